@@ -0,0 +1,86 @@
+package api_keys
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	/*AuditRecord - запрос к API мастера: чей ключ, к какой задаче и с каким результатом*/
+	AuditRecord struct {
+		Time       int64  `json:"time"`
+		Key        string `json:"key"` // имя ключа, пусто - ключ не распознан
+		Scope      string `json:"scope"`
+		Method     string `json:"method"`
+		Path       string `json:"path"`
+		TaskID     string `json:"task_id,omitempty"`
+		Status     int    `json:"status"`
+		RemoteAddr string `json:"remote_addr"`
+		Error      string `json:"error,omitempty"` // причина отказа в доступе
+	}
+
+	/*AuditLog - журнал запросов к API мастера (по строке JSON на запрос)*/
+	AuditLog struct {
+		path  string
+		mutex sync.Mutex
+	}
+
+	authContextKey struct{}
+
+	/*requestAuth - ключ запроса и его запись в журнале*/
+	requestAuth struct {
+		key    APIKey
+		record *AuditRecord
+	}
+)
+
+/*NewAuditLog - журнал запросов в файле path. Пустой путь отключает журнал*/
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+/*Write - добавление записи в журнал*/
+func (audit *AuditLog) Write(record *AuditRecord) {
+	if audit == nil || audit.path == "" {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Error("can not marshal audit record: ", err)
+		return
+	}
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+	file, err := os.OpenFile(audit.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Error("can not open audit log: ", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Error("can not write audit log: ", err)
+	}
+}
+
+/*WithAuth - запрос с проверенным ключом. Обработчики запроса могут дополнить его запись в журнале*/
+func WithAuth(request *http.Request, key APIKey, record *AuditRecord) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), authContextKey{}, requestAuth{key: key, record: record}))
+}
+
+/*AuthenticatedKey - ключ, с которым выполняется запрос*/
+func AuthenticatedKey(request *http.Request) (APIKey, bool) {
+	auth, ok := request.Context().Value(authContextKey{}).(requestAuth)
+	return auth.key, ok
+}
+
+/*SetAuditTask - задача запроса, идентификатор которой известен только из тела (например, при создании задачи)*/
+func SetAuditTask(request *http.Request, taskID string) {
+	if auth, ok := request.Context().Value(authContextKey{}).(requestAuth); ok && auth.record != nil {
+		auth.record.TaskID = taskID
+	}
+}
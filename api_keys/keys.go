@@ -0,0 +1,217 @@
+package api_keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ScopeSubmit - создание и отмена задач
+	ScopeSubmit = "submit"
+	// ScopeStatus - статусы, отчёты и оценка задач
+	ScopeStatus = "status"
+	// ScopeLogs - логи и артефакты задач
+	ScopeLogs = "logs"
+	// ScopeAdmin - все действия, включая состояние слейвов и удаление логов
+	ScopeAdmin = "admin"
+
+	// APIKeyHeader - заголовок с ключом для клиентов, которые не передают Authorization: Bearer
+	APIKeyHeader = "X-API-Key"
+
+	// legacyKeyName - ключ AGENT_ID, действующий, пока не создан файл ключей
+	legacyKeyName = "agent"
+	// reloadInterval - как часто проверяется изменение файла ключей
+	reloadInterval = time.Second
+)
+
+var scopes = map[string]bool{
+	ScopeSubmit: true,
+	ScopeStatus: true,
+	ScopeLogs:   true,
+	ScopeAdmin:  true,
+}
+
+type (
+	/*APIKey - ключ доступа к API мастера. В файле хранится только sha256 ключа*/
+	APIKey struct {
+		Name      string   `yaml:"name" json:"name"`
+		KeySHA256 string   `yaml:"key_sha256" json:"-"` // hex sha256 ключа (echo -n $KEY | sha256sum)
+		Scopes    []string `yaml:"scopes" json:"scopes"`
+		Expires   string   `yaml:"expires" json:"expires,omitempty"` // RFC3339, пусто - бессрочно
+	}
+
+	keysFile struct {
+		Keys []APIKey `yaml:"keys"`
+	}
+
+	/*KeyStore - ключи из файла API_KEYS_PATH. Файл перечитывается при изменении, поэтому ключи добавляются и отзываются без перезапуска мастера*/
+	KeyStore struct {
+		path   string
+		legacy *APIKey // ключ AGENT_ID с правами администратора, пока файла ключей нет
+
+		mutex   sync.Mutex
+		keys    map[string]APIKey // ключи по sha256
+		exist   bool              // файл ключей существует. Если он ещё ни разу не прочитан успешно, keys пуст
+		modTime time.Time
+		size    int64
+		checked time.Time
+	}
+)
+
+/*NewKeyStore - загрузка ключей из файла. Если файла нет, действует единственный ключ agentID с правами администратора*/
+func NewKeyStore(path, agentID string) *KeyStore {
+	store := &KeyStore{path: path, keys: map[string]APIKey{}}
+	if agentID != "" {
+		store.legacy = &APIKey{Name: legacyKeyName, KeySHA256: HashKey(agentID), Scopes: []string{ScopeAdmin}}
+	}
+	store.reload(time.Now())
+	if !store.exist {
+		log.Warn("api keys file not found: ", path, ". AGENT_ID will be used as admin key only while the file does not exist")
+	}
+	return store
+}
+
+/*HashKey - sha256 ключа в виде, в котором он хранится в файле ключей*/
+func HashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+/*KeyFromRequest - ключ из заголовка Authorization: Bearer или X-API-Key*/
+func KeyFromRequest(request *http.Request) string {
+	if authorization := request.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+	return request.Header.Get(APIKeyHeader)
+}
+
+/*Authenticate - поиск действующего ключа*/
+func (store *KeyStore) Authenticate(key string) (APIKey, error) {
+	if key == "" {
+		return APIKey{}, errors.New("api key is required")
+	}
+	now := time.Now()
+	store.mutex.Lock()
+	store.reload(now)
+	found, exist := store.keys[HashKey(key)]
+	if !store.exist && store.legacy != nil && store.legacy.KeySHA256 == HashKey(key) {
+		found, exist = *store.legacy, true
+	}
+	store.mutex.Unlock()
+	if !exist {
+		return APIKey{}, errors.New("unknown api key")
+	}
+	if found.expired(now) {
+		return APIKey{}, errors.New("api key expired: " + found.Name)
+	}
+	return found, nil
+}
+
+/*Allows - разрешено ли ключу действие*/
+func (key APIKey) Allows(scope string) bool {
+	for _, allowed := range key.Scopes {
+		if allowed == ScopeAdmin || allowed == scope {
+			return true
+		}
+	}
+	return scope == ""
+}
+
+func (key APIKey) expired(now time.Time) bool {
+	if key.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, key.Expires)
+	return err != nil || now.After(expires)
+}
+
+/*reload - перечитывание файла ключей, если он изменился. Файл с ошибкой не применяется: продолжают действовать прежние ключи, а если их ещё нет - доступ закрыт для всех ключей, включая AGENT_ID*/
+func (store *KeyStore) reload(now time.Time) {
+	if now.Sub(store.checked) < reloadInterval {
+		return
+	}
+	store.checked = now
+	info, err := os.Stat(store.path)
+	if os.IsNotExist(err) {
+		store.keys, store.exist, store.modTime, store.size = map[string]APIKey{}, false, time.Time{}, 0
+		return
+	}
+	if err != nil {
+		log.Error("can not check api keys file: ", err)
+		store.lockWithoutKeys()
+		return
+	}
+	if store.exist && info.ModTime().Equal(store.modTime) && info.Size() == store.size {
+		return
+	}
+	keys, err := readKeys(store.path)
+	if err != nil {
+		if store.modTime.IsZero() {
+			log.Error("can not load api keys, all keys are rejected until the file is fixed: ", err)
+			store.lockWithoutKeys()
+			return
+		}
+		log.Error("can not load api keys, previous keys are used: ", err)
+		return
+	}
+	store.keys, store.exist, store.modTime, store.size = keys, true, info.ModTime(), info.Size()
+	log.Info("loaded api keys: ", len(keys))
+}
+
+/*lockWithoutKeys - файл ключей есть, но ни один набор ключей из него не загружен: не принимается ни один ключ*/
+func (store *KeyStore) lockWithoutKeys() {
+	if store.modTime.IsZero() {
+		store.keys, store.exist = map[string]APIKey{}, true
+	}
+}
+
+func readKeys(path string) (map[string]APIKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keysFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	result := map[string]APIKey{}
+	for _, key := range file.Keys {
+		if err := key.validate(); err != nil {
+			return nil, err
+		}
+		key.KeySHA256 = strings.ToLower(key.KeySHA256)
+		if _, exist := result[key.KeySHA256]; exist {
+			return nil, errors.New("duplicate api key: " + key.Name)
+		}
+		result[key.KeySHA256] = key
+	}
+	return result, nil
+}
+
+func (key APIKey) validate() error {
+	if key.Name == "" {
+		return errors.New("api key without name")
+	}
+	if hash, err := hex.DecodeString(key.KeySHA256); err != nil || len(hash) != sha256.Size {
+		return errors.New("api key " + key.Name + " has invalid key_sha256")
+	}
+	for _, scope := range key.Scopes {
+		if !scopes[scope] {
+			return errors.New("api key " + key.Name + " has unknown scope: " + scope)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, key.Expires); key.Expires != "" && err != nil {
+		return errors.New("api key " + key.Name + " has invalid expires: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package api_keys
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKeys(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_KeyStoreScopesAndRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/keys.yaml"
+
+	// пока файла нет, действует AGENT_ID
+	store := NewKeyStore(path, "agent_secret")
+	key, err := store.Authenticate("agent_secret")
+	assert.Nil(t, err)
+	assert.True(t, key.Allows(ScopeAdmin))
+	_, err = store.Authenticate("")
+	assert.NotNil(t, err)
+
+	writeKeys(t, path, `
+keys:
+  - name: portal
+    key_sha256: `+HashKey("portal_secret")+`
+    scopes: [submit, status]
+  - name: old
+    key_sha256: `+HashKey("old_secret")+`
+    scopes: [logs]
+    expires: 2000-01-01T00:00:00Z
+`)
+	store.checked = time.Time{}
+	key, err = store.Authenticate("portal_secret")
+	assert.Nil(t, err)
+	assert.Equal(t, "portal", key.Name)
+	assert.True(t, key.Allows(ScopeSubmit))
+	assert.False(t, key.Allows(ScopeLogs))
+	assert.False(t, key.Allows(ScopeAdmin))
+	_, err = store.Authenticate("old_secret")
+	assert.NotNil(t, err)
+	// после создания файла AGENT_ID больше не действует
+	_, err = store.Authenticate("agent_secret")
+	assert.NotNil(t, err)
+
+	// файл с ошибкой не отменяет действующие ключи
+	writeKeys(t, path, `
+keys:
+  - name: broken
+    key_sha256: abc
+    scopes: [everything]
+`)
+	store.checked = time.Time{}
+	_, err = store.Authenticate("portal_secret")
+	assert.Nil(t, err)
+
+	// отзыв ключа без перезапуска
+	writeKeys(t, path, `
+keys:
+  - name: rotated
+    key_sha256: `+strings.ToUpper(HashKey("new_secret"))+`
+    scopes: [admin]
+`)
+	store.checked = time.Time{}
+	_, err = store.Authenticate("portal_secret")
+	assert.NotNil(t, err)
+	key, err = store.Authenticate("new_secret")
+	assert.Nil(t, err)
+	assert.True(t, key.Allows(ScopeLogs))
+}
+
+func Test_KeyStoreMalformedFileAtStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/keys.yaml"
+	writeKeys(t, path, "keys: [")
+
+	// файл с ошибкой при запуске не включает AGENT_ID
+	store := NewKeyStore(path, "agent_secret")
+	_, err = store.Authenticate("agent_secret")
+	assert.NotNil(t, err)
+
+	writeKeys(t, path, `
+keys:
+  - name: portal
+    key_sha256: `+HashKey("portal_secret")+`
+    scopes: [status]
+`)
+	store.checked = time.Time{}
+	key, err := store.Authenticate("portal_secret")
+	assert.Nil(t, err)
+	assert.Equal(t, "portal", key.Name)
+	_, err = store.Authenticate("agent_secret")
+	assert.NotNil(t, err)
+}
+
+func Test_KeyFromRequestAndAudit(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/task/task/status", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	assert.Equal(t, "secret", KeyFromRequest(request))
+	request = httptest.NewRequest(http.MethodGet, "/task/task/status", nil)
+	request.Header.Set(APIKeyHeader, "secret")
+	assert.Equal(t, "secret", KeyFromRequest(request))
+
+	file, err := ioutil.TempFile("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	record := &AuditRecord{Key: "portal", Method: http.MethodPost, Path: "/task", Status: http.StatusOK}
+	request = WithAuth(request, APIKey{Name: "portal"}, record)
+	SetAuditTask(request, "task")
+	key, ok := AuthenticatedKey(request)
+	assert.True(t, ok)
+	assert.Equal(t, "portal", key.Name)
+	NewAuditLog(file.Name()).Write(record)
+	NewAuditLog("").Write(record)
+
+	content, err := ioutil.ReadFile(file.Name())
+	assert.Nil(t, err)
+	var written AuditRecord
+	assert.Nil(t, json.Unmarshal(content, &written))
+	assert.Equal(t, "task", written.TaskID)
+	assert.Equal(t, "portal", written.Key)
+}
@@ -8,10 +8,42 @@ type ConfigurationMasterRunner struct {
 	PathToLogsWork        string `cf_env:"LOGS_WORK_PATH" cf_default:"logs"`
 	PathToReportsWork     string `cf_env:"REPORT_WORK_PATH" cf_default:"reports"`
 	MaxTaskPerSlave       int    `cf_env:"MAX_TASKS_PER_SLAVE" cf_default:"10"`
-	AgentID               string `cf_env:"AGENT_ID" cf_default:"default_agent"`
-	AverageTimeoutPerTask int    `cf_env:"AVERAGE_TIMEOUT_PER_TASK"`
+	AgentID               string `cf_env:"AGENT_ID" cf_default:"default_agent"` // ключ администратора API, пока не создан файл API_KEYS_PATH
+	AverageTimeoutPerTask int    `cf_env:"AVERAGE_TIMEOUT_PER_TASK"`            // таймаут задачи по умолчанию (мс), 0 - без ограничения
+	TaskStoreType         string `cf_env:"TASK_STORE_TYPE" cf_default:"FILE"`   // FILE, MEMORY
+	PathToTaskStore       string `cf_env:"TASK_STORE_PATH" cf_default:"tasks"`
+	SchedulerStrategy     string `cf_env:"SCHEDULER_STRATEGY" cf_default:"ROUND_ROBIN"` // ROUND_ROBIN, LEAST_LOADED, BIN_PACKING, AFFINITY
+	MaxReschedulePerTask  int    `cf_env:"MAX_RESCHEDULE_PER_TASK" cf_default:"3"`
+	PathToArtefactsWork   string `cf_env:"ARTEFACTS_WORK_PATH" cf_default:"artefacts"`
+	MaxArtefactsSize      int64  `cf_env:"ARTEFACTS_MAX_SIZE_MB" cf_default:"100"`     // максимальный размер артефактов одной загрузки (MB), 0 - без ограничения
+	ArtefactsRetention    int64  `cf_env:"ARTEFACTS_RETENTION_HOURS" cf_default:"168"` // время хранения артефактов (часы), 0 - хранятся бессрочно
+	WebhookURL            string `cf_env:"WEBHOOK_URL"`                                // адрес, на который отправляются изменения статусов всех задач. Пусто - только callback_url задач
+	WebhookSecret         string `cf_env:"WEBHOOK_SECRET"`                             // ключ HMAC-SHA256 подписи тела запроса (заголовок X-Runner-Signature)
+	WebhookMaxAttempts    int    `cf_env:"WEBHOOK_MAX_ATTEMPTS" cf_default:"5"`
+	WebhookBackoff        int64  `cf_env:"WEBHOOK_BACKOFF_MS" cf_default:"1000"`     // пауза перед первым повтором доставки (мс), удваивается с каждым повтором
+	PathToWebhooksWork    string `cf_env:"WEBHOOKS_WORK_PATH" cf_default:"webhooks"` // история доставок по задачам и журнал недоставленных событий
+	PathToAPIKeys         string `cf_env:"API_KEYS_PATH" cf_default:"api_keys.yaml"` // ключи API и их права, перечитываются при изменении файла
+	PathToAuditLog        string `cf_env:"AUDIT_LOG_PATH" cf_default:"audit.jsonl"`  // журнал запросов к API. Пусто - журнал не ведётся
+	PathToSecrets         string `cf_env:"SECRETS_PATH" cf_default:"secrets"`        // зашифрованные секреты для job
+	SecretsKey            string `cf_env:"SECRETS_KEY"`                              // ключ шифрования секретов: 32 байта в base64 (openssl rand -base64 32). Пусто - секреты недоступны
+	PathToSources         string `cf_env:"SOURCES_WORK_PATH" cf_default:"sources"`   // архивы кода кандидатов до завершения их задач
+	MaxSourceSize         int64  `cf_env:"SOURCE_MAX_SIZE_MB" cf_default:"100"`      // максимальный размер распакованного архива кода кандидата (MB), 0 - без ограничения
+	// WebhookAllowPrivate - callback_url задач может указывать на локальные и внутренние адреса (мастер, консул)
+	WebhookAllowPrivate bool `cf_env:"WEBHOOK_CALLBACK_ALLOW_PRIVATE" cf_default:"false"`
 }
 
+const (
+	TASKSTOREFILE   = "FILE"
+	TASKSTOREMEMORY = "MEMORY"
+)
+
+const (
+	SCHEDULERROUNDROBIN  = "ROUND_ROBIN"
+	SCHEDULERLEASTLOADED = "LEAST_LOADED"
+	SCHEDULERBINPACKING  = "BIN_PACKING"
+	SCHEDULERAFFINITY    = "AFFINITY"
+)
+
 /*ConfiureRunnerMaster - конфигурировании мастер ноды через Environment variables
  */
 func ConfiureRunnerMaster() (*ConfigurationMasterRunner, error) {
@@ -1,12 +1,81 @@
 package config
 
-import "github.com/goreflect/gostructor"
+import (
+	"strings"
+
+	"github.com/goreflect/gostructor"
+	"github.com/kubitre/diplom/models"
+)
 
 /*ConfigurationSlaveRunner - конфигурация слейв ноды
  */
 type ConfigurationSlaveRunner struct {
-	AmountPullWorkers          int `cf_env:"AMOUNT_PULL_WORKERS" cf_default:"10"`
-	AmountParallelTaskPerStage int `cf_env:"AMOUNT_PARALLEL_TASK_PER_STAGE" cf_default:"100"`
+	AmountPullWorkers          int    `cf_env:"AMOUNT_PULL_WORKERS" cf_default:"10"`
+	AmountParallelTaskPerStage int    `cf_env:"AMOUNT_PARALLEL_TASK_PER_STAGE" cf_default:"100"`
+	Labels                     string `cf_env:"SLAVE_LABELS"`                                      // метки слейва в consul через запятую (например, image:golang,cpu:4)
+	PathToArtefactsCache       string `cf_env:"ARTEFACTS_CACHE_PATH" cf_default:"artefacts_cache"` // артефакты job выполняющихся задач для job следующих этапов
+	MaxArtefactSizeMB          int64  `cf_env:"ARTEFACTS_MAX_SIZE_MB" cf_default:"100"`            // ограничение размера одного артефакта job, 0 - без ограничения
+	PathToRepositories         string `cf_env:"REPOS_PATH" cf_default:"repos"`                     // репозитории кандидатов выполняющихся задач
+	RepoMaxSizeMB              int64  `cf_env:"REPO_MAX_SIZE_MB" cf_default:"512"`                 // ограничение размера репозитория кандидата, 0 - без ограничения
+	BaseImages                 string `cf_env:"BASE_IMAGES"`                                       // образы через запятую, которые слейв скачивает при запуске и объявляет в consul метками image:{образ}
+	ImageCacheMB               int64  `cf_env:"IMAGE_CACHE_MB" cf_default:"10240"`                 // ограничение размера кэша образов job, 0 - кэш выключен
+
+	// ограничения контейнеров job по умолчанию
+	ContainerCPUs           float64 `cf_env:"CONTAINER_CPUS" cf_default:"1"`
+	ContainerMemory         int64   `cf_env:"CONTAINER_MEMORY_MB" cf_default:"512"`
+	ContainerPids           int64   `cf_env:"CONTAINER_PIDS_LIMIT" cf_default:"256"`
+	ContainerDisk           int64   `cf_env:"CONTAINER_DISK_MB" cf_default:"0"` // 0 - без ограничения (ограничение поддерживается не всеми storage драйверами)
+	ContainerNetwork        string  `cf_env:"CONTAINER_NETWORK" cf_default:"none"`
+	ContainerReadOnlyRootfs bool    `cf_env:"CONTAINER_READ_ONLY_ROOTFS" cf_default:"true"`
+	ContainerCapDrop        string  `cf_env:"CONTAINER_CAP_DROP" cf_default:"ALL"` // через запятую
+	ContainerUser           string  `cf_env:"CONTAINER_USER" cf_default:"65534:65534"`
+	// изменения изоляции, которые слейв разрешает job. По умолчанию job не может их ослабить
+	ContainerAllowJobUser         bool `cf_env:"CONTAINER_ALLOW_JOB_USER" cf_default:"false"`
+	ContainerAllowJobCapabilities bool `cf_env:"CONTAINER_ALLOW_JOB_CAPABILITIES" cf_default:"false"`
+}
+
+/*DefaultResources - ограничения контейнеров job, если они не заданы в job*/
+func (config *ConfigurationSlaveRunner) DefaultResources() models.ContainerResources {
+	readOnlyRootfs := config.ContainerReadOnlyRootfs
+	return models.ContainerResources{
+		CPUs:           config.ContainerCPUs,
+		Memory:         config.ContainerMemory,
+		Pids:           config.ContainerPids,
+		Disk:           config.ContainerDisk,
+		Network:        config.ContainerNetwork,
+		ReadOnlyRootfs: &readOnlyRootfs,
+		CapDrop:        splitList(config.ContainerCapDrop),
+		User:           config.ContainerUser,
+	}
+}
+
+/*ContainerPolicy - какие настройки изоляции job может изменить*/
+func (config *ConfigurationSlaveRunner) ContainerPolicy() models.ContainerPolicy {
+	return models.ContainerPolicy{
+		AllowUser:         config.ContainerAllowJobUser,
+		AllowCapabilities: config.ContainerAllowJobCapabilities,
+	}
+}
+
+/*GetLabels - метки слейва для регистрации в consul*/
+func (config *ConfigurationSlaveRunner) GetLabels() []string {
+	return splitList(config.Labels)
+}
+
+/*GetBaseImages - образы, которые слейв скачивает при запуске*/
+func (config *ConfigurationSlaveRunner) GetBaseImages() []string {
+	return splitList(config.BaseImages)
+}
+
+/*splitList - значения, перечисленные через запятую*/
+func splitList(value string) []string {
+	result := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
 }
 
 /*ConfigureRunnerSlave - конфигурирования slave сервиса
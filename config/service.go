@@ -9,6 +9,9 @@ type ServiceConfig struct {
 	ConsulPassword string `cf_env:"CONSUL_PASSWORD" cf_default:"password"`
 	ServiceType    string `cf_env:"SERVICE_TYPE" cf_default:"SLAVE"`     // MASTER, SLAVE
 	ServicePlugin  string `cf_env:"SERVICE_PLUGIN" cf_default:"DEFAULT"` // DEFAULT, PORTAL
+	RunnerSecret   string `cf_env:"RUNNER_SECRET"`                       // общий ключ подписи запросов между мастером и слейвами. Пусто - сервис не запускается
+	// RunnerAuthDisabled - запросы между мастером и слейвами не подписываются и не проверяются (только для локального запуска)
+	RunnerAuthDisabled bool `cf_env:"RUNNER_AUTH_DISABLED" cf_default:"false"`
 }
 
 const (
@@ -1,11 +1,28 @@
 package core
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/client"
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/kubitre/diplom/config"
+	"github.com/kubitre/diplom/discovery"
+	"github.com/kubitre/diplom/docker_runner"
+	"github.com/kubitre/diplom/enhancer"
 	"github.com/kubitre/diplom/gitmod"
 	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_SetupConfigurationPipeline(t *testing.T) {
@@ -14,7 +31,7 @@ func Test_SetupConfigurationPipeline(t *testing.T) {
 		&config.ConfigurationSlaveRunner{
 			AmountPullWorkers:          10,
 			AmountParallelTaskPerStage: 100,
-		}, &config.ServiceConfig{},
+		}, &config.ServiceConfig{RunnerAuthDisabled: true},
 	)
 	if err != nil {
 		t.Error("not created runner." + err.Error())
@@ -41,7 +58,7 @@ func Test_SetupConfigurationPipelineZeroStages(t *testing.T) {
 	runner, err := NewCoreSlaveRunner(&config.ConfigurationSlaveRunner{
 		AmountPullWorkers:          10,
 		AmountParallelTaskPerStage: 100,
-	}, &config.ServiceConfig{})
+	}, &config.ServiceConfig{RunnerAuthDisabled: true})
 	if err != nil {
 		t.Error("not created runner." + err.Error())
 	}
@@ -67,7 +84,7 @@ func Test_SetupConfigurationPipelineZeroTasks(t *testing.T) {
 	runner, err := NewCoreSlaveRunner(&config.ConfigurationSlaveRunner{
 		AmountPullWorkers:          10,
 		AmountParallelTaskPerStage: 100,
-	}, &config.ServiceConfig{})
+	}, &config.ServiceConfig{RunnerAuthDisabled: true})
 	if err != nil {
 		t.Error("not created runner." + err.Error())
 	}
@@ -88,11 +105,11 @@ func Test_CreatePipelineError(t *testing.T) {
 	runner, err := NewCoreSlaveRunner(&config.ConfigurationSlaveRunner{
 		AmountPullWorkers:          10,
 		AmountParallelTaskPerStage: 100,
-	}, &config.ServiceConfig{})
+	}, &config.ServiceConfig{RunnerAuthDisabled: true})
 	if err != nil {
 		t.Error("not created runner." + err.Error())
 	}
-	if err := runner.CreatePipeline(nil); err != nil {
+	if err := runner.CreatePipeline(context.Background(), nil); err != nil {
 		t.Log("completed test.", err)
 	} else {
 		t.Error("")
@@ -104,11 +121,11 @@ func Test_CreatePipelineWithConfig(t *testing.T) {
 	runner, err := NewCoreSlaveRunner(&config.ConfigurationSlaveRunner{
 		AmountPullWorkers:          10,
 		AmountParallelTaskPerStage: 100,
-	}, &config.ServiceConfig{})
+	}, &config.ServiceConfig{RunnerAuthDisabled: true})
 	if err != nil {
 		t.Error("not created runner." + err.Error())
 	}
-	if err := runner.CreatePipeline(&models.TaskConfig{
+	if err := runner.CreatePipeline(context.Background(), &models.TaskConfig{
 		Stages: []string{
 			"test",
 		},
@@ -135,7 +152,7 @@ func Test_CreatePipelineWithConfig(t *testing.T) {
 
 func Test_GetTypeError(t *testing.T) {
 	git := gitmod.Git{}
-	path, err := git.CloneRepo("http://github.com/kubitre/")
+	path, err := git.CloneRepo("http://github.com/kubitre/", nil)
 	switch git.GetTypeError(err) {
 	case gitmod.ErrorAuthenticate:
 		t.Log("test")
@@ -151,3 +168,374 @@ func Test_GetTypeError(t *testing.T) {
 	}
 	t.Error("Path: ", path)
 }
+
+func Test_CancelTask(t *testing.T) {
+	runner := &SlaveRunnerCore{
+		WorkerPull: make(chan models.TaskConfig, 1),
+		tasks:      newTaskRegistry(),
+	}
+	assert.NotNil(t, runner.CancelTask("unknown"))
+
+	runner.AddTask(models.TaskConfig{TaskID: "test"})
+	ctx := runner.tasks.context("test")
+	assert.Nil(t, ctx.Err())
+	assert.Nil(t, runner.CancelTask("test"))
+	assert.Equal(t, context.Canceled, ctx.Err())
+	assert.Equal(t, errTaskCanceled, runner.CreatePipeline(ctx, &models.TaskConfig{
+		TaskID: "test",
+		Stages: []string{"test"},
+	}))
+
+	runner.tasks.release("test")
+	assert.NotNil(t, runner.CancelTask("test"))
+}
+
+func Test_RetryPolicy(t *testing.T) {
+	var noRetry *models.RetryPolicy
+	assert.False(t, noRetry.ShouldRetry(models.RetryOnTimeout, 1))
+
+	policy := &models.RetryPolicy{Max: 2, Backoff: 100, On: []string{models.RetryOnTimeout, models.RetryOnNonZeroExit}}
+	assert.True(t, policy.ShouldRetry(models.RetryOnTimeout, 1))
+	assert.True(t, policy.ShouldRetry(models.RetryOnNonZeroExit, 2))
+	assert.False(t, policy.ShouldRetry(models.RetryOnNonZeroExit, 3))
+	assert.False(t, policy.ShouldRetry(models.RetryOnBuildError, 1))
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(2))
+
+	anyError := &models.RetryPolicy{Max: 1}
+	assert.True(t, anyError.ShouldRetry("", 1))
+
+	// пауза не переполняется и не превышает MaxRetryBackoff
+	assert.Equal(t, models.MaxRetryBackoff, policy.Delay(100))
+	assert.Equal(t, models.MaxRetryBackoff, (&models.RetryPolicy{Backoff: 1 << 62}).Delay(1))
+	assert.NotNil(t, (&models.TaskConfig{Retry: &models.RetryPolicy{Max: models.MaxRetries + 1}}).Validate())
+	assert.NotNil(t, (&models.TaskConfig{Jobs: map[string]models.Job{"build": {Retry: &models.RetryPolicy{Max: 1000000}}}}).Validate())
+	assert.Nil(t, (&models.TaskConfig{Jobs: map[string]models.Job{"build": {Retry: &models.RetryPolicy{Max: models.MaxRetries}}}}).Validate())
+}
+
+func Test_JobFailure(t *testing.T) {
+	reason, failed := jobFailure(WorkJob{JobStatus: failJob, FailReason: models.RetryOnBuildError})
+	assert.True(t, failed)
+	assert.Equal(t, models.RetryOnBuildError, reason)
+
+	reason, failed = jobFailure(WorkJob{JobStatus: nonZeroExitJob, ExitCode: 1})
+	assert.True(t, failed)
+	assert.Equal(t, models.RetryOnNonZeroExit, reason)
+
+	// вывод в STDERR не делает job упавшей
+	_, failed = jobFailure(WorkJob{JobStatus: executedJob, JobResukt: models.LogsPerTask{STDERR: []string{"warning"}}})
+	assert.False(t, failed)
+}
+
+func Test_RetryOnRuntimeError(t *testing.T) {
+	// консул без мастера и докер, в котором контейнер job не запускается
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		path := request.URL.Path
+		switch {
+		case strings.HasPrefix(path, "/v1/health/service/"):
+			writer.Write([]byte("[]"))
+		case strings.HasSuffix(path, "/build"):
+			writer.Write([]byte("{\"stream\":\"Successfully built\"}\n"))
+		case strings.HasSuffix(path, "/containers/create"):
+			writer.WriteHeader(http.StatusCreated)
+			writer.Write([]byte(`{"Id":"job"}`))
+		case strings.HasSuffix(path, "/containers/job/start"):
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(`{"message":"OCI runtime create failed"}`))
+		case request.Method == http.MethodDelete:
+			writer.Write([]byte("[]"))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+			writer.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+	dockerClient, err := client.NewClient(server.URL, "1.38", server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consulClient, err := consulapi.NewClient(&consulapi.Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &SlaveRunnerCore{
+		Docker:      &docker_runner.DockerExecutor{DockerClient: dockerClient},
+		SlaveConfig: &config.ConfigurationSlaveRunner{},
+		Discovery:   &discovery.Discovery{ConsulClient: consulClient},
+		tasks:       newTaskRegistry(),
+	}
+
+	workJob := runJobAttempt(context.Background(), models.Job{TaskID: "task", Stage: "test", JobName: "unit", Image: []string{"FROM alpine"}}, runner, 1)
+	reason, failed := jobFailure(workJob)
+	assert.True(t, failed)
+	assert.Equal(t, models.RetryOnRuntimeError, reason)
+	assert.Equal(t, int64(-1), workJob.ExitCode)
+	assert.True(t, (&models.RetryPolicy{Max: 1, On: []string{models.RetryOnRuntimeError}}).ShouldRetry(reason, 1))
+	assert.False(t, (&models.RetryPolicy{Max: 1, On: []string{models.RetryOnBuildError}}).ShouldRetry(reason, 1))
+}
+
+func Test_SuccessExitCodes(t *testing.T) {
+	job := models.Job{}
+	assert.True(t, job.IsSuccessExitCode(0))
+	assert.False(t, job.IsSuccessExitCode(1))
+
+	job.SuccessExitCodes = []int64{0, 2}
+	assert.True(t, job.IsSuccessExitCode(2))
+	assert.False(t, job.IsSuccessExitCode(1))
+}
+
+func Test_TaskTimeout(t *testing.T) {
+	runner := &SlaveRunnerCore{tasks: newTaskRegistry()}
+	ctx, cancel := runner.taskContext(&models.TaskConfig{TaskID: "test", Timeout: 1})
+	defer cancel()
+	<-ctx.Done()
+	assert.Equal(t, errTaskTimeout, runner.CreatePipeline(ctx, &models.TaskConfig{
+		TaskID: "test",
+		Stages: []string{"test"},
+	}))
+
+	reason, failed := jobFailure(WorkJob{JobStatus: timeoutJob, FailReason: models.RetryOnTimeout})
+	assert.True(t, failed)
+	assert.Equal(t, models.RetryOnTimeout, reason)
+}
+
+func Test_JobLogStream(t *testing.T) {
+	received := []models.LogLine{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		decoder := json.NewDecoder(request.Body)
+		for {
+			var line models.LogLine
+			if err := decoder.Decode(&line); err != nil {
+				break
+			}
+			received = append(received, line)
+		}
+	}))
+	defer server.Close()
+
+	stream := newJobLogStream(nil, server.URL, models.Job{Stage: "build", JobName: "compile"}, 2)
+	stream.Write(models.LogLine{Stream: models.LogStreamStdout, Line: "first"})
+	stream.Write(models.LogLine{Stream: models.LogStreamStderr, Line: "second"})
+	stream.Close()
+	assert.Equal(t, []models.LogLine{
+		{Stream: models.LogStreamStdout, Stage: "build", Job: "compile", Attempt: 2, Line: "first"},
+		{Stream: models.LogStreamStderr, Stage: "build", Job: "compile", Attempt: 2, Line: "second"},
+	}, received)
+
+	// без мастера строки не отправляются
+	var disabled *jobLogStream
+	disabled.Write(models.LogLine{Line: "line"})
+	disabled.Close()
+}
+
+func Test_JobLogsKeepOrderOfStreams(t *testing.T) {
+	logs := models.LogsPerTask{}
+	logs.AddLine(models.LogStreamBuild, "Step 1/2")
+	logs.AddLine(models.LogStreamStderr, "warning")
+	logs.AddLine(models.LogStreamStdout, "ok")
+	assert.Equal(t, "Step 1/2\nwarning\nok\n", mergeSTD(logs))
+
+	sent := jobLogs(WorkJob{Stage: "build", JobName: "compile", Attempt: 1, JobResukt: logs}, nil)
+	assert.Equal(t, 3, len(sent.Records))
+	assert.Equal(t, "compile", sent.Records[1].Job)
+	assert.Equal(t, models.LogStreamStderr, sent.Records[1].Stream)
+	assert.Equal(t, "", logs.Records[1].Job)
+}
+
+func Test_SendArtefactToMaster(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Query().Get("path") == "/big" {
+			writer.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		received = string(body)
+	}))
+	defer server.Close()
+
+	assert.Nil(t, sendArtefactToMaster(context.Background(), nil, server.URL+"?path=/report.xml", strings.NewReader("archive")))
+	assert.Equal(t, "archive", received)
+	assert.NotNil(t, sendArtefactToMaster(context.Background(), nil, server.URL+"?path=/big", strings.NewReader("archive")))
+}
+
+func Test_CollectArtefactLimit(t *testing.T) {
+	// докер отдаёт артефакт /service размером size из контейнера job
+	var size int64
+	sent := false
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !strings.HasSuffix(request.URL.Path, "/containers/job/archive") {
+			sent = true
+			return
+		}
+		writer.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString([]byte(`{"name":"service"}`)))
+		archive := tar.NewWriter(writer)
+		archive.WriteHeader(&tar.Header{Name: "service", Mode: 0644, Size: size, Typeflag: tar.TypeReg})
+		archive.Write(make([]byte, size))
+		archive.Close()
+	}))
+	defer server.Close()
+	dockerClient, err := client.NewClient(server.URL, "1.38", server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := ioutil.TempDir("", "artefacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	runner := &SlaveRunnerCore{
+		Docker:      &docker_runner.DockerExecutor{DockerClient: dockerClient},
+		SlaveConfig: &config.ConfigurationSlaveRunner{PathToArtefactsCache: path, MaxArtefactSizeMB: 1},
+	}
+	job := models.Job{TaskID: "task", JobName: "build"}
+
+	size = 2 * 1024 * 1024
+	assert.Equal(t, enhancer.ErrArchiveTooLarge, runner.collectArtefact(context.Background(), job, "job", "/service", server.URL))
+	_, errStat := os.Stat(path + "/task/build/service")
+	assert.True(t, os.IsNotExist(errStat))
+	assert.False(t, sent)
+
+	size = 1024
+	assert.Nil(t, runner.collectArtefact(context.Background(), job, "job", "/service", server.URL))
+	info, errStat := os.Stat(path + "/task/build/service")
+	assert.Nil(t, errStat)
+	assert.Equal(t, size, info.Size())
+	assert.True(t, sent)
+}
+
+func Test_JobDependencies(t *testing.T) {
+	path, err := ioutil.TempDir("", "artefacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	core := &SlaveRunnerCore{SlaveConfig: &config.ConfigurationSlaveRunner{PathToArtefactsCache: path}}
+	os.MkdirAll(core.jobArtefactsPath("task", "build"), os.ModePerm)
+
+	dependencies, err := core.jobDependencies(models.Job{TaskID: "task", JobName: "test", Needs: []string{"build"}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{path + "/task/build": "/artefacts/build"}, dependencies)
+	_, err = core.jobDependencies(models.Job{TaskID: "task", JobName: "test", Needs: []string{"lint"}})
+	assert.NotNil(t, err)
+
+	core.removeTaskArtefacts("task")
+	_, err = os.Stat(path + "/task")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_GetJobsByStageKeepsSecrets(t *testing.T) {
+	runner := &SlaveRunnerCore{}
+	unit := models.Job{
+		Stage:               "test",
+		Image:               []string{"FROM golang", models.RepoCandidateAnnotation},
+		Timeout:             1000,
+		RepositoryCandidate: "https://example.com/candidate.git",
+		RepositoryAuth:      &models.RepoAuth{Type: models.RepoAuthToken, Secret: "TOKEN"},
+		RepositoryRef:       "main",
+		RepositoryCommit:    "0123456789012345678901234567890123456789",
+		RepositoryDepth:     1,
+		RepositorySubmodule: true,
+		ShellCommands:       []string{"go test ./..."},
+		Reports:             map[string]models.ReportConfig{"coverage": {}},
+		Retry:               &models.RetryPolicy{Max: 1},
+		AllowFailure:        true,
+		SuccessExitCodes:    []int64{0, 1},
+		Resources:           &models.ContainerResources{Network: "none"},
+		Artefacts:           []string{"/repoCandidate/coverage.out"},
+		Needs:               []string{"build"},
+		Secrets:             map[string]string{"API_TOKEN": "TOKEN"},
+	}
+	jobs := runner.getJobsByStage("test", map[string]models.Job{
+		"unit":  unit,
+		"build": {Stage: "build"},
+	}, "task")
+	assert.Equal(t, 1, len(jobs))
+	expected := unit
+	expected.JobName, expected.TaskID = "unit", "task"
+	assert.Equal(t, expected, jobs[0])
+	assert.Equal(t, []string{"API_TOKEN=value"}, jobs[0].Env(models.SecretValues{"TOKEN": "value"}))
+}
+
+func Test_JobRepository(t *testing.T) {
+	path, err := ioutil.TempDir("", "repos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	core := &SlaveRunnerCore{SlaveConfig: &config.ConfigurationSlaveRunner{PathToRepositories: path}}
+	job := models.Job{TaskID: "task", JobName: "test", Image: []string{"FROM golang", models.RepoCandidateAnnotation}}
+
+	_, err = core.jobRepository(job)
+	assert.NotNil(t, err)
+	os.MkdirAll(core.taskRepositoryPath("task"), os.ModePerm)
+	repository, err := core.jobRepository(job)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{path + "/task": docker_runner.RepoCandidatePath}, repository)
+	repository, err = core.jobRepository(models.Job{TaskID: "task", Image: []string{"FROM golang"}})
+	assert.Nil(t, err)
+	assert.Empty(t, repository)
+
+	core.removeTaskRepository("task")
+	_, err = os.Stat(path + "/task")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_RepoCredentials(t *testing.T) {
+	secrets := models.SecretValues{"TOKEN": "token", "KEY": "private key", "HOSTS": "known hosts"}
+	assert.Nil(t, repoCredentials(nil, secrets))
+	assert.Equal(t, &gitmod.Credentials{Username: "oauth2", Token: "token"},
+		repoCredentials(&models.RepoAuth{Type: models.RepoAuthToken, Secret: "TOKEN", Username: "oauth2"}, secrets))
+	assert.Equal(t, &gitmod.Credentials{PrivateKey: "private key", KnownHosts: "known hosts"},
+		repoCredentials(&models.RepoAuth{Type: models.RepoAuthSSH, Secret: "KEY", KnownHosts: "HOSTS"}, secrets))
+}
+
+func Test_FetchSourceArchive(t *testing.T) {
+	path, err := ioutil.TempDir("", "repos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/task/task/source" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gzipWriter := gzip.NewWriter(writer)
+		tarWriter := tar.NewWriter(gzipWriter)
+		tarWriter.WriteHeader(&tar.Header{Name: "cmd/main.go", Mode: 0644, Size: 12, Typeflag: tar.TypeReg})
+		tarWriter.Write([]byte("package main"))
+		tarWriter.Close()
+		gzipWriter.Close()
+	}))
+	defer server.Close()
+
+	assert.Nil(t, fetchSourceArchive(context.Background(), nil, server.URL+"/task/task/source", path+"/task", 0))
+	content, _ := ioutil.ReadFile(path + "/task/cmd/main.go")
+	assert.Equal(t, "package main", string(content))
+	assert.NotNil(t, fetchSourceArchive(context.Background(), nil, server.URL+"/task/task/source", path+"/limit", 1))
+	assert.NotNil(t, fetchSourceArchive(context.Background(), nil, server.URL+"/task/unknown/source", path+"/unknown", 0))
+}
+
+func Test_PullBaseImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Query().Get("fromImage") {
+		case "golang":
+			writer.Write([]byte("{\"status\":\"Downloaded newer image for golang:1.14\"}\n"))
+		case "private":
+			writer.Write([]byte("{\"error\":\"pull access denied\"}\n"))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+			writer.Write([]byte(`{"message":"manifest unknown"}`))
+		}
+	}))
+	defer server.Close()
+	dockerClient, err := client.NewClient(server.URL, "1.38", server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pulled := pullBaseImages(&docker_runner.DockerExecutor{DockerClient: dockerClient}, []string{"golang:1.14", "private", "unknown"})
+	assert.Equal(t, []string{"golang:1.14"}, pulled)
+	assert.Equal(t, []string{"image:golang:1.14"}, baseImageTags(pulled))
+}
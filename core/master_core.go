@@ -6,6 +6,9 @@ import (
 	"github.com/kubitre/diplom/config"
 	"github.com/kubitre/diplom/discovery"
 	"github.com/kubitre/diplom/monitor"
+	"github.com/kubitre/diplom/notifications"
+	"github.com/kubitre/diplom/runner_auth"
+	"github.com/kubitre/diplom/secret_store"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -13,27 +16,81 @@ import (
 type MasterRunnerCore struct {
 	Discovery     *discovery.Discovery
 	SlaveMoniring *monitor.SlaveMonitoring
+	Webhooks      *notifications.WebhookDispatcher
+	Secrets       *secret_store.SecretStore
 }
 
 /*InitNewMasterRunnerCore - инициализация ядра текущего сервиса*/
-func InitNewMasterRunnerCore(config *config.ConfigurationMasterRunner,
+func InitNewMasterRunnerCore(masterConfig *config.ConfigurationMasterRunner,
 	configService *config.ServiceConfig,
 ) (*MasterRunnerCore, error) {
-	slaveMonitor, err := monitor.InitializeNewSlaveMonitoring(config.MaxTaskPerSlave)
+	taskRepository, err := initializeTaskRepository(masterConfig)
 	if err != nil {
 		return nil, err
 	}
-	slaveMonitor.LastUsingService = 0
+	slaveMonitor, err := monitor.InitializeNewSlaveMonitoring(masterConfig.MaxTaskPerSlave, taskRepository, initializeScheduler(masterConfig))
+	if err != nil {
+		return nil, err
+	}
+	slaveMonitor.MaxReschedulePerTask = masterConfig.MaxReschedulePerTask
+	slaveMonitor.DefaultTaskTimeout = int64(masterConfig.AverageTimeoutPerTask)
+	webhooks, err := notifications.NewWebhookDispatcher(masterConfig)
+	if err != nil {
+		return nil, err
+	}
+	slaveMonitor.Listener = webhooks
+	slaveMonitor.Auth, err = runner_auth.NewRunnerAuth(configService.RunnerSecret, configService.RunnerAuthDisabled)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := secret_store.NewSecretStore(masterConfig.PathToSecrets, masterConfig.SecretsKey)
+	if err != nil {
+		return nil, err
+	}
+	slaveMonitor.Secrets = secrets
 	return &MasterRunnerCore{
 		SlaveMoniring: slaveMonitor,
+		Webhooks:      webhooks,
+		Secrets:       secrets,
 		Discovery:     discovery.InitializeDiscovery(discovery.MasterPattern, configService),
 	}, nil
 }
 
+/*initializeTaskRepository - выбор хранилища задач по конфигурации мастера*/
+func initializeTaskRepository(masterConfig *config.ConfigurationMasterRunner) (monitor.TaskRepository, error) {
+	switch masterConfig.TaskStoreType {
+	case config.TASKSTOREMEMORY:
+		log.Info("tasks will be stored in memory")
+		return monitor.NewMemoryTaskRepository(), nil
+	default:
+		log.Info("tasks will be stored in: ", masterConfig.PathToTaskStore)
+		return monitor.NewFileTaskRepository(masterConfig.PathToTaskStore)
+	}
+}
+
+/*initializeScheduler - выбор стратегии распределения задач по слейвам по конфигурации мастера*/
+func initializeScheduler(masterConfig *config.ConfigurationMasterRunner) monitor.Scheduler {
+	log.Info("slave scheduler strategy: ", masterConfig.SchedulerStrategy)
+	switch masterConfig.SchedulerStrategy {
+	case config.SCHEDULERLEASTLOADED:
+		return monitor.NewLeastLoadedScheduler()
+	case config.SCHEDULERBINPACKING:
+		return monitor.NewBinPackingScheduler()
+	case config.SCHEDULERAFFINITY:
+		return monitor.NewAffinityScheduler()
+	case config.SCHEDULERROUNDROBIN:
+		return monitor.NewRoundRobinScheduler()
+	default:
+		log.Warn("unknown scheduler strategy, round robin will be used: ", masterConfig.SchedulerStrategy)
+		return monitor.NewRoundRobinScheduler()
+	}
+}
+
 /*Run - запуск роутера, discovery, получение информации о слейвах*/
 func (core *MasterRunnerCore) Run() {
 	core.Discovery.NewClientForConsule()
 	core.Discovery.RegisterServiceWithConsul([]string{discovery.TagMaster})
+	go core.SlaveMoniring.RunDispatcher()
 	go core.checkerNewSlave()
 
 }
@@ -50,6 +107,10 @@ func (core *MasterRunnerCore) checkerNewSlave() {
 		log.Debug("founded services: ", foundedSlaves)
 		core.SlaveMoniring.CompareAndSave(foundedSlaves)
 		core.SlaveMoniring.ClearNotAvailableSlaves(foundedSlaves)
+		// задачи пропавших слейвов возвращаются в очередь
+		core.SlaveMoniring.RescheduleOrphanedTasks()
+		// новые слейвы могут взять задачи из очереди
+		core.SlaveMoniring.NotifyDispatcher()
 		time.Sleep(time.Second * 15)
 	}
 }
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/runner_auth"
+	log "github.com/sirupsen/logrus"
+)
+
+/*collectArtefacts - копирование артефактов job из завершённого контейнера: они сохраняются на слейве для job следующих этапов и отправляются мастеру. Ошибки не останавливают job и добавляются в её лог*/
+func (core *SlaveRunnerCore) collectArtefacts(ctx context.Context, job models.Job, containerID string, logs *models.LogsPerTask, stream *jobLogStream) {
+	if len(job.Artefacts) == 0 {
+		return
+	}
+	address, errAddress := core.getAddressMaster()
+	if errAddress == nil {
+		address = "http://" + address + "/task/" + job.TaskID + "/artefacts/" + job.Stage + "/" + job.JobName
+	}
+	for _, path := range job.Artefacts {
+		log.Debug("collecting artefact: ", path, " of job: ", job.JobName)
+		if err := core.collectArtefact(ctx, job, containerID, path, address); err != nil {
+			log.Error("can not collect artefact: ", path, " by error: ", err)
+			stream.Write(logs.AddLine(models.LogStreamStderr, "can not collect artefact "+path+": "+err.Error()))
+		}
+	}
+}
+
+/*collectArtefact - архив из контейнера сохраняется во временный файл, чтобы распаковать его на слейве и отправить мастеру без повторного копирования из контейнера. Архив больше ARTEFACTS_MAX_SIZE_MB не сохраняется*/
+func (core *SlaveRunnerCore) collectArtefact(ctx context.Context, job models.Job, containerID, path, address string) error {
+	archive, err := core.Docker.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	temporary, err := ioutil.TempFile("", "artefact")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	defer temporary.Close()
+	limit := core.SlaveConfig.MaxArtefactSizeMB * 1024 * 1024
+	var source io.Reader = archive
+	limited := &io.LimitedReader{R: archive, N: limit + 1}
+	if limit > 0 {
+		source = limited
+	}
+	if _, err := io.Copy(temporary, source); err != nil {
+		return err
+	}
+	if limit > 0 && limited.N == 0 {
+		return enhancer.ErrArchiveTooLarge
+	}
+	if _, err := temporary.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := enhancer.ExtractArchive(temporary, core.jobArtefactsPath(job.TaskID, job.JobName), limit); err != nil {
+		return err
+	}
+	if address == "" {
+		return errors.New("not found master executor")
+	}
+	if _, err := temporary.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return sendArtefactToMaster(ctx, core.Auth, address+"?path="+url.QueryEscape(path), temporary)
+}
+
+/*jobDependencies - артефакты job из needs для копирования в образ job (путь на слейве: путь в образе)*/
+func (core *SlaveRunnerCore) jobDependencies(job models.Job) (map[string]string, error) {
+	result := map[string]string{}
+	for _, need := range job.Needs {
+		path := core.jobArtefactsPath(job.TaskID, need)
+		if _, err := os.Stat(path); err != nil {
+			return nil, errors.New("not found artefacts of job " + need + " needed by job " + job.JobName)
+		}
+		result[path] = "/artefacts/" + need
+	}
+	return result, nil
+}
+
+func (core *SlaveRunnerCore) jobArtefactsPath(taskID, jobName string) string {
+	return core.taskArtefactsPath(taskID) + "/" + jobName
+}
+
+func (core *SlaveRunnerCore) taskArtefactsPath(taskID string) string {
+	return core.SlaveConfig.PathToArtefactsCache + "/" + taskID
+}
+
+/*removeTaskArtefacts - удаление сохранённых на слейве артефактов задачи*/
+func (core *SlaveRunnerCore) removeTaskArtefacts(taskID string) {
+	if err := os.RemoveAll(core.taskArtefactsPath(taskID)); err != nil {
+		log.Warn("can not remove artefacts of task: ", taskID, " by error: ", err)
+	}
+}
+
+/*sendArtefactToMaster - отправка tar архива артефакта мастеру. Размер архива не проверяется: он ограничен при копировании из контейнера, а мастер отклоняет архивы больше своего ARTEFACTS_MAX_SIZE_MB*/
+func sendArtefactToMaster(ctx context.Context, auth *runner_auth.RunnerAuth, address string, archive io.Reader) error {
+	request, err := http.NewRequest(http.MethodPost, address, archive)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-tar")
+	auth.SignStream(request)
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return errors.New("master rejected artefact: " + response.Status + " " + string(body))
+	}
+	return nil
+}
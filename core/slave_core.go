@@ -2,23 +2,23 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/kubitre/diplom/config"
 	"github.com/kubitre/diplom/discovery"
 	"github.com/kubitre/diplom/docker_runner"
 	"github.com/kubitre/diplom/gitmod"
 	"github.com/kubitre/diplom/models"
 	"github.com/kubitre/diplom/payloads"
+	"github.com/kubitre/diplom/runner_auth"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -31,6 +31,8 @@ type (
 		ChannelClose chan string
 		SlaveConfig  *config.ConfigurationSlaveRunner
 		Discovery    *discovery.Discovery
+		Auth         *runner_auth.RunnerAuth // подпись запросов к мастеру и проверка запросов от него
+		tasks        *taskRegistry
 	}
 	/*Worker - единичная воркер функция, которая отвечает за выполнение всех job на одной стадии одной задачи*/
 	Worker struct {
@@ -45,14 +47,23 @@ type (
 		JobStatus  int
 		JobResukt  models.LogsPerTask
 		JobReports models.ReportPerTask
-		JobMetrics map[string]string
+		JobMetrics map[string]models.ReportConfig
+		// ReportFiles - содержимое файлов отчётов из контейнера job по пути отчёта
+		ReportFiles map[string][][]byte
+		FailReason  string // причина ошибки для политики повтора (models.RetryOn...)
+		Attempt     int    // номер попытки выполнения job, начиная с 1
+		ExitCode    int64  // код завершения контейнера job, -1 - контейнер не был выполнен
+		// AllowFailure - ошибка job не останавливает задачу
+		AllowFailure bool
 	}
 )
 
 const (
-	failJob     = 0
-	executedJob = 1
-	successJob  = 2
+	failJob        = 0
+	executedJob    = 1
+	successJob     = 2
+	nonZeroExitJob = 3 // контейнер завершился с кодом, не входящим в успешные коды job
+	timeoutJob     = 4 // job не завершилась за свой таймаут
 )
 
 /*NewCoreSlaveRunner - инициализация нового ядра слейв модуля*/
@@ -60,6 +71,10 @@ func NewCoreSlaveRunner(
 	config *config.ConfigurationSlaveRunner,
 	configService *config.ServiceConfig,
 ) (*SlaveRunnerCore, error) {
+	auth, err := runner_auth.NewRunnerAuth(configService.RunnerSecret, configService.RunnerAuthDisabled)
+	if err != nil {
+		return nil, err
+	}
 	dock, err := docker_runner.NewDockerExecutor()
 	if err != nil {
 		log.Error("can not create docker executor. " + err.Error())
@@ -78,13 +93,20 @@ func NewCoreSlaveRunner(
 		os.Exit(1)
 	}
 	log.Println("completed initilize discovery module")
-	discove.RegisterServiceWithConsul([]string{discovery.TagSlave})
+	tags := append([]string{discovery.TagSlave}, config.GetLabels()...)
+	if dock != nil {
+		setupImageCache(dock, config)
+		tags = append(tags, baseImageTags(pullBaseImages(dock, config.GetBaseImages()))...)
+	}
+	discove.RegisterServiceWithConsul(tags)
 	return &SlaveRunnerCore{
 		Git:          &gitmod.Git{},
 		Docker:       dock,
 		ChannelClose: make(chan string, 1),
 		SlaveConfig:  config,
 		Discovery:    discove,
+		Auth:         auth,
+		tasks:        newTaskRegistry(),
 	}, nil
 }
 
@@ -118,11 +140,25 @@ func executor(executorID int, taskChallenge <-chan models.TaskConfig, close chan
 			log.Info("stop worker: ", executorID, " by closed signal: ", close)
 		case newTask := <-taskChallenge:
 			log.Debug("start working with new task: ", newTask, " on worker : ", executorID)
-			if err := core.CreatePipeline(&newTask); err != nil {
+			ctx, cancel := core.taskContext(&newTask)
+			core.removeTaskArtefacts(newTask.TaskID)
+			core.removeTaskRepository(newTask.TaskID)
+			err := core.CreatePipeline(ctx, &newTask)
+			cancel()
+			core.tasks.release(newTask.TaskID)
+			core.removeTaskArtefacts(newTask.TaskID)
+			core.removeTaskRepository(newTask.TaskID)
+			switch err {
+			case nil:
+				core.successTask(newTask.TaskID, "unknown")
+			case errTaskCanceled:
+				log.Info("task was canceled: ", newTask.TaskID)
+			case errTaskTimeout:
+				log.Warn("task exceeded timeout: ", newTask.TaskID)
+				core.timeoutTask(newTask.TaskID, "unknown")
+			default:
 				log.Error("can not create pipeline for task. Err: ", err)
 				core.faieldTask(newTask.TaskID, "unknown")
-			} else {
-				core.successTask(newTask.TaskID, "unknown")
 			}
 			// send to Master node result log
 		}
@@ -142,25 +178,38 @@ func (core *SlaveRunnerCore) SetupConfigurationPipeline(config *models.TaskConfi
 	return nil
 }
 
-/*CreatePipeline - создание пайплайна на выполнение одной задачи*/
-func (core *SlaveRunnerCore) CreatePipeline(taskConfig *models.TaskConfig) error {
+/*CreatePipeline - создание пайплайна на выполнение одной задачи: клонирование репозитория кандидата и выполнение стадий. Отмена ctx останавливает выполнение задачи*/
+func (core *SlaveRunnerCore) CreatePipeline(ctx context.Context, taskConfig *models.TaskConfig) error {
 	if taskConfig == nil {
 		return errors.New("can not create pipeline without configuration. Please setup configuration and continue")
 	}
 	log.Debug("All available stages: ", taskConfig.Stages)
+	if err := core.checkoutRepository(ctx, taskConfig); err != nil {
+		return err
+	}
 	for _, stage := range taskConfig.Stages {
+		if ctx.Err() != nil {
+			return taskContextError(ctx)
+		}
 		log.Info("start working on stage: " + stage)
-		jobWork, amountJobs, err := core.executingJobsInStage(stage, taskConfig)
+		jobWork, amountJobs, err := core.executingJobsInStage(ctx, stage, taskConfig)
 		if err != nil {
 			log.Error("Something went wrong while exeucing jobs in stage: ", stage)
 			return err
 		}
 		for i := 0; i < amountJobs; i++ {
 			result := <-jobWork
+			if ctx.Err() != nil {
+				// ждём остальные job стадии, чтобы их контейнеры были остановлены
+				continue
+			}
 			if errChecking := checkJobResult(result, core); errChecking != nil {
 				return errChecking
 			}
 		}
+		if ctx.Err() != nil {
+			return taskContextError(ctx)
+		}
 	}
 	return nil
 }
@@ -180,6 +229,11 @@ func (core *SlaveRunnerCore) successTask(taskID, stage string) {
 	core.sendStatusTaskToMaster(taskID, models.SUCCESS, stage)
 }
 
+func (core *SlaveRunnerCore) timeoutTask(taskID, stage string) {
+	log.Debug("start send status Timeout for task to Master")
+	core.sendStatusTaskToMaster(taskID, models.TIMEOUT, stage)
+}
+
 func (core *SlaveRunnerCore) failedJob(taskID string, jobName string) {
 	log.Debug("start send status Fail for job to Master")
 	core.sendStatusJobToMaster(taskID, jobName, models.FAILED)
@@ -195,7 +249,7 @@ func (core *SlaveRunnerCore) sendStatusTaskToMaster(taskID string, status models
 	if errAddress != nil {
 		log.Error("can not get address of master executor")
 	}
-	if errStatusTask := sendStatusTask("http://"+addressMaster+"/task/"+taskID+"/status", taskID, status, stage); errStatusTask != nil {
+	if errStatusTask := sendStatusTask(core.Auth, "http://"+addressMaster+"/task/"+taskID+"/status", core.Discovery.CurrentServiceName, taskID, status, stage, core.tasks.commit(taskID)); errStatusTask != nil {
 		log.Error("Can not send status task: ", errStatusTask)
 	}
 }
@@ -206,13 +260,13 @@ func (core *SlaveRunnerCore) sendStatusJobToMaster(taskID, jobName string, statu
 	if errAddress != nil {
 		log.Error("can not get address of master executor")
 	}
-	if errStatusJob := sendStatusJob("http://"+addressMaster, taskID, jobName, status); errStatusJob != nil {
+	if errStatusJob := sendStatusJob(core.Auth, "http://"+addressMaster+"/task/"+taskID+"/status/"+jobName, core.Discovery.CurrentServiceName, taskID, jobName, status); errStatusJob != nil {
 		log.Error("Can not send status job: ", errStatusJob)
 	}
 }
 
 /*executingJobsInStage - sxecute entry for jobs start*/
-func (core *SlaveRunnerCore) executingJobsInStage(stage string, taskConfig *models.TaskConfig) (chan WorkJob, int, error) {
+func (core *SlaveRunnerCore) executingJobsInStage(ctx context.Context, stage string, taskConfig *models.TaskConfig) (chan WorkJob, int, error) {
 	log.Info("start executing jobs in stage: ", stage)
 	log.Debug("ALL JOBS: ", taskConfig.Jobs)
 	currentJobs := core.getJobsByStage(stage, taskConfig.Jobs, taskConfig.TaskID)
@@ -227,45 +281,44 @@ func (core *SlaveRunnerCore) executingJobsInStage(stage string, taskConfig *mode
 	// jobsNames := make(chan string, len(currentJobs))
 	for _, job := range currentJobs {
 		log.Info("current tasks for stage: ", stage, "; job: ", job.Reports)
-		go executingParallelJobPerStage(job, core, jobWork)
+		if job.Retry == nil {
+			job.Retry = taskConfig.Retry
+		}
+		go executingParallelJobPerStage(ctx, job, core, jobWork)
 		// go checkJobResult(jobWork, job, core, jobsChecked, jobsNames)
 	}
 	return jobWork, len(currentJobs), nil
 }
 
-func sendStatusTask(address, taskID string, status models.TaskStatusIndx, stage string) error {
+func sendStatusTask(auth *runner_auth.RunnerAuth, address, slaveID, taskID string, status models.TaskStatusIndx, stage, commit string) error {
 	log.Info("start sending results to master node")
 	pay := payloads.ChangeStatusTask{
 		TaskID:       taskID,
 		NewStatus:    int(status),
 		CurrentStage: stage,
+		SlaveID:      slaveID,
+		Commit:       commit,
 	}
 	resultMarshal, errMarshal := json.Marshal(&pay)
 	if errMarshal != nil {
 		return errMarshal
 	}
-	r := bytes.NewReader(resultMarshal)
-	_, err := http.Post(address, "application/json", r)
-	if err != nil {
-		return err
-	}
-	return nil
+	return postToMaster(auth, address, resultMarshal)
 }
 
-func sendStatusJob(address, taskID, jobName string, status models.TaskStatusIndx) error {
+func sendStatusJob(auth *runner_auth.RunnerAuth, address, slaveID, taskID, jobName string, status models.TaskStatusIndx) error {
 	log.Info("start sending job status to master node")
 	pay := payloads.ChangeStatusJob{
 		TaskID:    taskID,
 		NewStatus: int(status),
 		Job:       jobName,
+		SlaveID:   slaveID,
 	}
 	resultMarshal, errMarshal := json.Marshal(&pay)
 	if errMarshal != nil {
 		return errMarshal
 	}
-	r := bytes.NewReader(resultMarshal)
-	_, err := http.Post(address, "application/json", r)
-	if err != nil {
+	if err := postToMaster(auth, address, resultMarshal); err != nil {
 		log.Error("can not sent status to master executor: ", err, " Params: ", address, taskID, jobName, status)
 		return err
 	}
@@ -282,22 +335,13 @@ func (core *SlaveRunnerCore) getAddressMaster() (string, error) {
 	return address, nil
 }
 
+/*extractLogs - отправка логов job мастеру*/
 func (core *SlaveRunnerCore) extractLogs(workJob WorkJob) error {
-	if errMetricExtract := core.extractMetrtics(workJob); errMetricExtract != nil {
-		return errMetricExtract
-	}
 	address, errAddress := core.getAddressMaster()
 	if errAddress != nil {
 		log.Error("not found master executor in consul. Can not sending result")
 	}
-	if len(workJob.JobResukt.STDERR) > 0 {
-		log.Debug("job : ", workJob.JobName, " was failed status, because have stderrs")
-		sendStatusJob("http://"+address+"/task/"+workJob.TaskID+"/status/"+workJob.JobName, workJob.TaskID, workJob.JobName, models.FAILED)
-		core.faieldTask(workJob.TaskID, workJob.Stage)
-		return errors.New("can not send result to master executor")
-	}
-	sendStatusJob("http://"+address+"/task/"+workJob.TaskID+"/status/"+workJob.JobName, workJob.TaskID, workJob.JobName, models.SUCCESS)
-	if errSend := sendResultLogsToMaster("http://"+address+"/task/"+workJob.TaskID+"/log/"+workJob.Stage+"/"+workJob.JobName, workJob.JobResukt); errSend != nil {
+	if errSend := sendResultLogsToMaster(core.Auth, "http://"+address+"/task/"+workJob.TaskID+"/log/"+workJob.Stage+"/"+workJob.JobName, jobLogs(workJob, core.tasks.secrets(workJob.TaskID))); errSend != nil {
 		log.Error("can not sending result to master: ", errSend)
 		return errSend
 	}
@@ -306,15 +350,18 @@ func (core *SlaveRunnerCore) extractLogs(workJob WorkJob) error {
 
 func (core *SlaveRunnerCore) extractMetrtics(workJob WorkJob) error {
 	log.Debug("start extracting metics from logs")
-	allLogs := mergeSTD(workJob.JobResukt)
+	allLogs := core.tasks.secrets(workJob.TaskID).Mask(mergeSTD(workJob.JobResukt))
 	log.Debug("all logs: ", allLogs, " reg: ", workJob.JobMetrics)
-	reports := parseSTDToReport(allLogs, workJob.JobMetrics)
-	log.Debug("parsed metrics: ", reports)
+	reports := models.ReportPerTask{
+		Result: parseSTDToReport(allLogs, workJob.JobMetrics),
+		Tests:  parseTestReports(workJob, allLogs),
+	}
+	log.Debug("parsed metrics: ", reports.Result)
 	address, errAddress := core.getAddressMaster()
 	if errAddress != nil {
 		log.Error("not found master executor in consul. Can not sending result")
 	}
-	if errSend := sendResultReportsToMaster("http://"+address+"/task/"+workJob.TaskID+"/reports/"+workJob.JobName, reports); errSend != nil {
+	if errSend := sendResultReportsToMaster(core.Auth, "http://"+address+"/task/"+workJob.TaskID+"/reports/"+workJob.JobName, reports); errSend != nil {
 		log.Error("can not send report to master executor")
 		// jobChecked <- failJob
 		// jobName <- job.JobName
@@ -323,24 +370,42 @@ func (core *SlaveRunnerCore) extractMetrtics(workJob WorkJob) error {
 	return nil
 }
 
+/*checkJobResult - отправка результатов job мастеру. Статус job определяется кодом завершения её контейнера*/
 func checkJobResult(jobWork WorkJob, core *SlaveRunnerCore) error {
-	log.Info("start checking result work for job: ", jobWork.JobName)
+	log.Info("start checking result work for job: ", jobWork.JobName, " exit code: ", jobWork.ExitCode)
 	switch jobWork.JobStatus {
-	case failJob:
-		log.Error("error while executing job. start failing task")
+	case failJob, nonZeroExitJob:
+		if jobWork.JobStatus == nonZeroExitJob {
+			if errExtract := core.sendJobResults(jobWork); errExtract != nil {
+				return errExtract
+			}
+		}
 		core.failedJob(jobWork.TaskID, jobWork.JobName)
+		if jobWork.AllowFailure {
+			log.Warn("job was failed, but failure is allowed: ", jobWork.JobName)
+			return nil
+		}
+		log.Error("error while executing job. start failing task")
 		core.faieldTask(jobWork.TaskID, jobWork.Stage)
 		return errors.New("error while executing job. start failing task")
 	case executedJob:
 		log.Debug("success executing job. sending report per job to master")
+		if errExtract := core.sendJobResults(jobWork); errExtract != nil {
+			return errExtract
+		}
 		core.successJob(jobWork.TaskID, jobWork.JobName)
-		if errExtract1 := core.extractLogs(jobWork); errExtract1 != nil {
-			return errExtract1
+		return nil
+	case timeoutJob:
+		if errExtract := core.extractLogs(jobWork); errExtract != nil {
+			return errExtract
 		}
-		if errExtract2 := core.extractMetrtics(jobWork); errExtract2 != nil {
-			return errExtract2
+		core.sendStatusJobToMaster(jobWork.TaskID, jobWork.JobName, models.TIMEOUT)
+		if jobWork.AllowFailure {
+			log.Warn("job exceeded timeout, but failure is allowed: ", jobWork.JobName)
+			return nil
 		}
-		return nil
+		// статус задачи TIMEOUT отправляет воркер
+		return errTaskTimeout
 	default:
 		log.Error("Can not recognize status job. Send status failed")
 		core.faieldTask(jobWork.TaskID, jobWork.Stage)
@@ -348,102 +413,191 @@ func checkJobResult(jobWork WorkJob, core *SlaveRunnerCore) error {
 	}
 }
 
-func sendResultReportsToMaster(address string, result map[string][]string) error {
+/*sendJobResults - отправка метрик и логов выполненной job мастеру*/
+func (core *SlaveRunnerCore) sendJobResults(jobWork WorkJob) error {
+	if errMetrics := core.extractMetrtics(jobWork); errMetrics != nil {
+		return errMetrics
+	}
+	return core.extractLogs(jobWork)
+}
+
+func sendResultReportsToMaster(auth *runner_auth.RunnerAuth, address string, result models.ReportPerTask) error {
 	resultMarshal, errMarshal := json.Marshal(&result)
 	if errMarshal != nil {
 		log.Error("can not marshaled response: ", errMarshal)
 		return errMarshal
 	}
-	r := bytes.NewReader(resultMarshal)
-	resp, err := http.Post(address, "application/json", r)
-	if err != nil {
+	if err := postToMaster(auth, address, resultMarshal); err != nil {
 		log.Error("can not execute request", err)
 		return err
 	}
-	body, _ := ioutil.ReadAll(resp.Body)
-	log.Debug("response from master: ", string(body))
 	return nil
 }
 
-/*mergeSTD - merge output from containers in one. Need for create report*/
+/*mergeSTD - merge output from containers in one (in order of appearance). Need for create report*/
 func mergeSTD(jobResult models.LogsPerTask) (result string) {
-	for _, value := range jobResult.STDOUT {
-		result += value + "\n"
-	}
-	for _, value := range jobResult.STDERR {
-		result += value + "\n"
+	for _, record := range jobResult.LogRecords() {
+		result += record.Line + "\n"
 	}
 	return
 }
 
-func parseSTDToReport(allLogs string, jobsMetrics map[string]string) map[string][]string {
-	result := map[string][]string{} // result in format: key: []values
-	for nameRegular, rex := range jobsMetrics {
-		log.Debug("start extracting report: ", rex, " name: ", nameRegular)
-		parsingValues := parseSTD(rex, allLogs)
-		log.Debug("parsed metrics: ", parsingValues)
-		result[nameRegular] = parsingValues
+/*parseSTDToReport - все совпадения регулярных выражений метрик job в её логах. Ошибка регулярного выражения сохраняется в метрике*/
+func parseSTDToReport(allLogs string, jobsMetrics map[string]models.ReportConfig) map[string]models.MetricReport {
+	result := map[string]models.MetricReport{}
+	for nameRegular, report := range jobsMetrics {
+		if report.Type != models.ReportTypeRegex {
+			continue
+		}
+		log.Debug("start extracting report: ", report.Regex, " name: ", nameRegular)
+		metric := models.MatchMetric(report, allLogs)
+		if metric.Error != "" {
+			log.Error("can not parse metric: ", nameRegular, " by error: ", metric.Error)
+		}
+		log.Debug("parsed metrics: ", metric.Matches)
+		result[nameRegular] = metric
 	}
 	return result
 }
 
-func parseSTD(regx string, logs string) []string {
-	reg := regexp.MustCompile(regx)
-	log.Debug("start parsing metrics: regx: ", regx, " logs:", logs)
-	founded := reg.FindStringSubmatch(logs)
-	log.Debug("founded: ", founded)
-	return founded
-}
-
-func sendResultLogsToMaster(address string, jobResult models.LogsPerTask) error {
+func sendResultLogsToMaster(auth *runner_auth.RunnerAuth, address string, jobResult models.LogsPerTask) error {
 	resultMarshal, errMarshal := json.Marshal(&jobResult)
 	if errMarshal != nil {
 		log.Println("can not marshaled response: ", errMarshal)
 		return errMarshal
 	}
-	r := bytes.NewReader(resultMarshal)
-	resp, err := http.Post(address, "application/json", r)
-	if err != nil {
+	if err := postToMaster(auth, address, resultMarshal); err != nil {
 		log.Error("can not execute request", err)
 		return err
 	}
-	log.Debug("response from master: ", resp)
 	return nil
 }
 
-func readSTD(buffer *bytes.Buffer) []string {
-	var result []string
-	for {
-		line, err := buffer.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
+/*postToMaster - подписанный POST запрос мастеру с JSON телом. Отклонённая мастером подпись возвращается ошибкой*/
+func postToMaster(auth *runner_auth.RunnerAuth, address string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	auth.Sign(request, body)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	answer, _ := ioutil.ReadAll(response.Body)
+	log.Debug("response from master: ", response.Status, " ", string(answer))
+	if response.StatusCode == http.StatusUnauthorized {
+		return errors.New("master rejected request signature: " + string(answer))
+	}
+	return nil
+}
+
+func executingParallelJobPerStage(ctx context.Context, job models.Job, core *SlaveRunnerCore, workJob chan WorkJob) {
+	workJob <- core.executeJobWithRetries(ctx, job)
+}
+
+/*executeJobWithRetries - выполнение job с повторами по её политике. Логи неудачных попыток отправляются мастеру отдельно от логов последней попытки*/
+func (core *SlaveRunnerCore) executeJobWithRetries(ctx context.Context, job models.Job) WorkJob {
+	for attempt := 1; ; attempt++ {
+		result := executeJobAttempt(ctx, job, core, attempt)
+		result.Attempt = attempt
+		reason, failed := jobFailure(result)
+		if !failed || ctx.Err() != nil || !job.Retry.ShouldRetry(reason, attempt) {
+			return result
+		}
+		log.Warn("job ", job.JobName, " failed on attempt ", attempt, " by reason: ", reason, ". Job will be retried")
+		core.sendAttemptLogs(result)
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(job.Retry.Delay(attempt)):
+		}
+	}
+}
+
+/*jobFailure - причина ошибки job*/
+func jobFailure(workJob WorkJob) (string, bool) {
+	switch workJob.JobStatus {
+	case failJob:
+		return workJob.FailReason, true
+	case nonZeroExitJob:
+		return models.RetryOnNonZeroExit, true
+	case timeoutJob:
+		return models.RetryOnTimeout, true
+	default:
+		return "", false
+	}
+}
+
+/*sendAttemptLogs - отправка логов неудачной попытки мастеру как логов отдельной job (<job>_attempt<N>)*/
+func (core *SlaveRunnerCore) sendAttemptLogs(workJob WorkJob) {
+	address, errAddress := core.getAddressMaster()
+	if errAddress != nil {
+		return
+	}
+	attemptName := workJob.JobName + "_attempt" + strconv.Itoa(workJob.Attempt)
+	if errSend := sendResultLogsToMaster(core.Auth, "http://"+address+"/task/"+workJob.TaskID+"/log/"+workJob.Stage+"/"+attemptName, jobLogs(workJob, core.tasks.secrets(workJob.TaskID))); errSend != nil {
+		log.Error("can not send logs of failed attempt to master: ", errSend)
+	}
+}
+
+/*executeJobAttempt - одна попытка выполнения job. Таймаут job ограничивает и сборку образа, и выполнение контейнера*/
+func executeJobAttempt(ctx context.Context, job models.Job, core *SlaveRunnerCore, attempt int) WorkJob {
+	jobCtx := ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(ctx, time.Duration(job.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+	result := runJobAttempt(jobCtx, job, core, attempt)
+	if ctx.Err() == nil && jobCtx.Err() == context.DeadlineExceeded {
+		log.Warn("job exceeded timeout: ", job.JobName)
+		logs := result.JobResukt
+		logs.AddLine(models.LogStreamStderr, "job exceeded timeout of "+strconv.FormatInt(job.Timeout, 10)+" ms")
+		return WorkJob{
+			JobName:      job.JobName,
+			JobStatus:    timeoutJob,
+			FailReason:   models.RetryOnTimeout,
+			Stage:        job.Stage,
+			TaskID:       job.TaskID,
+			JobResukt:    logs,
+			JobMetrics:   job.Reports,
+			ExitCode:     -1,
+			AllowFailure: job.AllowFailure,
 		}
-		result = append(result, line)
 	}
 	return result
 }
 
-func executingParallelJobPerStage(job models.Job, core *SlaveRunnerCore, workJob chan WorkJob) {
+/*runJobAttempt - сборка образа, создание и запуск контейнера job*/
+func runJobAttempt(ctx context.Context, job models.Job, core *SlaveRunnerCore, attempt int) WorkJob {
+	stream := core.openLogStream(job, attempt)
+	defer stream.Close()
 	log.Debug("start preparing job: ", job.JobName)
-	logsFromBuild, imageName, err := core.prepareTask(job)
+	logsFromBuild, imageName, err := core.prepareTask(ctx, job)
 	defer core.removeImage(imageName)
+	buildLogs := models.LogsPerTask{}
+	for _, line := range logsFromBuild {
+		stream.Write(buildLogs.AddLine(models.LogStreamBuild, strings.TrimSuffix(line, "\n")))
+	}
+	if err == nil && ctx.Err() != nil {
+		err = taskContextError(ctx)
+	}
 	if err != nil {
 		log.Error("error while preparing task. ", err)
-		workJob <- WorkJob{
-			JobName:   job.JobName,
-			JobStatus: failJob,
-			Stage:     job.Stage,
-			TaskID:    job.TaskID,
-			JobResukt: models.LogsPerTask{
-				STDERR: []string{
-					err.Error(),
-				},
-			},
-			JobMetrics: job.Reports,
+		return WorkJob{
+			JobName:      job.JobName,
+			JobStatus:    failJob,
+			FailReason:   models.RetryOnBuildError,
+			Stage:        job.Stage,
+			TaskID:       job.TaskID,
+			JobResukt:    failedJobLogs(stream, buildLogs, err),
+			JobMetrics:   job.Reports,
+			ExitCode:     -1,
+			AllowFailure: job.AllowFailure,
 		}
-		return
 	}
 	log.Debug("start creating container for job: ", job.JobName)
 	containername := strings.ToLower(job.TaskID + "_" + job.JobName)
@@ -451,125 +605,89 @@ func executingParallelJobPerStage(job models.Job, core *SlaveRunnerCore, workJob
 	containerID, err := core.Docker.CreateContainer(&models.ContainerCreatePayload{
 		BaseImageName: containername,
 		ContainerName: "execute_" + containername,
+		Resources:     job.Resources.WithDefaults(core.SlaveConfig.DefaultResources(), core.SlaveConfig.ContainerPolicy()),
+		Env:           job.Env(core.tasks.secrets(job.TaskID)),
 	})
 	if err != nil {
 		log.Error("can not create container: ", err)
-		workJob <- WorkJob{
-			JobName:   job.JobName,
-			JobStatus: failJob,
-			Stage:     job.Stage,
-			TaskID:    job.TaskID,
-			JobResukt: models.LogsPerTask{
-				STDERR: []string{
-					err.Error(),
-				},
-			},
-			JobMetrics: job.Reports,
+		return WorkJob{
+			JobName:      job.JobName,
+			JobStatus:    failJob,
+			FailReason:   models.RetryOnBuildError,
+			Stage:        job.Stage,
+			TaskID:       job.TaskID,
+			JobResukt:    failedJobLogs(stream, buildLogs, err),
+			JobMetrics:   job.Reports,
+			ExitCode:     -1,
+			AllowFailure: job.AllowFailure,
 		}
-		return
 	}
 	log.Debug("running container for job")
-	responseCloser, err := core.Docker.RunContainer(containerID, job.Timeout)
+	output, exitCode, err := core.Docker.RunContainer(ctx, containerID, stream.Write)
+	output = buildLogs.Join(output)
 	if err != nil {
 		log.Error("can not run container: ", err)
-		workJob <- WorkJob{
-			JobName:   job.JobName,
-			JobStatus: failJob,
-			Stage:     job.Stage,
-			TaskID:    job.TaskID,
-			JobResukt: models.LogsPerTask{
-				STDERR: []string{
-					err.Error(),
-				},
-			},
-			JobMetrics: job.Reports,
+		return WorkJob{
+			JobName:      job.JobName,
+			JobStatus:    failJob,
+			FailReason:   models.RetryOnRuntimeError,
+			Stage:        job.Stage,
+			TaskID:       job.TaskID,
+			JobResukt:    failedJobLogs(stream, output, err),
+			JobMetrics:   job.Reports,
+			ExitCode:     -1,
+			AllowFailure: job.AllowFailure,
 		}
-		return
 	}
-	// log.Println("error while starting container: ", err)
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-	_, err = stdcopy.StdCopy(stdout, stderr, responseCloser)
-	// ADD PARSING STDOUT and STDERR
-	resultFromSTD := readSTD(stdout)
-	resultFromSTD = append(logsFromBuild, resultFromSTD...)
-	output := models.LogsPerTask{
-		STDERR: readSTD(stderr),
-		STDOUT: resultFromSTD,
-	}
-	// log.Println("output: ", output)
-	defer responseCloser.Close()
-	if err != nil {
-		workJob <- WorkJob{
-			JobName:   job.JobName,
-			JobStatus: failJob,
-			Stage:     job.Stage,
-			TaskID:    job.TaskID,
-			JobResukt: models.LogsPerTask{
-				STDERR: []string{
-					err.Error(),
-				},
-			},
-			JobMetrics: job.Reports,
-		}
-		return
+	core.collectArtefacts(ctx, job, containerID, &output, stream)
+	reportFiles := core.collectReportFiles(ctx, job, containerID, &output, stream)
+	status := executedJob
+	if !job.IsSuccessExitCode(exitCode) {
+		log.Debug("job: ", job.JobName, " exited with code: ", exitCode)
+		status = nonZeroExitJob
 	}
-	workJob <- WorkJob{
-		JobName:    job.JobName,
-		JobStatus:  executedJob,
-		Stage:      job.Stage,
-		TaskID:     job.TaskID,
-		JobResukt:  output,
-		JobMetrics: job.Reports,
+	return WorkJob{
+		JobName:      job.JobName,
+		JobStatus:    status,
+		Stage:        job.Stage,
+		TaskID:       job.TaskID,
+		JobResukt:    output,
+		JobMetrics:   job.Reports,
+		ReportFiles:  reportFiles,
+		ExitCode:     exitCode,
+		AllowFailure: job.AllowFailure,
 	}
 }
 
+/*failedJobLogs - логи job с ошибкой её выполнения в stderr*/
+func failedJobLogs(stream *jobLogStream, logs models.LogsPerTask, err error) models.LogsPerTask {
+	stream.Write(logs.AddLine(models.LogStreamStderr, err.Error()))
+	return logs
+}
+
 func (core *SlaveRunnerCore) removeImage(imageName string) {
 	log.Debug("REMOVE IMAGE: ", imageName)
 	core.Docker.RemoveImage(imageName)
 }
 
-// DEPRECATED
-func (core *SlaveRunnerCore) getRepoCandidate(job models.Job) (string, error) {
-	log.Println("start cloning repository candidate")
-	path, err := core.Git.CloneRepo(job.RepositoryCandidate)
+func (core *SlaveRunnerCore) prepareTask(ctx context.Context, job models.Job) ([]string, string, error) {
+	log.Debug("creating image for job: ", job.JobName)
+	repository, err := core.jobRepository(job)
 	if err != nil {
-		switch core.Git.GetTypeError(err) {
-		case gitmod.ErrorAuthenticate:
-			return "", err
-		case gitmod.ErrorExistingRepository:
-			return path, core.Git.RemoveRepo(path)
-		case gitmod.ErrorUnrecognized:
-			return "", err
-		}
+		return []string{}, "", err
 	}
-	return path, nil
-}
-
-func (core *SlaveRunnerCore) prepareTask(job models.Job) ([]string, string, error) {
-	// pathRepo, err := core.getRepoCandidate(job)
-	// if err != nil {
-	// 	return err
-	// }
-	log.Debug("creating image for job: ", job.JobName)
-	// log.Println("path repo: ", pathRepo)
-	// log.Println("name of docker image: ", job.TaskID+"_"+job.JobName)
-	logsFromBuildStage, err := core.Docker.CreateImageMem(job.Image,
+	dependencies, err := core.jobDependencies(job)
+	if err != nil {
+		return []string{}, "", err
+	}
+	logsFromBuildStage, err := core.Docker.CreateImageMem(ctx, job.Image,
 		job.ShellCommands,
 		[]string{strings.ToLower(job.TaskID + "_" + job.JobName)},
-		map[string]string{})
+		repository, dependencies)
 	if err != nil {
 		return []string{}, "", err
 	}
-	// if err := os.RemoveAll(pathRepo); err != nil {
-	// 	log.Warn("can not remove repo candidate. ", err)
-	// }
-	return append(logsFromBuildStage, "\n\n"), strings.ToLower(job.TaskID + "_" + job.JobName), nil
-}
-
-// DEPRECATED
-func (core *SlaveRunnerCore) appendRepoIntoDocker(path string, job models.Job) []string {
-	return append(job.Image, `COPY `+path+` /repoCandidate`)
+	return logsFromBuildStage, strings.ToLower(job.TaskID + "_" + job.JobName), nil
 }
 
 /*getJobsByStage - получение всех исполняемых job на stage */
@@ -578,16 +696,10 @@ func (core *SlaveRunnerCore) getJobsByStage(stage string, jobs map[string]models
 	log.Debug("getting all jobs for stage: ", stage)
 	for jobID, job := range jobs {
 		log.Debug("current job id: ", jobID)
-		enhanceJob := models.Job{
-			JobName:             jobID,
-			Stage:               stage,
-			TaskID:              taskID,
-			Image:               job.Image,
-			RepositoryCandidate: job.RepositoryCandidate,
-			ShellCommands:       job.ShellCommands,
-			Reports:             job.Reports,
-			Timeout:             job.Timeout,
-		}
+		enhanceJob := job
+		enhanceJob.JobName = jobID
+		enhanceJob.Stage = stage
+		enhanceJob.TaskID = taskID
 		log.Debug("stage: ", stage, " job stage: ", job.Stage)
 		if job.Stage == stage {
 			result = append(result, enhanceJob)
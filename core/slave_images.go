@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+
+	"github.com/kubitre/diplom/config"
+	"github.com/kubitre/diplom/discovery"
+	"github.com/kubitre/diplom/docker_runner"
+	log "github.com/sirupsen/logrus"
+)
+
+/*setupImageCache - включение кэша образов job с ограничением размера из конфигурации слейва*/
+func setupImageCache(docker *docker_runner.DockerExecutor, config *config.ConfigurationSlaveRunner) {
+	if config.ImageCacheMB <= 0 {
+		return
+	}
+	docker.Cache = docker_runner.NewImageCache(docker, config.ImageCacheMB*1024*1024)
+	if err := docker.Cache.Load(context.Background()); err != nil {
+		log.Warn("can not load images of cache: ", err)
+	}
+}
+
+/*pullBaseImages - скачивание базовых образов при запуске слейва. Возвращает успешно скачанные образы*/
+func pullBaseImages(docker *docker_runner.DockerExecutor, images []string) []string {
+	pulled := []string{}
+	for _, image := range images {
+		log.Info("pull base image: ", image)
+		if err := docker.PullImage(image); err != nil {
+			log.Error("can not pull base image: ", image, " by error: ", err)
+			continue
+		}
+		pulled = append(pulled, image)
+	}
+	return pulled
+}
+
+/*baseImageTags - метки consul для скачанных базовых образов. По ним задача с slave_labels выбирает слейв, на котором образ уже есть*/
+func baseImageTags(images []string) []string {
+	tags := []string{}
+	for _, image := range images {
+		tags = append(tags, discovery.TagImage+image)
+	}
+	return tags
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/runner_auth"
+	log "github.com/sirupsen/logrus"
+)
+
+/*logStreamBuffer - количество строк, которые могут ожидать отправки мастеру. Если мастер не успевает их принимать, строки пропускаются (полный лог job всё равно отправляется после её завершения)*/
+const logStreamBuffer = 1024
+
+type (
+	/*jobLogStream - пересылка строк лога выполняющейся job мастеру одним chunked запросом (по строке JSON на строку лога)*/
+	jobLogStream struct {
+		lines   chan models.LogLine
+		wait    sync.WaitGroup
+		dropped int64
+		stage   string
+		job     string
+		attempt int
+		secrets models.SecretValues // значения секретов задачи, которые маскируются в строках
+	}
+)
+
+/*openLogStream - открытие потока логов попытки job на мастер. Если мастер не найден, возвращается nil (строки не пересылаются)*/
+func (core *SlaveRunnerCore) openLogStream(job models.Job, attempt int) *jobLogStream {
+	address, errAddress := core.getAddressMaster()
+	if errAddress != nil {
+		return nil
+	}
+	stream := newJobLogStream(core.Auth, "http://"+address+"/task/"+job.TaskID+"/log/"+job.Stage+"/"+job.JobName+"/stream", job, attempt)
+	stream.secrets = core.tasks.secrets(job.TaskID)
+	return stream
+}
+
+func newJobLogStream(auth *runner_auth.RunnerAuth, address string, job models.Job, attempt int) *jobLogStream {
+	stream := &jobLogStream{
+		lines:   make(chan models.LogLine, logStreamBuffer),
+		stage:   job.Stage,
+		job:     job.JobName,
+		attempt: attempt,
+	}
+	reader, writer := io.Pipe()
+	stream.wait.Add(2)
+	go stream.encode(writer)
+	go func() {
+		defer stream.wait.Done()
+		defer reader.Close()
+		request, err := http.NewRequest(http.MethodPost, address, reader)
+		if err != nil {
+			log.Error("can not create log stream request: ", err)
+			return
+		}
+		request.Header.Set("Content-Type", "application/x-ndjson")
+		auth.SignStream(request)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			log.Error("can not stream logs to master: ", err)
+			return
+		}
+		response.Body.Close()
+	}()
+	return stream
+}
+
+/*encode - запись строк в тело запроса. После ошибки записи (мастер закрыл соединение) строки только вычитываются из канала*/
+func (stream *jobLogStream) encode(writer *io.PipeWriter) {
+	defer stream.wait.Done()
+	encoder := json.NewEncoder(writer)
+	var errWrite error
+	for line := range stream.lines {
+		if errWrite == nil {
+			errWrite = encoder.Encode(&line)
+		}
+	}
+	writer.Close()
+}
+
+/*Write - отправка строки лога. Не блокирует выполнение контейнера*/
+func (stream *jobLogStream) Write(line models.LogLine) {
+	if stream == nil {
+		return
+	}
+	line.Stage, line.Job, line.Attempt = stream.stage, stream.job, stream.attempt
+	line.Line = stream.secrets.Mask(line.Line)
+	select {
+	case stream.lines <- line:
+	default:
+		atomic.AddInt64(&stream.dropped, 1)
+	}
+}
+
+/*Close - завершение потока и ожидание отправки оставшихся строк*/
+func (stream *jobLogStream) Close() {
+	if stream == nil {
+		return
+	}
+	close(stream.lines)
+	stream.wait.Wait()
+	if dropped := atomic.LoadInt64(&stream.dropped); dropped > 0 {
+		log.Warn("log stream dropped lines: ", dropped)
+	}
+}
+
+/*jobLogs - логи job для отправки мастеру: записи дополняются этапом, именем job и номером попытки, значения секретов маскируются*/
+func jobLogs(workJob WorkJob, secrets models.SecretValues) models.LogsPerTask {
+	logs := models.LogsPerTask{Records: []models.LogLine{}}
+	for _, record := range workJob.JobResukt.LogRecords() {
+		record.Stage, record.Job, record.Attempt = workJob.Stage, workJob.JobName, workJob.Attempt
+		record.Line = secrets.Mask(record.Line)
+		logs.Records = append(logs.Records, record)
+		if record.Stream == models.LogStreamStderr {
+			logs.STDERR = append(logs.STDERR, record.Line+"\n")
+		} else {
+			logs.STDOUT = append(logs.STDOUT, record.Line+"\n")
+		}
+	}
+	return logs
+}
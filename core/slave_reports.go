@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/report_parsers"
+	log "github.com/sirupsen/logrus"
+)
+
+/*maxReportFilesSize - ограничение размера файлов одного отчёта, читаемых из контейнера в память слейва*/
+const maxReportFilesSize = 32 << 20
+
+/*collectReportFiles - чтение файлов отчётов job (junit, cobertura и т.д.) из завершённого контейнера. Ошибки не останавливают job и добавляются в её лог*/
+func (core *SlaveRunnerCore) collectReportFiles(ctx context.Context, job models.Job, containerID string, logs *models.LogsPerTask, stream *jobLogStream) map[string][][]byte {
+	result := map[string][][]byte{}
+	for name, report := range job.Reports {
+		if report.Type == models.ReportTypeRegex || report.Path == "" {
+			continue
+		}
+		if _, exist := result[report.Path]; exist {
+			continue
+		}
+		files, err := core.readReportFiles(ctx, containerID, report.Path)
+		if err != nil {
+			log.Error("can not read report: ", name, " of job: ", job.JobName, " by error: ", err)
+			stream.Write(logs.AddLine(models.LogStreamStderr, "can not read report "+name+" from "+report.Path+": "+err.Error()))
+			continue
+		}
+		result[report.Path] = files
+	}
+	return result
+}
+
+func (core *SlaveRunnerCore) readReportFiles(ctx context.Context, containerID, path string) ([][]byte, error) {
+	archive, err := core.Docker.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	return enhancer.ReadArchiveFiles(archive, maxReportFilesSize)
+}
+
+/*parseTestReports - разбор отчётов о тестах и покрытии job (в порядке имён отчётов). Отчёт без пути разбирается из логов job. Ошибка разбора сохраняется в отчёте*/
+func parseTestReports(workJob WorkJob, allLogs string) []models.TestReport {
+	names := []string{}
+	for name, report := range workJob.JobMetrics {
+		if report.Type != models.ReportTypeRegex {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	result := []models.TestReport{}
+	for _, name := range names {
+		config := workJob.JobMetrics[name]
+		contents := [][]byte{[]byte(allLogs)}
+		if config.Path != "" {
+			contents = workJob.ReportFiles[config.Path]
+		}
+		report, err := report_parsers.Parse(config.Type, contents)
+		if err == nil && config.Path != "" && len(contents) == 0 {
+			report.Error = "not found report files: " + config.Path
+		}
+		if err != nil {
+			log.Error("can not parse report: ", name, " by error: ", err)
+			report = models.TestReport{Type: config.Type, Error: err.Error()}
+		}
+		report.Name, report.Stage, report.Job = name, workJob.Stage, workJob.JobName
+		result = append(result, report)
+	}
+	return result
+}
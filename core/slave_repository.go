@@ -0,0 +1,150 @@
+package core
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/kubitre/diplom/docker_runner"
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/gitmod"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/runner_auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// repositoryStage, repositoryJob - под этими именами мастеру отправляется лог ошибки получения кода кандидата
+	repositoryStage = "checkout"
+	repositoryJob   = "repository"
+)
+
+/*checkoutRepository - получение кода кандидата задачи до выполнения её стадий: клонирование репозитория или скачивание архива, загруженного на мастер. Коммит рабочей копии отправляется мастеру вместе со статусом задачи*/
+func (core *SlaveRunnerCore) checkoutRepository(ctx context.Context, taskConfig *models.TaskConfig) error {
+	if taskConfig.Source == models.SourceArchive {
+		log.Info("start downloading source archive of task: ", taskConfig.TaskID)
+		return core.repositoryError(ctx, taskConfig.TaskID, "can not download source archive", core.downloadSource(ctx, taskConfig.TaskID))
+	}
+	repository, err := taskConfig.Repository()
+	if err != nil || repository.URL == "" {
+		return err
+	}
+	log.Info("start cloning repository candidate of task: ", taskConfig.TaskID)
+	var auth *models.RepoAuth
+	if repository.Auth.Type != "" {
+		auth = &repository.Auth
+	}
+	commit, err := core.Git.Checkout(ctx, core.taskRepositoryPath(taskConfig.TaskID), gitmod.CheckoutOptions{
+		URL:         repository.URL,
+		Ref:         repository.Ref,
+		Commit:      repository.Commit,
+		Depth:       repository.Depth,
+		Submodules:  repository.Submodules,
+		MaxSize:     core.SlaveConfig.RepoMaxSizeMB * 1024 * 1024,
+		Credentials: repoCredentials(auth, core.tasks.secrets(taskConfig.TaskID)),
+	})
+	if err != nil {
+		return core.repositoryError(ctx, taskConfig.TaskID, "can not clone repository "+repository.URL, err)
+	}
+	core.tasks.setCommit(taskConfig.TaskID, commit)
+	return nil
+}
+
+/*repositoryError - отправка мастеру лога ошибки получения кода кандидата. Отмена задачи ошибкой получения кода не считается*/
+func (core *SlaveRunnerCore) repositoryError(ctx context.Context, taskID, message string, err error) error {
+	if ctx.Err() != nil {
+		return taskContextError(ctx)
+	}
+	if err == nil {
+		return nil
+	}
+	log.Error(message, ": ", err)
+	logs := models.LogsPerTask{}
+	logs.AddLine(models.LogStreamStderr, message+": "+err.Error())
+	core.extractLogs(WorkJob{TaskID: taskID, Stage: repositoryStage, JobName: repositoryJob, Attempt: 1, JobResukt: logs})
+	return err
+}
+
+/*downloadSource - скачивание архива кода кандидата с мастера и его распаковка в директорию репозитория задачи*/
+func (core *SlaveRunnerCore) downloadSource(ctx context.Context, taskID string) error {
+	address, err := core.getAddressMaster()
+	if err != nil {
+		return err
+	}
+	path := core.taskRepositoryPath(taskID)
+	if err := fetchSourceArchive(ctx, core.Auth, "http://"+address+"/task/"+taskID+"/source", path, core.SlaveConfig.RepoMaxSizeMB*1024*1024); err != nil {
+		core.removeTaskRepository(taskID)
+		return err
+	}
+	return nil
+}
+
+/*fetchSourceArchive - подписанный запрос архива кода кандидата (tar.gz) у мастера и его распаковка в destination*/
+func fetchSourceArchive(ctx context.Context, auth *runner_auth.RunnerAuth, address, destination string, limit int64) error {
+	request, err := http.NewRequest(http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	auth.Sign(request, nil)
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+		return errors.New("master rejected source archive request: " + response.Status + " " + string(body))
+	}
+	archive, err := gzip.NewReader(response.Body)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	if err := os.MkdirAll(destination, os.ModePerm); err != nil {
+		return err
+	}
+	_, err = enhancer.ExtractArchive(archive, destination, limit)
+	return err
+}
+
+/*jobRepository - репозиторий кандидата для копирования в образ job (путь на слейве: путь в образе)*/
+func (core *SlaveRunnerCore) jobRepository(job models.Job) (map[string]string, error) {
+	if !job.UsesRepoCandidate() {
+		return map[string]string{}, nil
+	}
+	path := core.taskRepositoryPath(job.TaskID)
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.New("job " + job.JobName + " uses " + models.RepoCandidateAnnotation + ", but task has no cloned repository")
+	}
+	return map[string]string{path: docker_runner.RepoCandidatePath}, nil
+}
+
+func (core *SlaveRunnerCore) taskRepositoryPath(taskID string) string {
+	return core.SlaveConfig.PathToRepositories + "/" + taskID
+}
+
+/*removeTaskRepository - удаление склонированного репозитория задачи*/
+func (core *SlaveRunnerCore) removeTaskRepository(taskID string) {
+	if err := os.RemoveAll(core.taskRepositoryPath(taskID)); err != nil {
+		log.Warn("can not remove repository of task: ", taskID, " by error: ", err)
+	}
+}
+
+/*repoCredentials - доступ к репозиторию кандидата из секретов задачи*/
+func repoCredentials(auth *models.RepoAuth, secrets models.SecretValues) *gitmod.Credentials {
+	if auth == nil {
+		return nil
+	}
+	credentials := &gitmod.Credentials{Username: auth.Username}
+	if auth.Type == models.RepoAuthSSH {
+		credentials.PrivateKey = secrets[auth.Secret]
+		credentials.KnownHosts = secrets[auth.KnownHosts]
+	} else {
+		credentials.Token = secrets[auth.Secret]
+	}
+	return credentials
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	/*taskRegistry - реестр задач слейва, которые ожидают выполнения или выполняются, с возможностью их отмены*/
+	taskRegistry struct {
+		mutex sync.Mutex
+		tasks map[string]registeredTask
+	}
+
+	registeredTask struct {
+		ctx     context.Context
+		cancel  context.CancelFunc
+		secrets models.SecretValues // значения секретов job задачи, удаляются вместе с задачей
+		commit  string              // SHA склонированного репозитория кандидата
+	}
+)
+
+var (
+	errTaskCanceled = errors.New("task was canceled")
+	errTaskTimeout  = errors.New("task exceeded its timeout")
+)
+
+func newTaskRegistry() *taskRegistry {
+	return &taskRegistry{
+		tasks: map[string]registeredTask{},
+	}
+}
+
+/*context - получение контекста задачи (регистрирует задачу, если её ещё нет в реестре)*/
+func (registry *taskRegistry) context(taskID string) context.Context {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if task, ok := registry.tasks[taskID]; ok {
+		return task.ctx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	registry.tasks[taskID] = registeredTask{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	return ctx
+}
+
+/*register - регистрация задачи вместе со значениями её секретов*/
+func (registry *taskRegistry) register(taskID string, secrets models.SecretValues) {
+	registry.context(taskID)
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	task := registry.tasks[taskID]
+	task.secrets = secrets
+	registry.tasks[taskID] = task
+}
+
+/*secrets - значения секретов задачи*/
+func (registry *taskRegistry) secrets(taskID string) models.SecretValues {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return registry.tasks[taskID].secrets
+}
+
+/*setCommit - коммит склонированного репозитория задачи*/
+func (registry *taskRegistry) setCommit(taskID, commit string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if task, ok := registry.tasks[taskID]; ok {
+		task.commit = commit
+		registry.tasks[taskID] = task
+	}
+}
+
+/*commit - коммит репозитория задачи. Пусто - репозиторий не клонировался*/
+func (registry *taskRegistry) commit(taskID string) string {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return registry.tasks[taskID].commit
+}
+
+/*cancel - отмена задачи по её идентификатору*/
+func (registry *taskRegistry) cancel(taskID string) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	task, ok := registry.tasks[taskID]
+	if !ok {
+		return errors.New("can not cancel undefined task: " + taskID)
+	}
+	task.cancel()
+	return nil
+}
+
+/*release - удаление задачи из реестра после её завершения*/
+func (registry *taskRegistry) release(taskID string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if task, ok := registry.tasks[taskID]; ok {
+		task.cancel()
+		delete(registry.tasks, taskID)
+	}
+}
+
+/*taskContext - контекст выполнения задачи, который отменяется при отмене задачи или истечении её таймаута*/
+func (core *SlaveRunnerCore) taskContext(task *models.TaskConfig) (context.Context, context.CancelFunc) {
+	ctx := core.tasks.context(task.TaskID)
+	if task.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(task.Timeout)*time.Millisecond)
+}
+
+/*taskContextError - причина остановки задачи по её контексту*/
+func taskContextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTaskTimeout
+	}
+	return errTaskCanceled
+}
+
+/*AddTask - регистрация новой задачи и передача её в пул воркеров. Значения секретов остаются только в реестре задач*/
+func (core *SlaveRunnerCore) AddTask(task models.TaskConfig) {
+	core.tasks.register(task.TaskID, task.SecretValues)
+	task.SecretValues = nil
+	core.WorkerPull <- task
+}
+
+/*CancelTask - отмена задачи, которая ожидает выполнения или уже выполняется воркером*/
+func (core *SlaveRunnerCore) CancelTask(taskID string) error {
+	log.Info("start canceling task: ", taskID)
+	return core.tasks.cancel(taskID)
+}
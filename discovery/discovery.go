@@ -17,6 +17,8 @@ const (
 	MasterPattern = "master-executor#"
 	TagSlave      = "slave"
 	TagMaster     = "master"
+	// TagImage - префикс меток слейва с базовыми образами, скачанными при его запуске (image:golang:1.14)
+	TagImage = "image:"
 )
 
 type Discovery struct {
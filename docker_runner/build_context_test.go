@@ -0,0 +1,104 @@
+package docker_runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+)
+
+/*fakeBuildServer - докер API, который вместо сборки возвращает в логе сборки скрипт выполнения из полученного контекста*/
+func fakeBuildServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !strings.HasSuffix(request.URL.Path, "/build") {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gzipReader, err := gzip.NewReader(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		files := map[string]string{}
+		reader := tar.NewReader(gzipReader)
+		for {
+			header, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writer.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content, _ := ioutil.ReadAll(reader)
+			files[header.Name] = string(content)
+		}
+		dockerFile, ok := files[request.URL.Query().Get("dockerfile")]
+		if !ok || !strings.Contains(dockerFile, "COPY "+buildContextPath+"/"+entryScript) {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(writer).Encode(map[string]string{"stream": files[buildContextPath+"/"+entryScript]})
+	}))
+}
+
+func Test_CreateImageMemConcurrently(t *testing.T) {
+	temporary, err := ioutil.TempDir("", "builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(temporary)
+	previous := os.Getenv("TMPDIR")
+	os.Setenv("TMPDIR", temporary)
+	defer os.Setenv("TMPDIR", previous)
+
+	server := fakeBuildServer()
+	defer server.Close()
+	dockerClient, err := client.NewClient(server.URL, "1.38", server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerExecutor := &DockerExecutor{DockerClient: dockerClient}
+
+	const builds = 32
+	logs := make([][]string, builds)
+	errs := make([]error, builds)
+	start := make(chan struct{})
+	wait := sync.WaitGroup{}
+	for index := 0; index < builds; index++ {
+		wait.Add(1)
+		go func(index int) {
+			defer wait.Done()
+			<-start
+			logs[index], errs[index] = dockerExecutor.CreateImageMem(context.Background(), []string{"FROM alpine"},
+				[]string{"echo job-" + strconv.Itoa(index) + "-done"}, []string{"job" + strconv.Itoa(index)}, map[string]string{}, map[string]string{})
+		}(index)
+	}
+	close(start)
+	wait.Wait()
+
+	for index := 0; index < builds; index++ {
+		assert.Nil(t, errs[index])
+		assert.Equal(t, 1, len(logs[index]))
+		for other := 0; other < builds; other++ {
+			assert.Equal(t, other == index, strings.Contains(strings.Join(logs[index], ""), "job-"+strconv.Itoa(other)+"-done"))
+		}
+	}
+	// временные директории сборок удалены
+	left, _ := ioutil.ReadDir(temporary)
+	assert.Empty(t, left)
+	_, err = os.Stat(buildContextPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,10 +1,9 @@
 package docker_runner
 
 import (
-	"bytes"
+	"context"
 	"testing"
 
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/kubitre/diplom/models"
 )
 
@@ -26,16 +25,16 @@ func Test_createDefaultContainer(t *testing.T) {
 		t.Error("can not create container. Error: ", err.Error())
 	}
 
-	respCloser, errStart := dockerExecutor.RunContainer(containerID)
+	streamed := 0
+	output, exitCode, errStart := dockerExecutor.RunContainer(context.Background(), containerID, func(line models.LogLine) {
+		streamed++
+	})
 	if errStart != nil {
 		t.Error("can not start container: ", errStart)
 		return
 	}
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-	writer, err := stdcopy.StdCopy(stdout, stderr, respCloser)
-	if err != nil {
-		panic(err)
+	if streamed != len(output.STDOUT)+len(output.STDERR) {
+		t.Error("streamed lines: ", streamed, " collected: ", len(output.STDOUT)+len(output.STDERR))
 	}
-	t.Log("logs from container: ", writer)
+	t.Log("logs from container: ", output, " exit code: ", exitCode)
 }
@@ -1,6 +1,7 @@
 package docker_runner
 
 import (
+	"context"
 	"testing"
 
 	"github.com/kubitre/diplom/gitmod"
@@ -25,11 +26,11 @@ func Test_CreateImageDockerByMemfile(t *testing.T) {
 	}
 	log.SetLevel(log.DebugLevel)
 	gy := gitmod.Git{}
-	repoPath, err := gy.CloneRepo("https://github.com/kubitre/for_diplom")
+	repoPath, err := gy.CloneRepo("https://github.com/kubitre/for_diplom", nil)
 	if err != nil {
 		t.Error(err)
 	}
-	if err := dockerExecutor.CreateImageMem([]string{
+	if _, err := dockerExecutor.CreateImageMem(context.Background(), []string{
 		"FROM golang:1.14.2-alpine3.11",
 		"RUN apk update && apk add bash",
 		"{{repoCandidate}}",
@@ -39,7 +40,7 @@ func Test_CreateImageDockerByMemfile(t *testing.T) {
 		`./service`,
 	}, []string{"test_candidate_2"}, map[string]string{
 		repoPath: "repoCandidate",
-	}); err != nil {
+	}, map[string]string{}); err != nil {
 		t.Error("can not create image by dockerfile. Error: ", err.Error())
 	}
 	if err := gy.RemoveRepo(repoPath); err != nil {
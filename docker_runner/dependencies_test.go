@@ -0,0 +1,67 @@
+package docker_runner
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PrepareDockerEnvWithDependencies(t *testing.T) {
+	artefacts, err := ioutil.TempDir("", "artefacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(artefacts)
+	os.MkdirAll(artefacts+"/build", os.ModePerm)
+	ioutil.WriteFile(artefacts+"/build/service", []byte("binary"), 0644)
+
+	buildDir, err := ioutil.TempDir("", buildDirPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+	dockerExecutor := &DockerExecutor{}
+	assert.Nil(t, dockerExecutor.PrepareDockerEnv(buildDir, map[string]string{}, map[string]string{
+		artefacts + "/build": "/artefacts/build",
+	}, []string{"FROM alpine"}, []string{"/artefacts/build/service"}))
+
+	dockerFile, _ := ioutil.ReadFile(buildDir + "/" + buildContextPath + "/" + dockerFileMemName)
+	lines := strings.Split(string(dockerFile), "\n")
+	assert.Equal(t, "COPY "+buildContextPath+"/"+dependenciesPath+"/0 /artefacts/build", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "COPY "+buildContextPath+"/"+entryScript))
+	content, _ := ioutil.ReadFile(buildDir + "/" + buildContextPath + "/" + dependenciesPath + "/0/service")
+	assert.Equal(t, "binary", string(content))
+
+	assert.NotNil(t, dockerExecutor.PrepareDockerEnv(buildDir, map[string]string{}, map[string]string{
+		artefacts + "/missing": "/artefacts/missing",
+	}, []string{"FROM alpine"}, []string{}))
+}
+
+func Test_PrepareDockerEnvWithRepoCandidate(t *testing.T) {
+	repository, err := ioutil.TempDir("", "repository")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repository)
+	ioutil.WriteFile(repository+"/main.go", []byte("package main"), 0644)
+
+	buildDir, err := ioutil.TempDir("", buildDirPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+	dockerExecutor := &DockerExecutor{}
+	assert.Nil(t, dockerExecutor.PrepareDockerEnv(buildDir, map[string]string{repository: RepoCandidatePath}, map[string]string{},
+		[]string{"FROM golang", "{{repoCandidate}}", "{{workdir repoCandidate}}"}, []string{}))
+
+	dockerFile, _ := ioutil.ReadFile(buildDir + "/" + buildContextPath + "/" + dockerFileMemName)
+	lines := strings.Split(string(dockerFile), "\n")
+	lastPart, _ := dockerExecutor.getFinalNamePath(repository)
+	assert.Equal(t, "COPY "+buildContextPath+"/"+lastPart+" "+RepoCandidatePath, lines[1])
+	assert.Equal(t, "WORKDIR "+RepoCandidatePath, lines[2])
+	content, _ := ioutil.ReadFile(buildDir + "/" + buildContextPath + "/" + lastPart + "/main.go")
+	assert.Equal(t, "package main", string(content))
+}
@@ -6,19 +6,22 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/client"
-	"github.com/docker/go-connections/nat"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/kubitre/diplom/models"
 	"github.com/kubitre/diplom/tools"
 	log "github.com/sirupsen/logrus"
@@ -29,15 +32,24 @@ type (
 	DockerExecutor struct {
 		Status       chan bool // syncronization for executor
 		DockerClient *client.Client
+		Cache        *ImageCache // nil - кэш образов выключен
 	}
 )
 
 const (
-	buildContextPath  = "dockerBuildContext"
+	buildContextPath  = "dockerBuildContext" // директория контекста сборки внутри временной директории сборки
+	buildDirPrefix    = "build"
 	dockerFileMemName = "Dockerfile"
 	entryScript       = "entry.bash"
+	dependenciesPath  = "dependencies" // артефакты других job внутри контекста сборки
+
+	// RepoCandidatePath - директория репозитория кандидата в образе job
+	RepoCandidatePath = "/repoCandidate"
 )
 
+// ErrContainerTimeout - контейнер или сборка образа не завершились за отведённое время
+var ErrContainerTimeout = errors.New("container was stopped by timeout")
+
 // NewDockerExecutor - создание нового докер исполнителя
 func NewDockerExecutor() (*DockerExecutor, error) {
 	cli, err := client.NewEnvClient()
@@ -51,7 +63,7 @@ func NewDockerExecutor() (*DockerExecutor, error) {
 	}, nil
 }
 
-// PullImage - пуллинг публичных образов
+// PullImage - пуллинг публичных образов. Образ скачан, когда прочитан весь ответ докера
 func (docker *DockerExecutor) PullImage(image string) error {
 	ctx := context.Background()
 	respPulling, errPulling := docker.DockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
@@ -59,99 +71,187 @@ func (docker *DockerExecutor) PullImage(image string) error {
 		log.Error("Can npt pulling image. Error: ", errPulling.Error())
 		return errPulling
 	}
-	// io.Copy(os.Stdout, respPulling)
-	log.Debug("response from pulling image: ", respPulling)
+	defer respPulling.Close()
+	progress := docker.readLogsFromBodyCloser(respPulling)
+	log.Debug("response from pulling image: ", progress)
+	if message := streamError(progress); message != "" {
+		return errors.New("can not pull image " + image + ": " + message)
+	}
 	return nil
 }
 
+/*streamError - ошибка из потока JSON-сообщений докера (pull, build). Пусто - ошибок нет*/
+func streamError(lines []string) string {
+	for _, line := range lines {
+		var message struct {
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if json.Unmarshal([]byte(line), &message) != nil {
+			continue
+		}
+		if message.ErrorDetail.Message != "" {
+			return message.ErrorDetail.Message
+		}
+		if message.Error != "" {
+			return message.Error
+		}
+	}
+	return ""
+}
+
 // CreateContainer - function for creating new container with docker file such as json
 func (docker *DockerExecutor) CreateContainer(payload *models.ContainerCreatePayload) (string, error) {
 	ctx := context.Background()
-
-	hostBinding := nat.PortBinding{
-		HostIP:   "0.0.0.0",
-		HostPort: "8000",
-	}
-	containerPort, err := nat.NewPort("tcp", "80")
-	if err != nil {
-		panic("Unable to get the port")
-	}
-	portBinding := nat.PortMap{containerPort: []nat.PortBinding{hostBinding}}
 	repsCreating, err := docker.DockerClient.ContainerCreate(ctx, &container.Config{
 		Image: payload.BaseImageName,
-	}, &container.HostConfig{
-		AutoRemove:   false,
-		PortBindings: portBinding,
-	}, nil, payload.ContainerName)
+		User:  payload.Resources.User,
+		Env:   payload.Env,
+	}, hostConfig(payload.Resources), nil, payload.ContainerName)
 	if err != nil {
 		log.Error("can not create container with default configuration. Error: ", err.Error())
-		return "", docker.RemoveContainer(payload.ContainerName)
+		docker.RemoveContainer(payload.ContainerName)
+		return "", err
 	}
 	log.Info("success create container. Output oprts: ", repsCreating)
 	return repsCreating.ID, nil
 }
 
-/*RunContainer - запуск контейнера*/
-func (docker *DockerExecutor) RunContainer(containerID string, timeout int64) (io.ReadCloser, error) {
-	log.Debug("I'm here. Enter timeout: ", timeout)
-	resTimeout := int64(50000)
-	if timeout > 0 {
-		resTimeout = timeout
-	}
-	log.Debug("Run container for amount ms: ", resTimeout, " ContainerID: ", containerID)
-	ctx := context.Background()
-	if errStart := docker.DockerClient.ContainerStart(ctx, containerID, types.ContainerStartOptions{}); errStart != nil {
-		return nil, errStart
-	}
+/*hostConfig - ограничения и изоляция контейнера. Порты контейнера не публикуются на хост, чтобы параллельные job не конфликтовали*/
+func hostConfig(resources models.ContainerResources) *container.HostConfig {
+	config := &container.HostConfig{
+		AutoRemove:  false,
+		NetworkMode: container.NetworkMode(resources.Network),
+		CapDrop:     strslice.StrSlice(resources.CapDrop),
+		SecurityOpt: []string{"no-new-privileges"},
+		Resources: container.Resources{
+			NanoCPUs:  int64(resources.CPUs * 1e9),
+			Memory:    resources.Memory * 1024 * 1024,
+			PidsLimit: resources.Pids,
+		},
+	}
+	// без swap контейнер не может обойти ограничение памяти
+	config.Resources.MemorySwap = config.Resources.Memory
+	if resources.Disk > 0 {
+		config.StorageOpt = map[string]string{"size": strconv.FormatInt(resources.Disk, 10) + "M"}
+	}
+	if resources.ReadOnlyRootfs != nil && *resources.ReadOnlyRootfs {
+		config.ReadonlyRootfs = true
+		config.Tmpfs = map[string]string{"/tmp": "rw,exec,size=64m"}
+	}
+	return config
+}
+
+/*RunContainer - запуск контейнера и ожидание его завершения. Строки логов передаются в onLine по мере их появления, а также возвращаются вместе с кодом завершения контейнера. При отмене ctx или истечении его времени (ErrContainerTimeout) контейнер останавливается и удаляется*/
+func (docker *DockerExecutor) RunContainer(ctx context.Context, containerID string, onLine LineHandler) (models.LogsPerTask, int64, error) {
+	log.Debug("Run container: ", containerID)
+	// ожидание подписывается до старта, чтобы не пропустить завершение быстрого контейнера
 	statusCH, errCh := docker.DockerClient.ContainerWait(ctx, containerID, container.WaitConditionNextExit)
+	if errStart := docker.DockerClient.ContainerStart(ctx, containerID, types.ContainerStartOptions{}); errStart != nil {
+		if ctx.Err() != nil {
+			return models.LogsPerTask{}, -1, contextError(ctx)
+		}
+		return models.LogsPerTask{}, -1, errStart
+	}
+	logs, errLogs := docker.DockerClient.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if errLogs != nil {
+		log.Error("can not reading docker container logs: ", errLogs)
+		docker.StopContainer(containerID)
+		if ctx.Err() != nil {
+			return models.LogsPerTask{}, -1, contextError(ctx)
+		}
+		return models.LogsPerTask{}, -1, errLogs
+	}
+	collector := newLogCollector(onLine)
+	stdout := collector.writer(models.LogStreamStdout)
+	stderr := collector.writer(models.LogStreamStderr)
+	copied := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, logs)
+		copied <- err
+	}()
+	output := func() models.LogsPerTask {
+		stdout.flush()
+		stderr.flush()
+		return collector.logs
+	}
+	stop := func() (models.LogsPerTask, int64, error) {
+		log.Info("container was stopped: ", containerID, " reason: ", ctx.Err())
+		docker.StopContainer(containerID)
+		logs.Close()
+		<-copied
+		return output(), -1, contextError(ctx)
+	}
+	var exitCode int64
 	select {
 	case err := <-errCh:
+		if ctx.Err() != nil {
+			return stop()
+		}
 		if err != nil {
-			return nil, err
+			logs.Close()
+			<-copied
+			return output(), -1, err
+		}
+	case <-ctx.Done():
+		return stop()
+	case status := <-statusCH:
+		if status.Error != nil {
+			logs.Close()
+			<-copied
+			return output(), -1, errors.New(status.Error.Message)
 		}
-	case <-statusCH:
-		// case <-time.After(time.Millisecond * time.Duration(resTimeout)):
-		// 	log.Error("container can not return reposne for timeout")
-		// 	log.Debug("stop container")
-		// 	return nil, errors.New("timeout for starting container")
-	}
-	log.Info("container start: ", containerID)
-	result := make(chan io.ReadCloser, 1)
-	err := make(chan error, 1)
-	go func(containerID string, resultClose chan io.ReadCloser, resultError chan error) {
-		response, err := docker.DockerClient.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
-			ShowStdout: true,
-			ShowStderr: true,
-			Follow:     true,
-		})
+		exitCode = status.StatusCode
+	}
+	log.Info("container finished: ", containerID, " exit code: ", exitCode)
+	// после завершения контейнера логи дочитываются до конца
+	select {
+	case err := <-copied:
+		logs.Close()
 		if err != nil {
-			log.Error("can not reading docker container logs: ", err)
-			// return nil, err
-			resultError <- err
-			return
+			log.Error("something error while handling logs in container: ", err)
+			return output(), -1, err
 		}
-		resultClose <- response
+	case <-ctx.Done():
+		return stop()
+	}
+	return output(), exitCode, nil
+}
 
-	}(containerID, result, err)
+/*contextError - ErrContainerTimeout при истечении времени ctx, иначе ошибка отмены ctx*/
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrContainerTimeout
+	}
+	return ctx.Err()
+}
 
-	select {
-	case res := <-result:
-		log.Debug("completed reading logs from container")
-		return res, nil
-	case err := <-err:
-		log.Error("something error while handling logs in container: ", err)
+/*StopContainer - остановка и принудительное удаление контейнера*/
+func (docker *DockerExecutor) StopContainer(containerID string) error {
+	ctx := context.Background()
+	if errStop := docker.DockerClient.ContainerStop(ctx, containerID, nil); errStop != nil {
+		log.Error("can not stoped container: ", errStop)
+	}
+	if errRemove := docker.DockerClient.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true}); errRemove != nil {
+		log.Error("can not remove container: ", errRemove)
+		return errRemove
+	}
+	return nil
+}
+
+/*CopyFromContainer - tar архив файла или директории path из контейнера (контейнер может быть уже завершён)*/
+func (docker *DockerExecutor) CopyFromContainer(ctx context.Context, containerID, path string) (io.ReadCloser, error) {
+	content, _, err := docker.DockerClient.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
 		return nil, err
-	case <-time.After(time.Millisecond * time.Duration(resTimeout)):
-		log.Error("container can not return reposne for timeout")
-		log.Debug("stop container")
-		if errStop := docker.DockerClient.ContainerStop(ctx, containerID, nil); errStop != nil {
-			log.Error("can not stoped container: ", errStop)
-		}
-		if errRemoveContainer := docker.RemoveContainer(containerID); errRemoveContainer != nil {
-			log.Error("can not remove container: ", errRemoveContainer)
-		}
-		return nil, errors.New("container not answered for timeout")
 	}
+	return content, nil
 }
 
 func (docker *DockerExecutor) preparingBytesFromDockerfile(dockerFile []string) []byte {
@@ -190,20 +290,25 @@ func (docker *DockerExecutor) getFinalNamePath(path string) (string, error) {
 	return fileParts[len(fileParts)-1], nil
 }
 
-/*PrepareDockerEnv - подготовка докер файла для его сборки
+/*PrepareDockerEnv - подготовка докер файла для его сборки в директории buildDir. У каждой сборки своя buildDir, поэтому параллельные сборки не перезаписывают файлы друг друга. dependencies - директории слейва, которые копируются в образ (путь на слейве: путь в образе)
  */
-func (docker *DockerExecutor) PrepareDockerEnv(neededPath map[string]string, dockerFile, shell []string) error {
+func (docker *DockerExecutor) PrepareDockerEnv(buildDir string, neededPath, dependencies map[string]string, dockerFile, shell []string) error {
 	fromDockerfile := neededPath
 	dockerFile = docker.getPathNeededToCopyInContext(dockerFile, &fromDockerfile)
 	log.Println("DockerFile: ", dockerFile)
-	dockerF2, err := docker.preparingContext(fromDockerfile, dockerFile, true)
+	dockerF2, err := docker.preparingContext(buildDir, fromDockerfile, dockerFile, true)
 	if err != nil {
 		log.Error("can not preparing context from neededpath: ", err)
 		return err
 	}
-	os.Mkdir(buildContextPath, 0777)
+	os.Mkdir(buildDir+"/"+buildContextPath, 0777)
+	dockerF2, err = docker.copyDependencies(buildDir, dependencies, dockerF2)
+	if err != nil {
+		log.Error("can not copy dependencies into build context: ", err)
+		return err
+	}
 	if len(shell) > 0 {
-		if err := docker.prepareExecutingScript(shell); err != nil {
+		if err := docker.prepareExecutingScript(buildDir, shell); err != nil {
 			log.Error("can not create executing script. ", err)
 			return err
 		}
@@ -213,14 +318,31 @@ func (docker *DockerExecutor) PrepareDockerEnv(neededPath map[string]string, doc
 		log.Info("result dockerfile: ", dockerF2)
 	}
 
-	if err := docker.writeDockerfile(buildContextPath+"/"+dockerFileMemName, docker.preparingBytesFromDockerfile(dockerF2)); err != nil {
+	if err := docker.writeDockerfile(buildDir+"/"+buildContextPath+"/"+dockerFileMemName, docker.preparingBytesFromDockerfile(dockerF2)); err != nil {
 		log.Error("can not write dockerfile in buildcontext path. ", err)
 		return err
 	}
 	return nil
 }
 
-func (docker *DockerExecutor) preparingContext(neededPath map[string]string, dockerFile []string, fromDockerfile bool) ([]string, error) {
+/*copyDependencies - копирование директорий в контекст сборки и добавление их в образ. Порядок инструкций не зависит от порядка обхода map, чтобы не сбрасывать кэш слоёв*/
+func (docker *DockerExecutor) copyDependencies(buildDir string, dependencies map[string]string, dockerFile []string) ([]string, error) {
+	sources := []string{}
+	for source := range dependencies {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for index, source := range sources {
+		contextPath := buildContextPath + "/" + dependenciesPath + "/" + strconv.Itoa(index)
+		if err := docker.copyDir(source, buildDir+"/"+contextPath); err != nil {
+			return nil, err
+		}
+		dockerFile = append(dockerFile, "COPY "+contextPath+" "+dependencies[source])
+	}
+	return dockerFile, nil
+}
+
+func (docker *DockerExecutor) preparingContext(buildDir string, neededPath map[string]string, dockerFile []string, fromDockerfile bool) ([]string, error) {
 	dockerf := dockerFile
 	for key, val := range neededPath {
 		log.Println("key: ", key, " value: ", val)
@@ -229,10 +351,11 @@ func (docker *DockerExecutor) preparingContext(neededPath map[string]string, doc
 		if err != nil {
 			log.Error("can not copy value. ", err)
 		}
-		if err := docker.copyDir(key, buildContextPath+"/"+lastPart); err != nil {
+		if err := docker.copyDir(key, buildDir+"/"+buildContextPath+"/"+lastPart); err != nil {
 			return nil, err
 		}
-		if fromDockerfile {
+		// аннотация заменяется только репозиторием кандидата, а не путями из COPY инструкций образа
+		if fromDockerfile && val == RepoCandidatePath {
 			dockerf = docker.findAnnotationRepoCandidate(dockerf, "COPY "+buildContextPath+"/"+lastPart+" "+val)
 		}
 	}
@@ -243,10 +366,10 @@ func (docker *DockerExecutor) preparingContext(neededPath map[string]string, doc
 func (docker *DockerExecutor) findAnnotationRepoCandidate(dockerFile []string, repoCandidate string) []string {
 	for index, value := range dockerFile {
 		switch value {
-		case "{{repoCandidate}}":
+		case models.RepoCandidateAnnotation:
 			dockerFile[index] = repoCandidate
 		case "{{workdir repoCandidate}}":
-			dockerFile[index] = "WORKDIR repoCandidate"
+			dockerFile[index] = "WORKDIR " + RepoCandidatePath
 		}
 	}
 	return dockerFile
@@ -314,13 +437,13 @@ func (docker *DockerExecutor) copyDir(src string, dst string) (err error) {
 	return
 }
 
-func (docker *DockerExecutor) prepareExecutingScript(shell []string) error {
+func (docker *DockerExecutor) prepareExecutingScript(buildDir string, shell []string) error {
 	result, err := tools.CreateExecutingScript(shell)
 	if err != nil {
 		return err
 	}
 
-	if err := ioutil.WriteFile("./"+buildContextPath+"/"+entryScript, result, 0777); err != nil {
+	if err := ioutil.WriteFile(buildDir+"/"+buildContextPath+"/"+entryScript, result, 0777); err != nil {
 		return err
 	}
 	return nil
@@ -366,7 +489,7 @@ func (docker *DockerExecutor) copyFile(src, dst string) (err error) {
 }
 
 func (docker *DockerExecutor) tar(src string) (*bytes.Buffer, error) {
-	buff, err := docker.compressDir(buildContextPath)
+	buff, err := docker.compressDir(src)
 	if err != nil {
 		return nil, err
 	}
@@ -374,18 +497,29 @@ func (docker *DockerExecutor) tar(src string) (*bytes.Buffer, error) {
 	return buff, nil
 }
 
+/*compressDir - tar.gz архив содержимого директории path. Пути в архиве указываются относительно path*/
 func (docker *DockerExecutor) compressDir(path string) (*bytes.Buffer, error) {
 	buf := new(bytes.Buffer)
 	zr := gzip.NewWriter(buf)
 	tw := tar.NewWriter(zr)
 
 	if err1 := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err2 := filepath.Rel(path, file)
+		if err2 != nil {
+			return err2
+		}
+		if name == "." {
+			return nil
+		}
 		header, err2 := tar.FileInfoHeader(fi, file)
 		if err2 != nil {
 			return err2
 		}
 
-		header.Name = filepath.ToSlash(file)
+		header.Name = filepath.ToSlash(name)
 
 		if err2 := tw.WriteHeader(header); err2 != nil {
 			return err2
@@ -395,6 +529,7 @@ func (docker *DockerExecutor) compressDir(path string) (*bytes.Buffer, error) {
 			if err2 != nil {
 				return err2
 			}
+			defer data.Close()
 			if _, err2 := io.Copy(tw, data); err2 != nil {
 				return err2
 			}
@@ -410,51 +545,77 @@ func (docker *DockerExecutor) compressDir(path string) (*bytes.Buffer, error) {
 	if err := zr.Close(); err != nil {
 		return nil, err
 	}
-	//
-	log.Info("buffer: ", buf)
 	return buf, nil
 }
 
-/*CreateImageMem - создание образа по заданному dockerfile с заданными инструкциями для выполнения + пометка образа списком тэгов
+/*CreateImageMem - создание образа по заданному dockerfile с заданными инструкциями для выполнения + пометка образа списком тэгов. dependencies копируются в образ перед скриптом выполнения. Начало dockerfile без файлов контекста берётся из кэша образов, если он включён. Отмена ctx прерывает сборку
  */
-func (docker *DockerExecutor) CreateImageMem(dockerFile, shell, tags []string, neededPath map[string]string) ([]string, error) {
-	ctx := context.Background()
-	err := docker.PrepareDockerEnv(neededPath, dockerFile, shell)
+func (docker *DockerExecutor) CreateImageMem(ctx context.Context, dockerFile, shell, tags []string, neededPath, dependencies map[string]string) ([]string, error) {
+	cacheLogs := []string{}
+	if docker.Cache != nil {
+		cached, logs, release, err := docker.Cache.Acquire(ctx, dockerFile)
+		if err != nil {
+			log.Error("can not prepare cached image. ", err)
+			return logs, err
+		}
+		defer release()
+		dockerFile, cacheLogs = cached, logs
+	}
+	logs, err := docker.buildImage(ctx, dockerFile, shell, tags, neededPath, dependencies, nil)
+	return append(cacheLogs, logs...), err
+}
+
+/*buildImage - сборка образа. Контекст сборки готовится во временной директории, которая удаляется после отправки контекста докеру*/
+func (docker *DockerExecutor) buildImage(ctx context.Context, dockerFile, shell, tags []string, neededPath, dependencies, labels map[string]string) ([]string, error) {
+	buildDir, err := ioutil.TempDir("", buildDirPrefix)
 	if err != nil {
+		log.Error("can not create build directory. ", err)
+		return nil, err
+	}
+	defer os.RemoveAll(buildDir)
+	if err := docker.PrepareDockerEnv(buildDir, neededPath, dependencies, dockerFile, shell); err != nil {
 		log.Error("can not readed bytes from fs. " + err.Error())
-		os.RemoveAll(buildContextPath)
 		return nil, err
 	}
 
-	resultBuffer, errCreate := docker.tar(buildContextPath)
+	resultBuffer, errCreate := docker.tar(buildDir)
 	if errCreate != nil {
 		log.Error("can not create tar for build context. ", errCreate)
-		os.RemoveAll(buildContextPath)
-		return nil, err
+		return nil, errCreate
 	}
 
 	dockerFileTar := bytes.NewReader(resultBuffer.Bytes())
 	buildOptions := types.ImageBuildOptions{
 
-		Context:    dockerFileTar,
-		Dockerfile: buildContextPath + "/" + dockerFileMemName,
-		Tags:       tags,
+		Context:     dockerFileTar,
+		Dockerfile:  buildContextPath + "/" + dockerFileMemName,
+		Tags:        tags,
+		Labels:      labels,
+		Remove:      true, // промежуточные контейнеры сборки не остаются на слейве
+		ForceRemove: true,
 	}
 	log.Debug("TAGS FOR CREATING IMAGE: ", tags)
 	resp, err := docker.DockerClient.ImageBuild(ctx, dockerFileTar, buildOptions)
 	if err != nil {
 		log.Error("error while build image by dockerfile. Error: ", err.Error())
-		os.RemoveAll(buildContextPath)
 		return nil, err
 	}
 	log.Debug("response from building image: ", resp)
+	defer resp.Body.Close()
 	// termFd, isTerm := term.GetFdInfo(os.Stderr)
 	// if err1 := jsonmessage.DisplayJSONMessagesStream(resp.Body, os.Stderr, termFd, isTerm, nil); err1 != nil {
 	// 	return err1
 	// }
-	os.RemoveAll(buildContextPath)
+	os.RemoveAll(buildDir)
 
-	return docker.readLogsFromBodyCloser(resp.Body), nil
+	logs := docker.readLogsFromBodyCloser(resp.Body)
+	if ctx.Err() != nil {
+		return logs, contextError(ctx)
+	}
+	if message := streamError(logs); message != "" {
+		return logs, errors.New("can not build image: " + message)
+	}
+	return logs, nil
 }
 
 func (docker *DockerExecutor) readLogsFromBodyCloser(rd io.ReadCloser) []string {
@@ -517,7 +678,8 @@ func (docker *DockerExecutor) RemoveContainer(containerName string) error {
 /*RemoveImage - удаление образа*/
 func (docker *DockerExecutor) RemoveImage(imageName string) error {
 	ctx := context.Background()
-	delResponse, errDelete := docker.DockerClient.ImageRemove(ctx, imageName, types.ImageRemoveOptions{})
+	// промежуточные слои образа удаляются вместе с ним, образы с тегом (кэш и базовые образы) остаются
+	delResponse, errDelete := docker.DockerClient.ImageRemove(ctx, imageName, types.ImageRemoveOptions{PruneChildren: true})
 	if errDelete != nil {
 		return errDelete
 	}
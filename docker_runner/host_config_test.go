@@ -0,0 +1,59 @@
+package docker_runner
+
+import (
+	"testing"
+
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HostConfigFromResources(t *testing.T) {
+	readOnly := true
+	defaults := models.ContainerResources{
+		CPUs:           1,
+		Memory:         512,
+		Pids:           256,
+		Network:        models.NetworkNone,
+		ReadOnlyRootfs: &readOnly,
+		CapDrop:        []string{"ALL"},
+		User:           "65534:65534",
+	}
+	jobResources := &models.ContainerResources{CPUs: 2, Network: models.NetworkBridge, Disk: 1024}
+	config := hostConfig(jobResources.WithDefaults(defaults, models.ContainerPolicy{}))
+
+	assert.Equal(t, int64(2e9), config.NanoCPUs)
+	assert.Equal(t, int64(512*1024*1024), config.Memory)
+	assert.Equal(t, config.Memory, config.MemorySwap)
+	assert.Equal(t, int64(256), config.PidsLimit)
+	assert.Equal(t, "bridge", string(config.NetworkMode))
+	assert.Equal(t, "1024M", config.StorageOpt["size"])
+	assert.True(t, config.ReadonlyRootfs)
+	assert.Contains(t, config.Tmpfs, "/tmp")
+	assert.Equal(t, []string{"ALL"}, []string(config.CapDrop))
+	assert.Empty(t, config.PortBindings)
+
+	var noResources *models.ContainerResources
+	assert.Equal(t, defaults, noResources.WithDefaults(defaults, models.ContainerPolicy{}))
+}
+
+func Test_HostConfigKeepsSandbox(t *testing.T) {
+	defaults := models.ContainerResources{Network: models.NetworkNone, CapDrop: []string{"ALL"}, User: "65534:65534"}
+	jobResources := &models.ContainerResources{Network: "host", CapDrop: []string{}, User: "0:0"}
+	config := hostConfig(jobResources.WithDefaults(defaults, models.ContainerPolicy{}))
+	assert.Equal(t, models.NetworkNone, string(config.NetworkMode))
+	assert.Equal(t, []string{"ALL"}, []string(config.CapDrop))
+	assert.Equal(t, []string{"no-new-privileges"}, config.SecurityOpt)
+
+	jobResources = &models.ContainerResources{Network: "container:runner", CapDrop: []string{"NET_RAW"}}
+	resources := jobResources.WithDefaults(defaults, models.ContainerPolicy{})
+	assert.Equal(t, models.NetworkNone, resources.Network)
+	assert.Equal(t, []string{"ALL", "NET_RAW"}, resources.CapDrop)
+	assert.Equal(t, "65534:65534", resources.User)
+
+	// слейв разрешает job менять пользователя и capabilities
+	jobResources = &models.ContainerResources{CapDrop: []string{"NET_RAW"}, User: "0:0"}
+	resources = jobResources.WithDefaults(defaults, models.ContainerPolicy{AllowUser: true, AllowCapabilities: true})
+	assert.Equal(t, []string{"NET_RAW"}, resources.CapDrop)
+	assert.Equal(t, "0:0", resources.User)
+	assert.Equal(t, []string{"no-new-privileges"}, hostConfig(resources).SecurityOpt)
+}
@@ -0,0 +1,255 @@
+package docker_runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// CacheImageRepository - репозиторий образов кэша, тег образа - ключ кэша
+	CacheImageRepository = "runner-cache"
+	// cacheLabel - метка образов кэша, по ней кэш восстанавливается после перезапуска слейва
+	cacheLabel = "diplom.runner.cache"
+)
+
+type (
+	/*ImageCache - кэш образов, собранных из начала dockerfile job (FROM и инструкций, не использующих файлы контекста сборки). Ключ кэша - хэш этих инструкций, поэтому одинаковое начало образов разных кандидатов собирается один раз. Образы, которые не использовались дольше всех, удаляются, когда суммарный размер кэша превышает budget*/
+	ImageCache struct {
+		docker *DockerExecutor
+		budget int64 // байт
+		mutex  sync.Mutex
+		images map[string]*cachedImage // ключ кэша: образ
+		now    func() time.Time
+	}
+
+	cachedImage struct {
+		tag      string
+		size     int64
+		lastUsed time.Time
+		users    int           // сборки, использующие образ. Такой образ не удаляется
+		build    chan struct{} // образ собирается один раз, даже если его одновременно запросили несколько job. Ожидание прерывается отменой ctx job
+	}
+)
+
+func newCachedImage(key string) *cachedImage {
+	return &cachedImage{tag: cacheTag(key), build: make(chan struct{}, 1)}
+}
+
+/*NewImageCache - кэш образов с ограничением суммарного размера budget (байт)*/
+func NewImageCache(docker *DockerExecutor, budget int64) *ImageCache {
+	return &ImageCache{
+		docker: docker,
+		budget: budget,
+		images: map[string]*cachedImage{},
+		now:    time.Now,
+	}
+}
+
+/*Load - восстановление кэша из образов докера, собранных до перезапуска слейва*/
+func (cache *ImageCache) Load(ctx context.Context) error {
+	images, err := cache.docker.DockerClient.ImageList(ctx, types.ImageListOptions{Filters: filters.NewArgs(filters.Arg("label", cacheLabel))})
+	if err != nil {
+		return err
+	}
+	cache.mutex.Lock()
+	for _, image := range images {
+		key := image.Labels[cacheLabel]
+		if _, exist := cache.images[key]; key == "" || exist {
+			continue
+		}
+		cached := newCachedImage(key)
+		cached.size, cached.lastUsed = image.Size, time.Unix(image.Created, 0)
+		cache.images[key] = cached
+	}
+	cache.mutex.Unlock()
+	log.Info("loaded images of cache: ", len(images))
+	cache.collect()
+	return nil
+}
+
+/*Acquire - dockerfile job, начало которого заменено образом из кэша. Образ собирается, если его ещё нет. release должен быть вызван после сборки образа job, до этого образ кэша не удаляется*/
+func (cache *ImageCache) Acquire(ctx context.Context, dockerFile []string) ([]string, []string, func(), error) {
+	prefix, rest := cacheablePrefix(dockerFile)
+	if len(prefix) < 2 {
+		// только FROM - базовый образ уже является кэшем
+		return dockerFile, []string{}, func() {}, nil
+	}
+	key := cacheKey(prefix)
+	cache.mutex.Lock()
+	image, exist := cache.images[key]
+	if !exist {
+		image = newCachedImage(key)
+		cache.images[key] = image
+	}
+	image.users++
+	image.lastUsed = cache.now()
+	cache.mutex.Unlock()
+	release := func() {
+		cache.mutex.Lock()
+		image.users--
+		image.lastUsed = cache.now()
+		cache.mutex.Unlock()
+		cache.collect()
+	}
+
+	logs, err := cache.ensureLocked(ctx, image, key, prefix)
+	if err != nil {
+		cache.mutex.Lock()
+		image.users--
+		// образ не собран: запись без образа удаляется, следующая сборка попробует собрать его заново
+		if image.users == 0 && image.size == 0 && cache.images[key] == image {
+			delete(cache.images, key)
+		}
+		cache.mutex.Unlock()
+		return dockerFile, logs, func() {}, err
+	}
+	cache.collect()
+	return append([]string{"FROM " + image.tag + stageAlias(prefix[0])}, rest...), logs, release, nil
+}
+
+/*stageAlias - имя стадии из FROM ... AS name, на которое могут ссылаться следующие стадии*/
+func stageAlias(from string) string {
+	fields := strings.Fields(from)
+	if len(fields) >= 4 && strings.ToUpper(fields[len(fields)-2]) == "AS" {
+		return " AS " + fields[len(fields)-1]
+	}
+	return ""
+}
+
+/*ensureLocked - ensure под image.build. Если другая job уже собирает этот образ, ожидание прерывается отменой ctx*/
+func (cache *ImageCache) ensureLocked(ctx context.Context, image *cachedImage, key string, prefix []string) ([]string, error) {
+	select {
+	case image.build <- struct{}{}:
+	case <-ctx.Done():
+		return []string{}, contextError(ctx)
+	}
+	defer func() { <-image.build }()
+	return cache.ensure(ctx, image, key, prefix)
+}
+
+/*ensure - сборка образа кэша, если его нет в докере. Вызывается под image.build*/
+func (cache *ImageCache) ensure(ctx context.Context, image *cachedImage, key string, prefix []string) ([]string, error) {
+	size, exist, err := cache.inspect(ctx, image.tag)
+	if err != nil {
+		return []string{}, err
+	}
+	if exist {
+		cache.setSize(image, size)
+		return []string{"Using cached image " + image.tag + " for " + strconv.Itoa(len(prefix)) + " instructions\n"}, nil
+	}
+	log.Info("build image of cache: ", image.tag)
+	logs, err := cache.docker.buildImage(ctx, prefix, []string{}, []string{image.tag}, map[string]string{}, map[string]string{}, map[string]string{cacheLabel: key})
+	if err != nil {
+		return logs, err
+	}
+	size, exist, err = cache.inspect(ctx, image.tag)
+	if err != nil {
+		return logs, err
+	}
+	if !exist {
+		return logs, errors.New("image of cache was not built: " + image.tag)
+	}
+	cache.setSize(image, size)
+	return logs, nil
+}
+
+func (cache *ImageCache) inspect(ctx context.Context, tag string) (int64, bool, error) {
+	inspect, _, err := cache.docker.DockerClient.ImageInspectWithRaw(ctx, tag)
+	if client.IsErrNotFound(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return inspect.Size, true, nil
+}
+
+func (cache *ImageCache) setSize(image *cachedImage, size int64) {
+	cache.mutex.Lock()
+	image.size = size
+	cache.mutex.Unlock()
+}
+
+/*collect - удаление образов, которые не использовались дольше всех, пока размер кэша больше budget. Образы, используемые сборками, не удаляются*/
+func (cache *ImageCache) collect() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	var total int64
+	keys := []string{}
+	for key, image := range cache.images {
+		total += image.size
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return cache.images[keys[i]].lastUsed.Before(cache.images[keys[j]].lastUsed)
+	})
+	for _, key := range keys {
+		if total <= cache.budget {
+			return
+		}
+		image := cache.images[key]
+		if image.users > 0 {
+			continue
+		}
+		log.Info("remove image of cache: ", image.tag, " size: ", image.size)
+		// удаляется под mutex, чтобы Acquire не начал использовать удаляемый образ
+		if _, err := cache.docker.DockerClient.ImageRemove(context.Background(), image.tag, types.ImageRemoveOptions{PruneChildren: true}); err != nil && !client.IsErrNotFound(err) {
+			log.Warn("can not remove image of cache: ", err)
+			continue
+		}
+		delete(cache.images, key)
+		total -= image.size
+	}
+}
+
+/*cacheablePrefix - начало dockerfile, которое можно собрать отдельным образом: FROM и следующие за ним инструкции до первой, использующей файлы контекста сборки, аргументы сборки или следующую стадию*/
+func cacheablePrefix(dockerFile []string) ([]string, []string) {
+	if len(dockerFile) == 0 || dockerInstruction(dockerFile[0]) != "FROM" {
+		return []string{}, dockerFile
+	}
+	for index := 1; index < len(dockerFile); index++ {
+		line := strings.TrimSpace(dockerFile[index])
+		if strings.HasPrefix(line, "{{") {
+			// аннотации репозитория кандидата
+			return dockerFile[:index], dockerFile[index:]
+		}
+		switch dockerInstruction(line) {
+		case "COPY", "ADD", "ARG", "FROM", "ONBUILD":
+			return dockerFile[:index], dockerFile[index:]
+		}
+	}
+	return dockerFile, []string{}
+}
+
+func dockerInstruction(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+/*cacheKey - ключ кэша по инструкциям образа*/
+func cacheKey(prefix []string) string {
+	hash := sha256.New()
+	for _, line := range prefix {
+		hash.Write([]byte(strings.TrimSpace(line) + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func cacheTag(key string) string {
+	return CacheImageRepository + ":" + key
+}
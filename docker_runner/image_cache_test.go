@@ -0,0 +1,267 @@
+package docker_runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+)
+
+/*fakeImageStore - докер API с образами в памяти. Каждый собранный образ имеет размер imageSize*/
+type fakeImageStore struct {
+	mutex       sync.Mutex
+	images      map[string]types.ImageSummary // тег: образ
+	dockerFiles map[string]string             // тег: dockerfile, по которому собран образ
+	builds      map[string]int                // тег: количество сборок
+	removed     []string
+	failBuild   string // сборка dockerfile с этой строкой завершается ошибкой
+}
+
+const imageSize = 100
+
+func newFakeImageStore() *fakeImageStore {
+	return &fakeImageStore{images: map[string]types.ImageSummary{}, dockerFiles: map[string]string{}, builds: map[string]int{}}
+}
+
+func (store *fakeImageStore) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := request.URL.Path[strings.Index(request.URL.Path[1:], "/")+1:] // без версии API
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	switch {
+	case request.Method == http.MethodPost && path == "/build":
+		dockerFile, err := readDockerfile(request.Body, request.URL.Query().Get("dockerfile"))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if store.failBuild != "" && strings.Contains(dockerFile, store.failBuild) {
+			writer.Write([]byte("{\"errorDetail\":{\"code\":1,\"message\":\"The command returned a non-zero code: 1\"},\"error\":\"The command returned a non-zero code: 1\"}\n"))
+			return
+		}
+		labels := map[string]string{}
+		json.Unmarshal([]byte(request.URL.Query().Get("labels")), &labels)
+		for _, tag := range request.URL.Query()["t"] {
+			store.images[tag] = types.ImageSummary{ID: tag, RepoTags: []string{tag}, Labels: labels, Size: imageSize, Created: time.Now().Unix()}
+			store.dockerFiles[tag] = dockerFile
+			store.builds[tag]++
+		}
+		writer.Write([]byte("{\"stream\":\"Successfully built\"}\n"))
+	case request.Method == http.MethodGet && path == "/images/json":
+		result := []types.ImageSummary{}
+		for _, image := range store.images {
+			if len(image.Labels) > 0 {
+				result = append(result, image)
+			}
+		}
+		json.NewEncoder(writer).Encode(result)
+	case request.Method == http.MethodGet && strings.HasPrefix(path, "/images/"):
+		image, ok := store.images[strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			writer.Write([]byte(`{"message":"no such image"}`))
+			return
+		}
+		json.NewEncoder(writer).Encode(types.ImageInspect{ID: image.ID, Size: image.Size})
+	case request.Method == http.MethodDelete && strings.HasPrefix(path, "/images/"):
+		tag := strings.TrimPrefix(path, "/images/")
+		delete(store.images, tag)
+		store.removed = append(store.removed, tag)
+		writer.Write([]byte("[]"))
+	default:
+		writer.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func readDockerfile(body io.Reader, name string) (string, error) {
+	gzipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return "", err
+	}
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			return "", err
+		}
+		if header.Name == name {
+			content, err := ioutil.ReadAll(reader)
+			return string(content), err
+		}
+	}
+}
+
+func newFakeImageCache(t *testing.T, store *fakeImageStore, budget int64) (*ImageCache, func()) {
+	server := httptest.NewServer(store)
+	dockerClient, err := client.NewClient(server.URL, "1.38", server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docker := &DockerExecutor{DockerClient: dockerClient}
+	docker.Cache = NewImageCache(docker, budget)
+	return docker.Cache, server.Close
+}
+
+func Test_CacheablePrefix(t *testing.T) {
+	prefix, rest := cacheablePrefix([]string{"FROM golang", "RUN apk add bash", "{{repoCandidate}}", "RUN go build"})
+	assert.Equal(t, []string{"FROM golang", "RUN apk add bash"}, prefix)
+	assert.Equal(t, []string{"{{repoCandidate}}", "RUN go build"}, rest)
+
+	prefix, rest = cacheablePrefix([]string{"FROM golang AS build", "RUN apk add bash", "copy . /src", "FROM alpine"})
+	assert.Equal(t, 2, len(prefix))
+	assert.Equal(t, []string{"copy . /src", "FROM alpine"}, rest)
+
+	prefix, _ = cacheablePrefix([]string{"FROM golang", "RUN apk add bash", "FROM alpine", "RUN apk add git"})
+	assert.Equal(t, 2, len(prefix))
+	prefix, _ = cacheablePrefix([]string{"FROM golang", "ARG VERSION", "RUN echo $VERSION"})
+	assert.Equal(t, 1, len(prefix))
+	prefix, _ = cacheablePrefix([]string{"ARG VERSION", "FROM golang:$VERSION"})
+	assert.Empty(t, prefix)
+
+	assert.Equal(t, cacheKey([]string{"FROM golang", "RUN apk add bash"}), cacheKey([]string{" FROM golang", "RUN apk add bash "}))
+	assert.NotEqual(t, cacheKey([]string{"FROM golang", "RUN apk add bash"}), cacheKey([]string{"FROM golang", "RUN apk add git"}))
+	assert.Equal(t, " AS build", stageAlias("FROM golang as build"))
+	assert.Equal(t, "", stageAlias("FROM golang"))
+}
+
+func Test_ImageCacheReusesPrefix(t *testing.T) {
+	store := newFakeImageStore()
+	cache, closeServer := newFakeImageCache(t, store, 10*imageSize)
+	defer closeServer()
+	prefix := []string{"FROM golang", "RUN apk add bash"}
+	tag := cacheTag(cacheKey(prefix))
+
+	wait := sync.WaitGroup{}
+	for index := 0; index < 8; index++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			_, err := cache.docker.CreateImageMem(context.Background(), append(prefix, "{{repoCandidate}}", "RUN go build"), []string{"./service"}, []string{"job"}, map[string]string{}, map[string]string{})
+			assert.Nil(t, err)
+		}()
+	}
+	wait.Wait()
+	assert.Equal(t, 1, store.builds[tag])
+	assert.Equal(t, 8, store.builds["job"])
+	assert.True(t, strings.HasPrefix(store.dockerFiles["job"], "FROM "+tag+"\n"))
+	assert.Equal(t, "FROM golang\nRUN apk add bash\n", store.dockerFiles[tag])
+
+	// после перезапуска слейва образ берётся из докера
+	restarted := NewImageCache(cache.docker, 10*imageSize)
+	assert.Nil(t, restarted.Load(context.Background()))
+	dockerFile, logs, release, err := restarted.Acquire(context.Background(), append(prefix, "COPY . /src"))
+	release()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"FROM " + tag, "COPY . /src"}, dockerFile)
+	assert.Contains(t, logs[0], "Using cached image "+tag)
+	assert.Equal(t, 1, store.builds[tag])
+
+	// без инструкций после FROM кэш не используется
+	dockerFile, _, release, err = restarted.Acquire(context.Background(), []string{"FROM golang", "COPY . /src"})
+	release()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"FROM golang", "COPY . /src"}, dockerFile)
+}
+
+func Test_ImageCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	store := newFakeImageStore()
+	cache, closeServer := newFakeImageCache(t, store, 2*imageSize)
+	defer closeServer()
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	acquire := func(packages string) func() {
+		_, _, release, err := cache.Acquire(context.Background(), []string{"FROM golang", "RUN apk add " + packages})
+		assert.Nil(t, err)
+		return release
+	}
+	tag := func(packages string) string {
+		return cacheTag(cacheKey([]string{"FROM golang", "RUN apk add " + packages}))
+	}
+
+	acquire("bash")()
+	acquire("git")()
+	acquire("bash")()
+	// git использовался раньше всех
+	acquire("make")()
+	assert.Equal(t, []string{tag("git")}, store.removed)
+
+	// используемые сборками образы не удаляются, даже если кэш больше ограничения
+	releaseBash, releaseMake := acquire("bash"), acquire("make")
+	releaseCurl := acquire("curl")
+	assert.Equal(t, []string{tag("git")}, store.removed)
+	releaseCurl()
+	assert.Equal(t, []string{tag("git"), tag("curl")}, store.removed)
+	releaseBash()
+	releaseMake()
+	assert.Equal(t, 2, len(cache.images))
+}
+
+func Test_ImageCacheForgetsFailedBuild(t *testing.T) {
+	store := newFakeImageStore()
+	store.failBuild = "RUN apk add unknown"
+	cache, closeServer := newFakeImageCache(t, store, 10*imageSize)
+	defer closeServer()
+	prefix := []string{"FROM golang", "RUN apk add unknown"}
+
+	_, _, release, err := cache.Acquire(context.Background(), append(prefix, "COPY . /src"))
+	release()
+	assert.NotNil(t, err)
+	// ошибка сборки возвращается из потока сборки, а не из последующей проверки образа
+	assert.Equal(t, "can not build image: The command returned a non-zero code: 1", err.Error())
+	assert.Empty(t, cache.images)
+
+	// после исправления сборки образ собирается заново
+	store.mutex.Lock()
+	store.failBuild = ""
+	store.mutex.Unlock()
+	dockerFile, _, release, err := cache.Acquire(context.Background(), append(prefix, "COPY . /src"))
+	release()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"FROM " + cacheTag(cacheKey(prefix)), "COPY . /src"}, dockerFile)
+	assert.Equal(t, 1, len(cache.images))
+}
+
+func Test_ImageCacheWaitCanceled(t *testing.T) {
+	store := newFakeImageStore()
+	cache, closeServer := newFakeImageCache(t, store, 10*imageSize)
+	defer closeServer()
+	prefix := []string{"FROM golang", "RUN apk add bash"}
+	key := cacheKey(prefix)
+	// другая job собирает этот же образ
+	building := newCachedImage(key)
+	building.users = 1
+	building.build <- struct{}{}
+	cache.images[key] = building
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, release, err := cache.Acquire(ctx, append(prefix, "COPY . /src"))
+		release()
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled job is still waiting for image of cache")
+	}
+	assert.Equal(t, 1, building.users)
+	assert.Equal(t, building, cache.images[key])
+	assert.Equal(t, 0, store.builds[cacheTag(key)])
+}
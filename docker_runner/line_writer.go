@@ -0,0 +1,71 @@
+package docker_runner
+
+import (
+	"bytes"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	// LineHandler - обработчик строки лога контейнера (запись с отметкой времени и потоком, без перевода строки)
+	LineHandler func(line models.LogLine)
+
+	/*logCollector - общий для stdout и stderr контейнера лог, сохраняющий порядок строк*/
+	logCollector struct {
+		logs   models.LogsPerTask
+		onLine LineHandler
+	}
+
+	/*lineWriter - разбиение потока вывода контейнера на строки*/
+	lineWriter struct {
+		stream    string
+		collector *logCollector
+		buffer    []byte
+	}
+)
+
+func newLogCollector(onLine LineHandler) *logCollector {
+	return &logCollector{
+		logs: models.LogsPerTask{
+			STDOUT:  []string{},
+			STDERR:  []string{},
+			Records: []models.LogLine{},
+		},
+		onLine: onLine,
+	}
+}
+
+func (collector *logCollector) writer(stream string) *lineWriter {
+	return &lineWriter{
+		stream:    stream,
+		collector: collector,
+	}
+}
+
+func (writer *lineWriter) Write(data []byte) (int, error) {
+	writer.buffer = append(writer.buffer, data...)
+	for {
+		index := bytes.IndexByte(writer.buffer, '\n')
+		if index < 0 {
+			break
+		}
+		writer.emit(string(writer.buffer[:index]))
+		writer.buffer = writer.buffer[index+1:]
+	}
+	return len(data), nil
+}
+
+/*flush - последняя строка без перевода строки*/
+func (writer *lineWriter) flush() {
+	if len(writer.buffer) > 0 {
+		writer.emit(string(writer.buffer))
+		writer.buffer = nil
+	}
+}
+
+func (writer *lineWriter) emit(line string) {
+	record := writer.collector.logs.AddLine(writer.stream, line)
+	if writer.collector.onLine != nil {
+		writer.collector.onLine(record)
+	}
+}
@@ -0,0 +1,29 @@
+package docker_runner
+
+import (
+	"testing"
+
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_lineWriterSplitsChunks(t *testing.T) {
+	streamed := []string{}
+	collector := newLogCollector(func(line models.LogLine) {
+		streamed = append(streamed, line.Stream+":"+line.Line)
+	})
+	stdout, stderr := collector.writer(models.LogStreamStdout), collector.writer(models.LogStreamStderr)
+	stdout.Write([]byte("first li"))
+	assert.Equal(t, 0, len(streamed))
+	stdout.Write([]byte("ne\nsecond\nthi"))
+	stderr.Write([]byte("error\n"))
+	assert.Equal(t, []string{"stdout:first line", "stdout:second", "stderr:error"}, streamed)
+	stdout.flush()
+	stderr.flush()
+	assert.Equal(t, []string{"stdout:first line", "stdout:second", "stderr:error", "stdout:thi"}, streamed)
+	assert.Equal(t, []string{"first line\n", "second\n", "thi\n"}, collector.logs.STDOUT)
+	assert.Equal(t, []string{"error\n"}, collector.logs.STDERR)
+	// порядок строк stdout и stderr сохраняется
+	assert.Equal(t, models.LogStreamStderr, collector.logs.Records[2].Stream)
+	assert.Equal(t, 4, len(collector.logs.Records))
+}
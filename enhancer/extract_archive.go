@@ -0,0 +1,111 @@
+package enhancer
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrArchiveTooLarge - размер файлов архива больше допустимого
+	ErrArchiveTooLarge = errors.New("archive exceeds size limit")
+	errEmptyPath       = errors.New("empty path")
+)
+
+/*ExtractArchive - распаковка tar архива (в формате docker cp) в destination. Сохраняются только обычные файлы и директории. При ошибке или превышении limit (байт, 0 - без ограничения) распакованные файлы удаляются*/
+func ExtractArchive(archive io.Reader, destination string, limit int64) ([]string, error) {
+	reader := tar.NewReader(archive)
+	saved := []string{}
+	var total int64
+	fail := func(err error) ([]string, error) {
+		for _, name := range saved {
+			os.Remove(destination + "/" + name)
+		}
+		return nil, err
+	}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return saved, nil
+		}
+		if err != nil {
+			return fail(err)
+		}
+		name, errName := CleanArchivePath(header.Name)
+		if errName != nil {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if errDir := os.MkdirAll(destination+"/"+name, os.ModePerm); errDir != nil {
+				return fail(errDir)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			total += header.Size
+			if limit > 0 && total > limit {
+				return fail(ErrArchiveTooLarge)
+			}
+			if errWrite := writeArchiveFile(destination+"/"+name, reader, header.Size); errWrite != nil {
+				return fail(errWrite)
+			}
+			saved = append(saved, name)
+		}
+	}
+}
+
+/*CleanArchivePath - относительный путь без выхода за пределы директории распаковки*/
+func CleanArchivePath(name string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if cleaned == "" {
+		return "", errEmptyPath
+	}
+	return cleaned, nil
+}
+
+func writeArchiveFile(fileName string, content io.Reader, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyN(file, content, size); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+/*ReadArchiveFiles - чтение всех обычных файлов tar архива (в формате docker cp) в память в порядке их следования в архиве. Превышение limit (байт, 0 - без ограничения) - ошибка*/
+func ReadArchiveFiles(archive io.Reader, limit int64) ([][]byte, error) {
+	reader := tar.NewReader(archive)
+	result := [][]byte{}
+	var total int64
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		total += header.Size
+		if limit > 0 && total > limit {
+			return nil, ErrArchiveTooLarge
+		}
+		content, errRead := ioutil.ReadAll(io.LimitReader(reader, header.Size))
+		if errRead != nil {
+			return nil, errRead
+		}
+		result = append(result, content)
+	}
+}
@@ -0,0 +1,152 @@
+package enhancer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kubitre/diplom/models"
+)
+
+/*StructuredLogExt - расширение файлов логов job в формате JSON lines (models.LogLine)*/
+const StructuredLogExt = ".jsonl"
+
+type (
+	/*LogFilter - фильтр записей лога по потокам, диапазону строк (offset записи в логе job) и регулярному выражению*/
+	LogFilter struct {
+		Streams []string
+		From    int64 // -1 - без ограничения
+		To      int64 // включительно, -1 - без ограничения
+		Grep    *regexp.Regexp
+	}
+)
+
+/*ParseLogFilter - фильтр из параметров запроса: stream (через запятую), from, to, grep*/
+func ParseLogFilter(query url.Values) (LogFilter, error) {
+	filter := LogFilter{From: -1, To: -1}
+	if streams := query.Get("stream"); streams != "" {
+		filter.Streams = strings.Split(streams, ",")
+	}
+	for name, value := range map[string]*int64{"from": &filter.From, "to": &filter.To} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				return filter, errors.New("invalid line number in " + name + ": " + raw)
+			}
+			*value = parsed
+		}
+	}
+	if grep := query.Get("grep"); grep != "" {
+		compiled, err := regexp.Compile(grep)
+		if err != nil {
+			return filter, err
+		}
+		filter.Grep = compiled
+	}
+	return filter, nil
+}
+
+/*IsEmpty - фильтр не задан*/
+func (filter LogFilter) IsEmpty() bool {
+	return len(filter.Streams) == 0 && filter.From < 0 && filter.To < 0 && filter.Grep == nil
+}
+
+/*Match - запись проходит фильтр*/
+func (filter LogFilter) Match(record models.LogLine) bool {
+	if len(filter.Streams) > 0 {
+		found := false
+		for _, stream := range filter.Streams {
+			if stream == record.Stream {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.From >= 0 && record.Offset < filter.From {
+		return false
+	}
+	if filter.To >= 0 && record.Offset > filter.To {
+		return false
+	}
+	return filter.Grep == nil || filter.Grep.MatchString(record.Line)
+}
+
+/*FilterLogRecords - записи лога задачи, этапа или job (как в Mergelog), прошедшие фильтр. Записи нескольких job упорядочиваются по времени*/
+func FilterLogRecords(rootPath, taskID, stage, job string, filter LogFilter) ([]models.LogLine, error) {
+	files, err := structuredLogFiles(rootPath, taskID, stage, job)
+	if err != nil {
+		return nil, err
+	}
+	result := []models.LogLine{}
+	for _, file := range files {
+		records, errRead := ReadLogRecords(file)
+		if errRead != nil {
+			return nil, errRead
+		}
+		for _, record := range records {
+			if filter.Match(record) {
+				result = append(result, record)
+			}
+		}
+	}
+	if len(files) > 1 {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].Timestamp.Before(result[j].Timestamp)
+		})
+	}
+	return result, nil
+}
+
+/*ReadLogRecords - чтение записей из файла лога job*/
+func ReadLogRecords(fileName string) ([]models.LogLine, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	result := []models.LogLine{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record models.LogLine
+		if errDecode := json.Unmarshal(scanner.Bytes(), &record); errDecode != nil {
+			return nil, errDecode
+		}
+		result = append(result, record)
+	}
+	return result, scanner.Err()
+}
+
+func structuredLogFiles(rootPath, taskID, stage, job string) ([]string, error) {
+	if taskID == "" {
+		return nil, errors.New("can not filter log with empty task")
+	}
+	if job != "" {
+		return []string{rootPath + "/" + taskID + "/" + stage + "/" + job + StructuredLogExt}, nil
+	}
+	searchPath := rootPath + "/" + taskID
+	if stage != "" {
+		searchPath += "/" + stage
+	}
+	walkedFiles, err := walkDir(searchPath)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, file := range walkedFiles {
+		if filepath.Ext(file) == StructuredLogExt {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
@@ -1,10 +1,12 @@
 package enhancer
 
-/*MergeMetricsToString - смержить все спарсенные метрики в строки, с разделителями в виде запятой*/
-func MergeMetricsToString(metrics map[string][]string) map[string]string {
+import "github.com/kubitre/diplom/models"
+
+/*MergeMetricsToString - смержить все спарсенные метрики (агрегированные значения или значения всех совпадений) в строки, с разделителями в виде запятой*/
+func MergeMetricsToString(metrics map[string]models.MetricReport) map[string]string {
 	result := map[string]string{}
-	for nameMetric, values := range metrics {
-		result[nameMetric] = mergeStrings(values)
+	for nameMetric, metric := range metrics {
+		result[nameMetric] = mergeStrings(metric.Values())
 	}
 	return result
 }
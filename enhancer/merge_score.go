@@ -0,0 +1,33 @@
+package enhancer
+
+import (
+	"strconv"
+
+	"github.com/kubitre/diplom/models"
+)
+
+/*MergeScoreToString - итоговый балл, вердикт и результат каждого правила (score.{правило}) в строковом виде для портала*/
+func MergeScoreToString(score *models.TaskScore) map[string]string {
+	result := map[string]string{
+		"score":   strconv.FormatFloat(score.Score, 'f', 2, 64),
+		"verdict": score.Verdict,
+	}
+	for index, rule := range score.Rules {
+		name := rule.Name
+		if name == "" {
+			name = strconv.Itoa(index)
+		}
+		status := "failed"
+		if rule.Passed {
+			status = "passed"
+		}
+		switch {
+		case rule.Error != "":
+			status += ": " + rule.Error
+		case rule.Value != nil:
+			status += ": " + strconv.FormatFloat(*rule.Value, 'f', -1, 64)
+		}
+		result["score."+name] = status
+	}
+	return result
+}
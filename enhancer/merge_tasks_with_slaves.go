@@ -6,29 +6,25 @@ import (
 	"github.com/kubitre/diplom/payloads"
 )
 
-func MergeTasksWithSlaves(slaves []monitor.Slave, tasks []models.Task, executingTask, history []int) []payloads.EnhancedSlave {
+func MergeTasksWithSlaves(slaves []monitor.Slave, tasks []models.Task) []payloads.EnhancedSlave {
 	result := []payloads.EnhancedSlave{}
 	for _, slave := range slaves {
-		result = append(result, mergeTasksWithSlave(slave, tasks, executingTask, history))
+		result = append(result, mergeTasksWithSlave(slave, tasks))
 	}
 	return result
 }
 
-func mergeTasksWithSlave(slave monitor.Slave, tasks []models.Task, executedTask, historyTasks []int) payloads.EnhancedSlave {
+func mergeTasksWithSlave(slave monitor.Slave, tasks []models.Task) payloads.EnhancedSlave {
 	result := []models.Task{}
 	history := []models.Task{}
-	for _, taskID := range slave.CurrentExecuteTasks {
-		for _, taskIndex := range executedTask {
-			if taskIndex == taskID {
-				result = append(result, tasks[taskIndex])
-			}
+	for _, task := range tasks {
+		if task.SlaveID != slave.ID {
+			continue
 		}
-	}
-	for _, taskID := range slave.HistoryTasks {
-		for _, taskIndex := range historyTasks {
-			if taskIndex == taskID {
-				history = append(result, tasks[taskIndex])
-			}
+		if task.StatusTask.IsFinal() {
+			history = append(history, task)
+		} else {
+			result = append(result, task)
 		}
 	}
 
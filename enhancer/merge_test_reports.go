@@ -0,0 +1,29 @@
+package enhancer
+
+import (
+	"strconv"
+
+	"github.com/kubitre/diplom/models"
+)
+
+/*MergeTestReportsToString - сводка по каждому отчёту о тестах ({job}.{отчёт}_tests, {job}.{отчёт}_coverage) в строковом виде для портала*/
+func MergeTestReportsToString(tests []models.TestReport) map[string]string {
+	result := map[string]string{}
+	for _, report := range tests {
+		name := report.Job + "." + report.Name
+		if report.Error != "" {
+			result[name+"_error"] = report.Error
+			continue
+		}
+		if report.Total > 0 {
+			result[name+"_tests"] = "passed " + strconv.Itoa(report.Passed) +
+				", failed " + strconv.Itoa(report.Failed) +
+				", skipped " + strconv.Itoa(report.Skipped) +
+				" of " + strconv.Itoa(report.Total)
+		}
+		if report.Coverage != nil {
+			result[name+"_coverage"] = strconv.FormatFloat(*report.Coverage, 'f', 2, 64) + "%"
+		}
+	}
+	return result
+}
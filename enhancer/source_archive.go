@@ -0,0 +1,228 @@
+package enhancer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	sourceFormatZip   = "zip"
+	sourceFormatTarGz = "tar.gz"
+	sourceFormatTar   = "tar"
+
+	// maxSourceFiles - ограничение количества файлов в архиве кода кандидата
+	maxSourceFiles = 100000
+)
+
+var (
+	// ErrArchivePath - путь в архиве выходит за пределы директории распаковки
+	ErrArchivePath = errors.New("archive contains path outside of its root")
+	// ErrArchiveFormat - архив не является zip, tar.gz или tar
+	ErrArchiveFormat = errors.New("unsupported archive format, zip, tar.gz or tar is expected")
+)
+
+/*sourceEntry - файл или директория архива кода кандидата*/
+type sourceEntry struct {
+	name    string
+	dir     bool
+	size    int64
+	mode    os.FileMode
+	content io.Reader
+}
+
+/*PackSourceArchive - проверка архива кода кандидата (zip, tar.gz или tar) в файле source и его перепаковка в tar.gz. Пути не могут выходить за пределы архива, суммарный размер файлов ограничен limit (байт, 0 - без ограничения), ссылки пропускаются. Если все файлы лежат в одной директории верхнего уровня (архивы GitHub, GitLab), она убирается. Возвращает количество файлов*/
+func PackSourceArchive(source string, output io.Writer, limit int64) (int, error) {
+	format, err := sourceArchiveFormat(source)
+	if err != nil {
+		return 0, err
+	}
+	names := []string{}
+	var total int64
+	if err := walkSourceArchive(source, format, func(entry sourceEntry) error {
+		name, errName := sourceEntryName(entry.name)
+		if errName == errEmptyPath {
+			// корень архива (./)
+			return nil
+		}
+		if errName != nil {
+			return errName
+		}
+		names = append(names, name)
+		if !entry.dir {
+			total += entry.size
+		}
+		if limit > 0 && total > limit {
+			return ErrArchiveTooLarge
+		}
+		if len(names) > maxSourceFiles {
+			return errors.New("archive contains too many files")
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	root := commonRoot(names)
+	gzipWriter := gzip.NewWriter(output)
+	tarWriter := tar.NewWriter(gzipWriter)
+	files := 0
+	if err := walkSourceArchive(source, format, func(entry sourceEntry) error {
+		name, errName := sourceEntryName(entry.name)
+		name = strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+		if errName != nil || name == "" {
+			return nil
+		}
+		header := &tar.Header{Name: name, Mode: int64(entry.mode.Perm() | 0600), Typeflag: tar.TypeDir}
+		if entry.dir {
+			header.Mode |= 0700
+			return tarWriter.WriteHeader(header)
+		}
+		header.Typeflag, header.Size = tar.TypeReg, entry.size
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		// читается не больше объявленного размера, поэтому превысить limit содержимым нельзя
+		if _, err := io.CopyN(tarWriter, entry.content, entry.size); err != nil {
+			return err
+		}
+		files++
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return 0, err
+	}
+	return files, gzipWriter.Close()
+}
+
+/*sourceArchiveFormat - формат архива по его первым байтам*/
+func sourceArchiveFormat(source string) (string, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	header := make([]byte, 512)
+	read, _ := io.ReadFull(file, header)
+	header = header[:read]
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return sourceFormatZip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return sourceFormatTarGz, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return sourceFormatTar, nil
+	}
+	return "", ErrArchiveFormat
+}
+
+/*walkSourceArchive - обход обычных файлов и директорий архива*/
+func walkSourceArchive(source, format string, walk func(sourceEntry) error) error {
+	if format == sourceFormatZip {
+		reader, err := zip.OpenReader(source)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		for _, file := range reader.File {
+			info := file.FileInfo()
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				continue
+			}
+			content, err := file.Open()
+			if err != nil {
+				return err
+			}
+			err = walk(sourceEntry{name: file.Name, dir: info.IsDir(), size: int64(file.UncompressedSize64), mode: info.Mode(), content: content})
+			content.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var archive io.Reader = file
+	if format == sourceFormatTarGz {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		archive = gzipReader
+	}
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA:
+		default:
+			continue
+		}
+		if err := walk(sourceEntry{
+			name:    header.Name,
+			dir:     header.Typeflag == tar.TypeDir,
+			size:    header.Size,
+			mode:    os.FileMode(header.Mode),
+			content: reader,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+/*sourceEntryName - относительный путь файла архива. В отличие от CleanArchivePath, абсолютные пути и переходы выше корня архива отклоняются*/
+func sourceEntryName(name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if strings.HasPrefix(name, "/") {
+		return "", ErrArchivePath
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", ErrArchivePath
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if cleaned == "" {
+		return "", errEmptyPath
+	}
+	return cleaned, nil
+}
+
+/*commonRoot - директория верхнего уровня, в которой лежат все пути архива. Пусто - такой директории нет*/
+func commonRoot(names []string) string {
+	root := ""
+	nested := false
+	for _, name := range names {
+		parts := strings.SplitN(name, "/", 2)
+		if root == "" {
+			root = parts[0]
+		}
+		if parts[0] != root {
+			return ""
+		}
+		nested = nested || len(parts) == 2
+	}
+	if !nested {
+		// архив из одного файла или директорий без вложений
+		return ""
+	}
+	return root
+}
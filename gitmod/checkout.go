@@ -0,0 +1,186 @@
+package gitmod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// sizeCheckInterval - как часто проверяется размер клонируемого репозитория
+const sizeCheckInterval = 500 * time.Millisecond
+
+/*CheckoutOptions - что клонируется из репозитория кандидата*/
+type CheckoutOptions struct {
+	URL         string
+	Ref         string // ветка, тег или полное имя ссылки (refs/...). Пусто - ветка по умолчанию
+	Commit      string // полный SHA коммита, на который переключается рабочая копия
+	Depth       int    // 0 - вся история
+	Submodules  bool
+	MaxSize     int64 // ограничение размера клона в байтах, 0 - без ограничения
+	Credentials *Credentials
+}
+
+/*Checkout - клонирование репозитория в path с переключением на ref или commit. Возвращает SHA коммита рабочей копии. При ошибке path удаляется*/
+func (gt *Git) Checkout(ctx context.Context, path string, options CheckoutOptions) (string, error) {
+	auth, err := options.Credentials.authMethod()
+	if err != nil {
+		return "", err
+	}
+	cloneCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var exceeded int32
+	if options.MaxSize > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go watchSize(path, options.MaxSize, done, func() {
+			atomic.StoreInt32(&exceeded, 1)
+			cancel()
+		})
+	}
+	commit, err := checkout(cloneCtx, path, options, auth)
+	if err == nil && options.MaxSize > 0 && dirSize(path) > options.MaxSize {
+		atomic.StoreInt32(&exceeded, 1)
+	}
+	if atomic.LoadInt32(&exceeded) == 1 {
+		err = errors.New("repository is larger than " + strconv.FormatInt(options.MaxSize, 10) + " bytes")
+	}
+	if err != nil {
+		os.RemoveAll(path)
+		return "", err
+	}
+	log.Info("repository ", options.URL, " was cloned into ", path, " on commit ", commit)
+	return commit, nil
+}
+
+func checkout(ctx context.Context, path string, options CheckoutOptions, auth transport.AuthMethod) (string, error) {
+	var (
+		repository *git.Repository
+		err        error
+	)
+	references := referenceNames(options.Ref)
+	for index, reference := range references {
+		repository, err = git.PlainCloneContext(ctx, path, false, &git.CloneOptions{
+			URL:           options.URL,
+			Auth:          auth,
+			ReferenceName: reference,
+			SingleBranch:  options.Ref != "",
+			Depth:         options.Depth,
+		})
+		if err == nil || ctx.Err() != nil || index == len(references)-1 {
+			break
+		}
+		// ref не найден среди веток - пробуем теги
+		os.RemoveAll(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return "", err
+	}
+	if options.Commit != "" {
+		hash, errResolve := repository.ResolveRevision(plumbing.Revision(options.Commit))
+		if errResolve != nil {
+			return "", errors.New("commit " + options.Commit + " is not found in cloned history (check ref or depth): " + errResolve.Error())
+		}
+		if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true}); err != nil {
+			return "", err
+		}
+	}
+	if options.Submodules {
+		if err := updateSubmodules(ctx, worktree, auth, git.DefaultSubmoduleRecursionDepth); err != nil {
+			return "", err
+		}
+	}
+	head, err := repository.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+/*updateSubmodules - инициализация подмодулей рабочей копии. Адрес каждого подмодуля берётся из .gitmodules кандидата, поэтому проверяется так же, как адрес самого репозитория, до клонирования*/
+func updateSubmodules(ctx context.Context, worktree *git.Worktree, auth transport.AuthMethod, depth git.SubmoduleRescursivity) error {
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return err
+	}
+	for _, submodule := range submodules {
+		if url := submodule.Config().URL; !models.ValidRepoURL(url) {
+			return errors.New("submodule " + submodule.Config().Name + " has invalid url, only https://, ssh:// and git@host:path addresses are allowed: " + url)
+		}
+	}
+	for _, submodule := range submodules {
+		if err := submodule.UpdateContext(ctx, &git.SubmoduleUpdateOptions{Init: true, Auth: auth}); err != nil {
+			return err
+		}
+		if depth <= 1 {
+			continue
+		}
+		repository, err := submodule.Repository()
+		if err != nil {
+			return err
+		}
+		nested, err := repository.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := updateSubmodules(ctx, nested, auth, depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*referenceNames - ссылки, которые пробуются для ref: ветка, затем тег*/
+func referenceNames(ref string) []plumbing.ReferenceName {
+	switch {
+	case ref == "":
+		return []plumbing.ReferenceName{plumbing.HEAD}
+	case strings.HasPrefix(ref, "refs/"):
+		return []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		return []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+}
+
+/*watchSize - вызов exceeded, когда размер path превысит maxSize. Останавливается при закрытии done*/
+func watchSize(path string, maxSize int64, done <-chan struct{}, exceeded func()) {
+	ticker := time.NewTicker(sizeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if dirSize(path) > maxSize {
+				exceeded()
+				return
+			}
+		}
+	}
+}
+
+/*dirSize - размер файлов в директории*/
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
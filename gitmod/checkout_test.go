@@ -0,0 +1,120 @@
+package gitmod
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+/*sourceRepository - репозиторий с двумя коммитами и тегом v1 на первом из них*/
+func sourceRepository(t *testing.T, path string) (string, string) {
+	repository, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(content string) plumbing.Hash {
+		if err := ioutil.WriteFile(path+"/main.go", []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("main.go"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "candidate", Email: "candidate@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	first := commit("package first")
+	if _, err := repository.CreateTag("v1", first, nil); err != nil {
+		t.Fatal(err)
+	}
+	second := commit("package second")
+	return first.String(), second.String()
+}
+
+func Test_Checkout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitmod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first, second := sourceRepository(t, dir+"/source")
+	gt := &Git{}
+	ctx := context.Background()
+
+	commit, err := gt.Checkout(ctx, dir+"/default", CheckoutOptions{URL: dir + "/source", Depth: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, second, commit)
+
+	commit, err = gt.Checkout(ctx, dir+"/tag", CheckoutOptions{URL: dir + "/source", Ref: "v1"})
+	assert.Nil(t, err)
+	assert.Equal(t, first, commit)
+	content, _ := ioutil.ReadFile(dir + "/tag/main.go")
+	assert.Equal(t, "package first", string(content))
+
+	commit, err = gt.Checkout(ctx, dir+"/commit", CheckoutOptions{URL: dir + "/source", Ref: "master", Commit: first})
+	assert.Nil(t, err)
+	assert.Equal(t, first, commit)
+
+	_, err = gt.Checkout(ctx, dir+"/unknown", CheckoutOptions{URL: dir + "/source", Ref: "unknown"})
+	assert.NotNil(t, err)
+
+	_, err = gt.Checkout(ctx, dir+"/large", CheckoutOptions{URL: dir + "/source", MaxSize: 1})
+	assert.NotNil(t, err)
+	_, err = os.Stat(dir + "/large")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_CheckoutRejectsLocalSubmodule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitmod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sourceRepository(t, dir+"/other")
+	sourceRepository(t, dir+"/source")
+	repository, err := git.PlainOpen(dir + "/source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitmodules := "[submodule \"other\"]\n\tpath = other\n\turl = file://" + dir + "/other\n"
+	if err := ioutil.WriteFile(dir+"/source/.gitmodules", []byte(gitmodules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add(".gitmodules"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Commit("submodule", &git.CommitOptions{
+		Author: &object.Signature{Name: "candidate", Email: "candidate@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	gt := &Git{}
+
+	_, err = gt.Checkout(context.Background(), dir+"/submodules", CheckoutOptions{URL: dir + "/source", Submodules: true})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "submodule other has invalid url")
+	_, err = os.Stat(dir + "/submodules")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = gt.Checkout(context.Background(), dir+"/plain", CheckoutOptions{URL: dir + "/source"})
+	assert.Nil(t, err)
+}
@@ -1,17 +1,29 @@
 package gitmod
 
 import (
+	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 )
 
 type Git struct {
 }
 
+/*Credentials - доступ к приватному репозиторию кандидата. Token - для HTTP(S), PrivateKey - для SSH*/
+type Credentials struct {
+	Username   string
+	Token      string
+	PrivateKey string
+	KnownHosts string // содержимое known_hosts. Пусто - ~/.ssh/known_hosts
+}
+
 type ErrorType int
 
 const (
@@ -23,11 +35,16 @@ const (
 	stringErrorAuthenticated = "need auth"
 )
 
-/*CloneRepo - клонирование репозитория кандата по его url*/
-func (gt *Git) CloneRepo(url string) (string, error) {
+/*CloneRepo - клонирование репозитория кандата по его url. credentials == nil - публичный репозиторий*/
+func (gt *Git) CloneRepo(url string, credentials *Credentials) (string, error) {
 	id := uuid.New()
+	auth, err := credentials.authMethod()
+	if err != nil {
+		return "", err
+	}
 	res, err := git.PlainClone("repo_"+id.String(), false, &git.CloneOptions{
 		URL:      url,
+		Auth:     auth,
 		Progress: os.Stdout,
 	})
 	if err != nil {
@@ -65,3 +82,41 @@ func (gt *Git) GetTypeError(err error) ErrorType {
 func (gt *Git) RemoveRepo(repoPath string) error {
 	return os.RemoveAll(repoPath)
 }
+
+/*authMethod - способ аутентификации go-git по данным доступа*/
+func (credentials *Credentials) authMethod() (transport.AuthMethod, error) {
+	if credentials == nil {
+		return nil, nil
+	}
+	username := credentials.Username
+	if username == "" {
+		username = "git"
+	}
+	if credentials.PrivateKey == "" {
+		return &http.BasicAuth{Username: username, Password: credentials.Token}, nil
+	}
+	auth, err := ssh.NewPublicKeys(username, []byte(credentials.PrivateKey), "")
+	if err != nil {
+		return nil, err
+	}
+	if credentials.KnownHosts == "" {
+		return auth, nil
+	}
+	// known_hosts читается go-git только из файла
+	file, err := ioutil.TempFile("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(credentials.KnownHosts); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if auth.HostKeyCallback, err = ssh.NewKnownHostsCallback(file.Name()); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/api_keys"
+	"github.com/kubitre/diplom/enhancer"
+	log "github.com/sirupsen/logrus"
+)
+
+/*statusRecorder - запоминает код ответа для журнала запросов. Flush нужен для трансляции логов через SSE*/
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(code int) {
+	if recorder.status == 0 {
+		recorder.status = code
+	}
+	recorder.ResponseWriter.WriteHeader(code)
+}
+
+func (recorder *statusRecorder) Write(data []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return recorder.ResponseWriter.Write(data)
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+/*CheckAPIKey - проверка ключа из Authorization: Bearer (или X-API-Key) и его права scope. Каждый запрос записывается в журнал*/
+func CheckAPIKey(keys *api_keys.KeyStore, audit *api_keys.AuditLog, scope string, next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		record := &api_keys.AuditRecord{
+			Time:       time.Now().Unix(),
+			Scope:      scope,
+			Method:     request.Method,
+			Path:       request.URL.Path,
+			TaskID:     mux.Vars(request)["taskID"],
+			RemoteAddr: request.RemoteAddr,
+		}
+		defer audit.Write(record)
+		if keys == nil {
+			record.Error, record.Status = "api keys are not configured", http.StatusUnauthorized
+			unauthorized(request, writer, record)
+			return
+		}
+		key, err := keys.Authenticate(api_keys.KeyFromRequest(request))
+		if err != nil {
+			record.Error, record.Status = err.Error(), http.StatusUnauthorized
+			unauthorized(request, writer, record)
+			return
+		}
+		record.Key = key.Name
+		if !key.Allows(scope) {
+			record.Error, record.Status = "api key has no scope: "+scope, http.StatusForbidden
+			unauthorized(request, writer, record)
+			return
+		}
+		recorder := &statusRecorder{ResponseWriter: writer}
+		next.ServeHTTP(recorder, api_keys.WithAuth(request, key, record))
+		record.Status = recorder.status
+		if record.Status == 0 {
+			record.Status = http.StatusOK
+		}
+	})
+}
+
+func unauthorized(request *http.Request, writer http.ResponseWriter, record *api_keys.AuditRecord) {
+	log.Warn("rejected api request: ", record.Method, " ", record.Path, " key: ", record.Key, " by error: ", record.Error)
+	enhancer.Response(request, writer, map[string]interface{}{
+		"context": map[string]string{
+			"package": "middlewares",
+			"func":    "CheckAPIKey",
+		},
+		"status": "runner can not execute your request. " + record.Error,
+	}, record.Status)
+}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/runner_auth"
+	log "github.com/sirupsen/logrus"
+)
+
+/*CheckRunnerSignature - проверка подписи запроса от мастера или слейва общим ключом RUNNER_SECRET. Тело запроса должно входить в подпись*/
+func CheckRunnerSignature(auth *runner_auth.RunnerAuth, next http.Handler) http.HandlerFunc {
+	return checkRunnerSignature(auth.Verify, next)
+}
+
+/*CheckRunnerStreamSignature - проверка подписи потокового запроса (логи, артефакты), тело которого может не входить в подпись*/
+func CheckRunnerStreamSignature(auth *runner_auth.RunnerAuth, next http.Handler) http.HandlerFunc {
+	return checkRunnerSignature(auth.VerifyStream, next)
+}
+
+func checkRunnerSignature(verify func(*http.Request) error, next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if err := verify(request); err != nil {
+			log.Warn("rejected unauthenticated runner request: ", request.Method, " ", request.URL.Path, " from: ", request.RemoteAddr, " by error: ", err)
+			enhancer.Response(request, writer, map[string]interface{}{
+				"context": map[string]string{
+					"package": "middlewares",
+					"func":    "CheckRunnerSignature",
+				},
+				"status": "runner can not execute your request. Invalid request signature",
+			}, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
@@ -0,0 +1,12 @@
+package models
+
+type (
+	/*Artefact - файл артефакта job, сохранённый на мастере*/
+	Artefact struct {
+		Stage        string `json:"stage"`
+		Job          string `json:"job"`
+		Path         string `json:"path"` // путь относительно директории артефактов job
+		Size         int64  `json:"size"`
+		TimeModified int64  `json:"time_modified"`
+	}
+)
@@ -7,5 +7,8 @@ type (
 		WorkDir       string   `json:"workdir"`
 		ShellCommands []string `json:"shell"`
 		ContainerName string   `json:"container_name"`
+		Env           []string `json:"-"` // переменные окружения с секретами job (ИМЯ=значение)
+
+		Resources ContainerResources `json:"resources"` // ограничения и изоляция контейнера
 	}
 )
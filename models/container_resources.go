@@ -0,0 +1,89 @@
+package models
+
+import "errors"
+
+type (
+	/*ContainerResources - ограничения и изоляция контейнера job. Нулевые значения заменяются настройками слейва*/
+	ContainerResources struct {
+		CPUs           float64  `yaml:"cpus" json:"cpus"`                         // количество CPU
+		Memory         int64    `yaml:"memory" json:"memory"`                     // память (MB)
+		Pids           int64    `yaml:"pids" json:"pids"`                         // максимальное количество процессов
+		Disk           int64    `yaml:"disk" json:"disk"`                         // размер файловой системы контейнера (MB), поддерживается не всеми storage драйверами
+		Network        string   `yaml:"network" json:"network"`                   // none или bridge
+		ReadOnlyRootfs *bool    `yaml:"read_only_rootfs" json:"read_only_rootfs"` // корневая файловая система только для чтения (/tmp остаётся доступным для записи)
+		CapDrop        []string `yaml:"cap_drop" json:"cap_drop"`                 // отключаемые capabilities ядра
+		User           string   `yaml:"user" json:"user"`                         // пользователь, от которого запускается контейнер
+	}
+	/*ContainerPolicy - настройки изоляции, которые job может изменить, если это разрешено слейвом*/
+	ContainerPolicy struct {
+		AllowUser         bool // user job заменяет пользователя слейва
+		AllowCapabilities bool // cap_drop job заменяет cap_drop слейва, иначе добавляется к нему
+	}
+)
+
+const (
+	// NetworkNone - контейнер без сети
+	NetworkNone = "none"
+	// NetworkBridge - контейнер в сети docker по умолчанию
+	NetworkBridge = "bridge"
+)
+
+/*Validate - job может выбрать только сеть none или bridge*/
+func (resources *ContainerResources) Validate() error {
+	if resources == nil || resources.Network == "" || validNetwork(resources.Network) {
+		return nil
+	}
+	return errors.New("network must be " + NetworkNone + " or " + NetworkBridge + ", but got " + resources.Network)
+}
+
+func validNetwork(network string) bool {
+	return network == NetworkNone || network == NetworkBridge
+}
+
+/*WithDefaults - ограничения job, в которых не заданные значения взяты из defaults. cap_drop слейва применяется всегда, а пользователь и capabilities job учитываются, только если их разрешает policy*/
+func (resources *ContainerResources) WithDefaults(defaults ContainerResources, policy ContainerPolicy) ContainerResources {
+	if resources == nil {
+		return defaults
+	}
+	result := *resources
+	if result.CPUs <= 0 {
+		result.CPUs = defaults.CPUs
+	}
+	if result.Memory <= 0 {
+		result.Memory = defaults.Memory
+	}
+	if result.Pids <= 0 {
+		result.Pids = defaults.Pids
+	}
+	if result.Disk <= 0 {
+		result.Disk = defaults.Disk
+	}
+	if !validNetwork(result.Network) {
+		result.Network = defaults.Network
+	}
+	if result.ReadOnlyRootfs == nil {
+		result.ReadOnlyRootfs = defaults.ReadOnlyRootfs
+	}
+	if !policy.AllowCapabilities || result.CapDrop == nil {
+		result.CapDrop = mergeCapabilities(defaults.CapDrop, result.CapDrop)
+	}
+	if !policy.AllowUser || result.User == "" {
+		result.User = defaults.User
+	}
+	return result
+}
+
+/*mergeCapabilities - capabilities слейва и job без повторов*/
+func mergeCapabilities(defaults, job []string) []string {
+	result := append([]string{}, defaults...)
+	for _, capability := range job {
+		exist := false
+		for _, current := range result {
+			exist = exist || current == capability
+		}
+		if !exist {
+			result = append(result, capability)
+		}
+	}
+	return result
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ContainerResourcesNetwork(t *testing.T) {
+	task := func(network string) *TaskConfig {
+		return &TaskConfig{
+			Stages: []string{"build"},
+			Jobs:   map[string]Job{"build": {Stage: "build", Resources: &ContainerResources{Network: network}}},
+		}
+	}
+	assert.Nil(t, task("").Validate())
+	assert.Nil(t, task(NetworkNone).Validate())
+	assert.Nil(t, task(NetworkBridge).Validate())
+	assert.NotNil(t, task("host").Validate())
+	assert.NotNil(t, task("container:runner").Validate())
+	assert.NotNil(t, task("custom_network").Validate())
+}
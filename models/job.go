@@ -2,12 +2,37 @@ package models
 
 /*Job - primitive which parsed from entered yaml from portal*/
 type Job struct {
-	JobName             string            `yaml:"-"`
-	Stage               string            `yaml:"stage" json:"stage"`
-	TaskID              string            `yaml:"-"`
-	Image               []string          `yaml:"image" json:"image"`
-	Timeout             int64             `yaml:"timeout" json:"timeout"`
-	RepositoryCandidate string            `yaml:"repo" json:"repo"`
-	ShellCommands       []string          `yaml:"run" json:"run"`
-	Reports             map[string]string `yaml:"reports" json:"reports"`
+	JobName             string                  `yaml:"-"`
+	Stage               string                  `yaml:"stage" json:"stage"`
+	TaskID              string                  `yaml:"-"`
+	Image               []string                `yaml:"image" json:"image"`
+	Timeout             int64                   `yaml:"timeout" json:"timeout"`
+	RepositoryCandidate string                  `yaml:"repo" json:"repo"`
+	RepositoryAuth      *RepoAuth               `yaml:"repo_auth" json:"repo_auth"`   // доступ к приватному репозиторию кандидата
+	RepositoryRef       string                  `yaml:"ref" json:"ref"`               // ветка или тег репозитория. Пусто - ветка по умолчанию
+	RepositoryCommit    string                  `yaml:"commit" json:"commit"`         // полный SHA коммита, на который переключается репозиторий
+	RepositoryDepth     int                     `yaml:"depth" json:"depth"`           // глубина истории при клонировании, 0 - вся история
+	RepositorySubmodule bool                    `yaml:"submodules" json:"submodules"` // клонировать подмодули репозитория
+	ShellCommands       []string                `yaml:"run" json:"run"`
+	Reports             map[string]ReportConfig `yaml:"reports" json:"reports"`                       // отчёты job по имени: метрики из логов или отчёты о тестах
+	Retry               *RetryPolicy            `yaml:"retry" json:"retry"`                           // повтор job при ошибке. Если не задан - используется политика задачи
+	AllowFailure        bool                    `yaml:"allow_failure" json:"allow_failure"`           // ошибка job не останавливает задачу
+	SuccessExitCodes    []int64                 `yaml:"success_exit_codes" json:"success_exit_codes"` // коды завершения, при которых job успешна. По умолчанию 0
+	Resources           *ContainerResources     `yaml:"resources" json:"resources"`                   // ограничения контейнера. Не заданные значения берутся из настроек слейва
+	Artefacts           []string                `yaml:"artefacts" json:"artefacts"`                   // пути к файлам или директориям в контейнере, которые сохраняются на мастере после выполнения job
+	Needs               []string                `yaml:"needs" json:"needs"`                           // job предыдущих этапов, артефакты которых копируются в образ job (/artefacts/{job})
+	Secrets             map[string]string       `yaml:"secrets" json:"secrets"`                       // переменные окружения контейнера job: имя секрета мастера
+}
+
+/*IsSuccessExitCode - код завершения контейнера job считается успешным*/
+func (job *Job) IsSuccessExitCode(exitCode int64) bool {
+	if len(job.SuccessExitCodes) == 0 {
+		return exitCode == 0
+	}
+	for _, successCode := range job.SuccessExitCodes {
+		if successCode == exitCode {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type (
+	/*LogLine - запись лога job. Хранится на мастере в формате JSON lines, текстовый лог строится из этих записей*/
+	LogLine struct {
+		Offset    int64     `json:"offset"` // порядковый номер строки в логе job (назначается мастером)
+		Timestamp time.Time `json:"timestamp"`
+		Stream    string    `json:"stream"`
+		Stage     string    `json:"stage,omitempty"`
+		Job       string    `json:"job,omitempty"`
+		Attempt   int       `json:"attempt,omitempty"`
+		Line      string    `json:"line"`
+	}
+)
+
+const (
+	// LogStreamStdout - стандартный вывод контейнера
+	LogStreamStdout = "stdout"
+	// LogStreamStderr - вывод ошибок контейнера
+	LogStreamStderr = "stderr"
+	// LogStreamBuild - вывод сборки образа job
+	LogStreamBuild = "build"
+)
+
+/*Render - текстовое представление записи для .log*/
+func (line LogLine) Render() string {
+	return line.Timestamp.Format("2006-01-02 15:04:05.000") + " [" + line.Stream + "] " + line.Line + "\n"
+}
+
+/*AddLine - добавление строки в лог с текущим временем. Строки stderr попадают в STDERR, остальные в STDOUT*/
+func (logs *LogsPerTask) AddLine(stream, line string) LogLine {
+	if stream == LogStreamStderr {
+		logs.STDERR = append(logs.STDERR, line+"\n")
+	} else {
+		logs.STDOUT = append(logs.STDOUT, line+"\n")
+	}
+	record := LogLine{
+		Timestamp: time.Now(),
+		Stream:    stream,
+		Line:      line,
+	}
+	logs.Records = append(logs.Records, record)
+	return record
+}
+
+/*Join - логи, за которыми следуют логи other*/
+func (logs LogsPerTask) Join(other LogsPerTask) LogsPerTask {
+	return LogsPerTask{
+		STDOUT:  append(append([]string{}, logs.STDOUT...), other.STDOUT...),
+		STDERR:  append(append([]string{}, logs.STDERR...), other.STDERR...),
+		Records: append(append([]LogLine{}, logs.Records...), other.Records...),
+	}
+}
+
+/*LogRecords - записи лога. Для логов без записей (от слейвов предыдущих версий) записи строятся из STDOUT и STDERR*/
+func (logs LogsPerTask) LogRecords() []LogLine {
+	if len(logs.Records) > 0 {
+		return logs.Records
+	}
+	now := time.Now()
+	records := []LogLine{}
+	for _, line := range logs.STDOUT {
+		records = append(records, LogLine{Timestamp: now, Stream: LogStreamStdout, Line: strings.TrimSuffix(line, "\n")})
+	}
+	for _, line := range logs.STDERR {
+		records = append(records, LogLine{Timestamp: now, Stream: LogStreamStderr, Line: strings.TrimSuffix(line, "\n")})
+	}
+	return records
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AggregateCount - количество совпадений
+	AggregateCount = "count"
+	// AggregateSum - сумма числовых значений поля
+	AggregateSum = "sum"
+	// AggregateMin - минимальное числовое значение поля
+	AggregateMin = "min"
+	// AggregateMax - максимальное числовое значение поля
+	AggregateMax = "max"
+	// AggregateLast - значение поля в последнем совпадении
+	AggregateLast = "last"
+
+	// MetricMatchKey - ключ всего совпадения для регулярных выражений без именованных групп
+	MetricMatchKey = "match"
+)
+
+var metricAggregates = map[string]bool{
+	AggregateCount: true,
+	AggregateSum:   true,
+	AggregateMin:   true,
+	AggregateMax:   true,
+	AggregateLast:  true,
+}
+
+type (
+	/*MetricReport - все совпадения регулярного выражения метрики в логах job и их агрегированное значение*/
+	MetricReport struct {
+		Matches   []map[string]string `json:"matches"`             // значения именованных групп каждого совпадения
+		Aggregate string              `json:"aggregate,omitempty"` // models.Aggregate...
+		Field     string              `json:"field,omitempty"`     // именованная группа, значения которой агрегируются
+		Value     string              `json:"value,omitempty"`     // агрегированное значение
+		Error     string              `json:"error,omitempty"`     // регулярное выражение не удалось применить
+	}
+
+	metricReportAlias MetricReport
+)
+
+/*UnmarshalJSON - поддержка метрик, сохранённых мастером в старом формате (результат FindStringSubmatch)*/
+func (metric *MetricReport) UnmarshalJSON(data []byte) error {
+	var legacy []string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*metric = MetricReport{Matches: []map[string]string{}}
+		if len(legacy) > 0 {
+			metric.Matches = append(metric.Matches, map[string]string{MetricMatchKey: legacy[0]})
+		}
+		return nil
+	}
+	var alias metricReportAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*metric = MetricReport(alias)
+	return nil
+}
+
+/*CompileMetricRegex - компиляция регулярного выражения метрики с проверкой агрегации и её поля*/
+func CompileMetricRegex(config ReportConfig) (*regexp.Regexp, error) {
+	regex, err := regexp.Compile(config.Regex)
+	if err != nil {
+		return nil, err
+	}
+	if config.Aggregate != "" && !metricAggregates[config.Aggregate] {
+		return nil, errors.New("unknown aggregate: " + config.Aggregate)
+	}
+	if config.Field == "" || config.Field == MetricMatchKey && len(namedGroups(regex)) == 0 {
+		return regex, nil
+	}
+	for _, name := range namedGroups(regex) {
+		if name == config.Field {
+			return regex, nil
+		}
+	}
+	return nil, errors.New("regex has no named group: " + config.Field)
+}
+
+/*MatchMetric - все совпадения регулярного выражения метрики в логах в виде значений именованных групп и их агрегация*/
+func MatchMetric(config ReportConfig, logs string) MetricReport {
+	result := MetricReport{Matches: []map[string]string{}, Aggregate: config.Aggregate, Field: config.Field}
+	regex, err := CompileMetricRegex(config)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	names := regex.SubexpNames()
+	for _, found := range regex.FindAllStringSubmatch(logs, -1) {
+		match := map[string]string{}
+		for index, name := range names {
+			if name != "" {
+				match[name] = found[index]
+			}
+		}
+		if len(match) == 0 {
+			match[MetricMatchKey] = found[0]
+		}
+		result.Matches = append(result.Matches, match)
+	}
+	if result.Field == "" {
+		result.Field = MetricMatchKey
+		if groups := namedGroups(regex); len(groups) > 0 {
+			result.Field = groups[0]
+		}
+	}
+	result.Calculate()
+	return result
+}
+
+/*Calculate - пересчёт агрегированного значения (например, после объединения совпадений метрик нескольких job). Нечисловые значения не учитываются в sum, min и max*/
+func (metric *MetricReport) Calculate() {
+	metric.Value = ""
+	switch metric.Aggregate {
+	case AggregateCount:
+		metric.Value = strconv.Itoa(len(metric.Matches))
+	case AggregateLast:
+		if len(metric.Matches) > 0 {
+			metric.Value = metric.Matches[len(metric.Matches)-1][metric.Field]
+		}
+	case AggregateSum, AggregateMin, AggregateMax:
+		metric.Value = metric.calculateNumeric()
+	}
+}
+
+func (metric *MetricReport) calculateNumeric() string {
+	var result float64
+	found := false
+	for _, match := range metric.Matches {
+		value, err := strconv.ParseFloat(strings.TrimSpace(match[metric.Field]), 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case !found:
+			result = value
+		case metric.Aggregate == AggregateSum:
+			result += value
+		case metric.Aggregate == AggregateMin && value < result, metric.Aggregate == AggregateMax && value > result:
+			result = value
+		}
+		found = true
+	}
+	if !found {
+		return ""
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64)
+}
+
+/*Values - значения метрики в строковом виде: агрегированное значение или значения поля всех совпадений*/
+func (metric MetricReport) Values() []string {
+	if metric.Value != "" {
+		return []string{metric.Value}
+	}
+	field := metric.Field
+	if field == "" {
+		field = MetricMatchKey
+	}
+	result := []string{}
+	for _, match := range metric.Matches {
+		if value, exist := match[field]; exist {
+			result = append(result, value)
+			continue
+		}
+		result = append(result, formatMatch(match))
+	}
+	return result
+}
+
+func formatMatch(match map[string]string) string {
+	names := make([]string, 0, len(match))
+	for name := range match {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+match[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func namedGroups(regex *regexp.Regexp) []string {
+	result := []string{}
+	for _, name := range regex.SubexpNames() {
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
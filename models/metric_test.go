@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testLogs = `--- FAIL: TestCreate (0.25s)
+--- FAIL: TestCancel (1.50s)
+ok  	pkg/a	0.004s`
+
+func Test_MatchMetricReturnsAllNamedMatches(t *testing.T) {
+	metric := MatchMetric(ReportConfig{Type: ReportTypeRegex, Regex: `--- FAIL: (?P<test>\w+) \((?P<time>[\d.]+)s\)`}, testLogs)
+	assert.Equal(t, "", metric.Error)
+	assert.Equal(t, []map[string]string{
+		{"test": "TestCreate", "time": "0.25"},
+		{"test": "TestCancel", "time": "1.50"},
+	}, metric.Matches)
+	assert.Equal(t, []string{"TestCreate", "TestCancel"}, metric.Values())
+
+	metric = MatchMetric(ReportConfig{Type: ReportTypeRegex, Regex: `ok\s+\S+`}, testLogs)
+	assert.Equal(t, []map[string]string{{MetricMatchKey: "ok  \tpkg/a"}}, metric.Matches)
+}
+
+func Test_MatchMetricAggregates(t *testing.T) {
+	regex := `--- FAIL: (?P<test>\w+) \((?P<time>[\d.]+)s\)`
+	for aggregate, expected := range map[string]string{
+		AggregateCount: "2",
+		AggregateSum:   "1.75",
+		AggregateMin:   "0.25",
+		AggregateMax:   "1.5",
+		AggregateLast:  "1.50",
+	} {
+		metric := MatchMetric(ReportConfig{Type: ReportTypeRegex, Regex: regex, Aggregate: aggregate, Field: "time"}, testLogs)
+		assert.Equal(t, expected, metric.Value, aggregate)
+	}
+	metric := MatchMetric(ReportConfig{Type: ReportTypeRegex, Regex: regex, Aggregate: AggregateSum}, testLogs)
+	assert.Equal(t, "", metric.Value, "names of tests are not numbers")
+}
+
+func Test_ValidateMetricRegex(t *testing.T) {
+	assert.Nil(t, (&ReportConfig{Type: ReportTypeRegex, Regex: `(?P<time>\d+)`, Aggregate: AggregateMax, Field: "time"}).Validate())
+	assert.NotNil(t, (&ReportConfig{Type: ReportTypeRegex, Regex: `(unclosed`}).Validate())
+	assert.NotNil(t, (&ReportConfig{Type: ReportTypeRegex, Regex: `\d+`, Aggregate: "median"}).Validate())
+	assert.NotNil(t, (&ReportConfig{Type: ReportTypeRegex, Regex: `(?P<time>\d+)`, Field: "name"}).Validate())
+
+	metric := MatchMetric(ReportConfig{Type: ReportTypeRegex, Regex: `(unclosed`}, testLogs)
+	assert.NotEqual(t, "", metric.Error)
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// ReportTypeRegex - значения метрики выделяются регулярным выражением из логов job
+	ReportTypeRegex = "regex"
+	// ReportTypeJUnit - отчёт о тестах в формате JUnit XML
+	ReportTypeJUnit = "junit"
+	// ReportTypeGoTestJSON - вывод go test -json
+	ReportTypeGoTestJSON = "gotest-json"
+	// ReportTypeTAP - отчёт о тестах в формате Test Anything Protocol
+	ReportTypeTAP = "tap"
+	// ReportTypeCobertura - отчёт о покрытии кода в формате Cobertura XML
+	ReportTypeCobertura = "cobertura"
+)
+
+const (
+	// TestStatusPassed - тест прошёл
+	TestStatusPassed = "passed"
+	// TestStatusFailed - тест упал
+	TestStatusFailed = "failed"
+	// TestStatusSkipped - тест пропущен
+	TestStatusSkipped = "skipped"
+)
+
+var reportTypes = map[string]bool{
+	ReportTypeRegex:      true,
+	ReportTypeJUnit:      true,
+	ReportTypeGoTestJSON: true,
+	ReportTypeTAP:        true,
+	ReportTypeCobertura:  true,
+}
+
+type (
+	/*ReportConfig - отчёт job. В yaml может быть задан строкой - тогда это регулярное выражение*/
+	ReportConfig struct {
+		Type  string `yaml:"type" json:"type"`
+		Path  string `yaml:"path" json:"path"`   // файл или директория с отчётами в контейнере job. Пусто - отчёт разбирается из логов job
+		Regex string `yaml:"regex" json:"regex"` // регулярное выражение для типа regex
+		// Aggregate - агрегация совпадений регулярного выражения (count, sum, min, max, last)
+		Aggregate string `yaml:"aggregate" json:"aggregate"`
+		// Field - именованная группа, значения которой агрегируются. По умолчанию - первая именованная группа
+		Field string `yaml:"field" json:"field"`
+	}
+
+	/*TestCase - результат одного теста*/
+	TestCase struct {
+		Name     string  `json:"name"`
+		Suite    string  `json:"suite,omitempty"`
+		Status   string  `json:"status"`
+		Duration float64 `json:"duration"` // секунды
+		Failure  string  `json:"failure,omitempty"`
+	}
+
+	/*TestReport - разобранный отчёт о тестах и покрытии одной job*/
+	TestReport struct {
+		Name     string     `json:"name"`
+		Type     string     `json:"type"`
+		Stage    string     `json:"stage"`
+		Job      string     `json:"job"`
+		Cases    []TestCase `json:"cases,omitempty"`
+		Total    int        `json:"total"`
+		Passed   int        `json:"passed"`
+		Failed   int        `json:"failed"`
+		Skipped  int        `json:"skipped"`
+		Duration float64    `json:"duration"`           // секунды
+		Coverage *float64   `json:"coverage,omitempty"` // процент покрытых строк, если есть в отчёте
+		Error    string     `json:"error,omitempty"`    // отчёт не удалось получить или разобрать
+	}
+
+	reportConfigAlias ReportConfig
+)
+
+/*UnmarshalYAML - поддержка старого формата отчётов (имя: регулярное выражение)*/
+func (config *ReportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var regex string
+	if err := unmarshal(&regex); err == nil {
+		*config = ReportConfig{Type: ReportTypeRegex, Regex: regex}
+		return nil
+	}
+	var alias reportConfigAlias
+	if err := unmarshal(&alias); err != nil {
+		return err
+	}
+	*config = ReportConfig(alias).withDefaultType()
+	return nil
+}
+
+/*UnmarshalJSON - поддержка старого формата отчётов (имя: регулярное выражение)*/
+func (config *ReportConfig) UnmarshalJSON(data []byte) error {
+	var regex string
+	if err := json.Unmarshal(data, &regex); err == nil {
+		*config = ReportConfig{Type: ReportTypeRegex, Regex: regex}
+		return nil
+	}
+	var alias reportConfigAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*config = ReportConfig(alias).withDefaultType()
+	return nil
+}
+
+func (config ReportConfig) withDefaultType() ReportConfig {
+	if config.Type == "" && config.Regex != "" {
+		config.Type = ReportTypeRegex
+	}
+	return config
+}
+
+/*Validate - проверка типа отчёта и его параметров*/
+func (config *ReportConfig) Validate() error {
+	if !reportTypes[config.Type] {
+		return errors.New("unknown report type: " + config.Type)
+	}
+	if config.Type != ReportTypeRegex {
+		return nil
+	}
+	if config.Regex == "" {
+		return errors.New("regex report without regex")
+	}
+	_, err := CompileMetricRegex(*config)
+	return err
+}
+
+/*Summarize - подсчёт количества тестов по статусам и общей длительности (если она не задана в отчёте)*/
+func (report *TestReport) Summarize() {
+	report.Total, report.Passed, report.Failed, report.Skipped = len(report.Cases), 0, 0, 0
+	var duration float64
+	for _, testCase := range report.Cases {
+		switch testCase.Status {
+		case TestStatusPassed:
+			report.Passed++
+		case TestStatusFailed:
+			report.Failed++
+		case TestStatusSkipped:
+			report.Skipped++
+		}
+		duration += testCase.Duration
+	}
+	if report.Duration == 0 {
+		report.Duration = duration
+	}
+}
+
+/*Append - добавление отчётов другой job: совпадения метрик с одинаковыми именами объединяются и их агрегация пересчитывается*/
+func (reports *ReportPerTask) Append(other ReportPerTask) {
+	if reports.Result == nil {
+		reports.Result = map[string]MetricReport{}
+	}
+	for name, metric := range other.Result {
+		current, exist := reports.Result[name]
+		if !exist {
+			metric.Matches = append([]map[string]string{}, metric.Matches...)
+			reports.Result[name] = metric
+			continue
+		}
+		current.Matches = append(current.Matches, metric.Matches...)
+		if current.Error == "" {
+			current.Error = metric.Error
+		}
+		current.Calculate()
+		reports.Result[name] = current
+	}
+	reports.Tests = append(reports.Tests, other.Tests...)
+}
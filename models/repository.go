@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+)
+
+const (
+	// RepoCandidateAnnotation - строка образа job, вместо которой в образ копируется репозиторий кандидата
+	RepoCandidateAnnotation = "{{repoCandidate}}"
+	// SourceArchive - код кандидата задачи загружен на мастер архивом
+	SourceArchive = "archive"
+)
+
+var (
+	commitRegex = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+	// scpRepoRegex - адрес репозитория в виде git@host:path
+	scpRepoRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*@[A-Za-z0-9][A-Za-z0-9.-]*:[^\s]+$`)
+)
+
+/*RepoCheckout - репозиторий кандидата задачи. Клонируется слейвом один раз и используется всеми job задачи*/
+type RepoCheckout struct {
+	URL        string
+	Ref        string
+	Commit     string
+	Depth      int
+	Submodules bool
+	Auth       RepoAuth // Type == "" - публичный репозиторий
+}
+
+/*RepoCheckout - репозиторий кандидата, указанный в job*/
+func (job *Job) RepoCheckout() RepoCheckout {
+	checkout := RepoCheckout{
+		URL:        job.RepositoryCandidate,
+		Ref:        job.RepositoryRef,
+		Commit:     job.RepositoryCommit,
+		Depth:      job.RepositoryDepth,
+		Submodules: job.RepositorySubmodule,
+	}
+	if job.RepositoryAuth != nil {
+		checkout.Auth = *job.RepositoryAuth
+	}
+	return checkout
+}
+
+/*UsesRepoCandidate - образ job содержит репозиторий кандидата*/
+func (job *Job) UsesRepoCandidate() bool {
+	for _, line := range job.Image {
+		if line == RepoCandidateAnnotation {
+			return true
+		}
+	}
+	return false
+}
+
+/*Repository - репозиторий кандидата задачи. Все job, в которых указан repo, должны указывать один и тот же репозиторий. URL == "" - репозитория нет*/
+func (task *TaskConfig) Repository() (RepoCheckout, error) {
+	result := RepoCheckout{}
+	for name, job := range task.Jobs {
+		if job.RepositoryCandidate == "" {
+			continue
+		}
+		checkout := job.RepoCheckout()
+		if !ValidRepoURL(checkout.URL) {
+			return RepoCheckout{}, errors.New("job " + name + " has invalid repo, only https://, ssh:// and git@host:path addresses are allowed: " + checkout.URL)
+		}
+		if checkout.Depth < 0 {
+			return RepoCheckout{}, errors.New("job " + name + " has negative repository depth")
+		}
+		if checkout.Commit != "" && !commitRegex.MatchString(checkout.Commit) {
+			return RepoCheckout{}, errors.New("job " + name + " has invalid commit, full SHA is required: " + checkout.Commit)
+		}
+		if result.URL != "" && result != checkout {
+			return RepoCheckout{}, errors.New("all jobs of task must use the same repository, but job " + name + " differs")
+		}
+		result = checkout
+	}
+	return result, nil
+}
+
+/*ValidRepoURL - репозиторий клонируется только по https, ssh или scp-подобному адресу. Локальные пути и file:// не принимаются, чтобы задача не могла склонировать репозиторий с файловой системы слейва*/
+func ValidRepoURL(repo string) bool {
+	if scpRepoRegex.MatchString(repo) {
+		return true
+	}
+	parsed, err := url.Parse(repo)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "ssh") && parsed.Host != ""
+}
+
+/*validateSource - код кандидата задачи берётся либо из архива, либо из repo job*/
+func (task *TaskConfig) validateSource() error {
+	switch task.Source {
+	case "":
+		return nil
+	case SourceArchive:
+		for name, job := range task.Jobs {
+			if job.RepositoryCandidate != "" {
+				return errors.New("task with source archive can not clone repo, but job " + name + " has it")
+			}
+		}
+		return nil
+	default:
+		return errors.New("unknown task source: " + task.Source)
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TaskRepository(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	task := &TaskConfig{
+		Stages: []string{"build", "test"},
+		Jobs: map[string]Job{
+			"build": {Stage: "build", RepositoryCandidate: "https://example.com/candidate.git", RepositoryRef: "main", RepositoryCommit: commit, RepositoryDepth: 10},
+			"test":  {Stage: "test", RepositoryCandidate: "https://example.com/candidate.git", RepositoryRef: "main", RepositoryCommit: commit, RepositoryDepth: 10},
+			"lint":  {Stage: "test"},
+		},
+	}
+	assert.Nil(t, task.Validate())
+	repository, err := task.Repository()
+	assert.Nil(t, err)
+	assert.Equal(t, RepoCheckout{URL: "https://example.com/candidate.git", Ref: "main", Commit: commit, Depth: 10}, repository)
+
+	job := task.Jobs["test"]
+	job.RepositoryRef = "develop"
+	task.Jobs["test"] = job
+	assert.NotNil(t, task.Validate())
+
+	job.RepositoryRef, job.RepositoryCommit = "main", "abc123"
+	task.Jobs["test"] = job
+	assert.NotNil(t, task.Validate())
+
+	repository, err = (&TaskConfig{Jobs: map[string]Job{"lint": {}}}).Repository()
+	assert.Nil(t, err)
+	assert.Equal(t, "", repository.URL)
+}
+
+func Test_TaskRepositoryURL(t *testing.T) {
+	task := func(repo string) *TaskConfig {
+		return &TaskConfig{Stages: []string{"build"}, Jobs: map[string]Job{"build": {Stage: "build", RepositoryCandidate: repo}}}
+	}
+	assert.Nil(t, task("https://github.com/kubitre/for_diplom.git").Validate())
+	assert.Nil(t, task("ssh://git@github.com/kubitre/for_diplom.git").Validate())
+	assert.Nil(t, task("git@github.com:kubitre/for_diplom.git").Validate())
+
+	assert.NotNil(t, task("file:///etc/runner/repo").Validate())
+	assert.NotNil(t, task("/abs/path").Validate())
+	assert.NotNil(t, task("../relative/path").Validate())
+	assert.NotNil(t, task("http://github.com/kubitre/for_diplom.git").Validate())
+	assert.NotNil(t, task("ext::sh -c touch% /tmp/pwned").Validate())
+	assert.NotNil(t, task("-uhttps://github.com/kubitre/for_diplom.git").Validate())
+	// слейв не клонирует такой репозиторий, даже если задача не прошла валидацию мастера
+	_, err := task("file:///etc/runner/repo").Repository()
+	assert.NotNil(t, err)
+}
+
+func Test_UsesRepoCandidate(t *testing.T) {
+	job := Job{Image: []string{"FROM golang", RepoCandidateAnnotation, "{{workdir repoCandidate}}"}}
+	assert.True(t, job.UsesRepoCandidate())
+	job.Image = []string{"FROM golang"}
+	assert.False(t, job.UsesRepoCandidate())
+}
+
+func Test_TaskSource(t *testing.T) {
+	task := &TaskConfig{
+		Source: SourceArchive,
+		Stages: []string{"build"},
+		Jobs:   map[string]Job{"build": {Stage: "build", Image: []string{"FROM golang", RepoCandidateAnnotation}}},
+	}
+	assert.Nil(t, task.Validate())
+
+	task.Jobs["build"] = Job{Stage: "build", RepositoryCandidate: "https://example.com/candidate.git"}
+	assert.NotNil(t, task.Validate())
+
+	task.Source = "ftp"
+	task.Jobs["build"] = Job{Stage: "build"}
+	assert.NotNil(t, task.Validate())
+}
@@ -3,12 +3,14 @@ package models
 type (
 	// LogsPerTask - логи по выполнению какой-либо джобы
 	LogsPerTask struct {
-		STDOUT []string
-		STDERR []string
+		STDOUT  []string
+		STDERR  []string
+		Records []LogLine // строки stdout, stderr и сборки образа в порядке их появления
 	}
 
-	// ReportPerTask - модель отчёта для мастера на уровне джобы
+	// ReportPerTask - модель отчёта для мастера на уровне джобы (или всех job задачи)
 	ReportPerTask struct {
-		Result map[string][]string
+		Result map[string]MetricReport `json:"metrics"` // метрики, выделенные регулярными выражениями
+		Tests  []TestReport            `json:"tests"`   // отчёты о тестах и покрытии
 	}
 )
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+type (
+	/*RetryPolicy - политика повторного выполнения job при ошибке*/
+	RetryPolicy struct {
+		Max     int      `yaml:"max" json:"max"`         // количество повторов после первой попытки
+		Backoff int64    `yaml:"backoff" json:"backoff"` // пауза перед первым повтором (мс), удваивается с каждым следующим повтором
+		On      []string `yaml:"on" json:"on"`           // ошибки, при которых job повторяется. Пусто - при любой ошибке
+	}
+)
+
+const (
+	// RetryOnBuildError - ошибка сборки образа или создания контейнера job
+	RetryOnBuildError = "build_error"
+	// RetryOnTimeout - job не завершилась за отведённое время
+	RetryOnTimeout = "timeout"
+	// RetryOnNonZeroExit - job завершилась с ошибкой
+	RetryOnNonZeroExit = "nonzero_exit"
+	// RetryOnRuntimeError - ошибка запуска контейнера job или чтения его логов
+	RetryOnRuntimeError = "runtime_error"
+
+	// MaxRetries - наибольшее количество повторов job, которое можно указать в политике
+	MaxRetries = 10
+	// MaxRetryBackoff - наибольшая пауза перед повтором
+	MaxRetryBackoff = 5 * time.Minute
+)
+
+/*Validate - количество повторов ограничено MaxRetries, чтобы задача не занимала слейв бесконечными повторами*/
+func (policy *RetryPolicy) Validate() error {
+	if policy == nil {
+		return nil
+	}
+	if policy.Max < 0 || policy.Max > MaxRetries {
+		return errors.New("retry max must be from 0 to " + strconv.Itoa(MaxRetries))
+	}
+	if policy.Backoff < 0 {
+		return errors.New("retry backoff must not be negative")
+	}
+	return nil
+}
+
+/*ShouldRetry - нужно ли повторить job после попытки attempt (начиная с 1), завершившейся ошибкой reason*/
+func (policy *RetryPolicy) ShouldRetry(reason string, attempt int) bool {
+	if policy == nil || attempt > policy.Max {
+		return false
+	}
+	if len(policy.On) == 0 {
+		return true
+	}
+	for _, retryOn := range policy.On {
+		if retryOn == reason {
+			return true
+		}
+	}
+	return false
+}
+
+/*Delay - пауза перед повтором после попытки attempt (начиная с 1). Не больше MaxRetryBackoff*/
+func (policy *RetryPolicy) Delay(attempt int) time.Duration {
+	if policy == nil || policy.Backoff <= 0 || attempt < 1 {
+		return 0
+	}
+	if policy.Backoff >= int64(MaxRetryBackoff/time.Millisecond) {
+		return MaxRetryBackoff
+	}
+	delay := time.Duration(policy.Backoff) * time.Millisecond
+	for retry := 1; retry < attempt && delay < MaxRetryBackoff; retry++ {
+		delay *= 2
+	}
+	if delay > MaxRetryBackoff {
+		return MaxRetryBackoff
+	}
+	return delay
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const (
+	// VerdictPassed - кандидат прошёл проверку: выполнены все обязательные правила и набран проходной балл
+	VerdictPassed = "passed"
+	// VerdictFailed - кандидат не прошёл проверку
+	VerdictFailed = "failed"
+	// VerdictPending - задача ещё выполняется, оценка может измениться
+	VerdictPending = "pending"
+)
+
+const (
+	// ScoreTotalTests - количество тестов в отчётах о тестах
+	ScoreTotalTests = "total_tests"
+	// ScorePassedTests - количество прошедших тестов
+	ScorePassedTests = "passed_tests"
+	// ScoreFailedTests - количество упавших тестов
+	ScoreFailedTests = "failed_tests"
+	// ScoreSkippedTests - количество пропущенных тестов
+	ScoreSkippedTests = "skipped_tests"
+	// ScoreCoverage - средний процент покрытия по отчётам, в которых он есть
+	ScoreCoverage = "coverage"
+	// ScoreSuccess - 1, если все job (или job правила) завершились успешно, иначе 0
+	ScoreSuccess = "success"
+)
+
+var scoringCheckRegex = regexp.MustCompile(`^\s*([\w.]+)\s*(>=|<=|==|!=|>|<)\s*(-?\d+(?:\.\d+)?)\s*$`)
+
+type (
+	/*Scoring - правила оценки кандидата по отчётам job*/
+	Scoring struct {
+		PassScore float64       `yaml:"pass_score" json:"pass_score"` // минимальный итоговый балл (0-100) для вердикта passed
+		Rules     []ScoringRule `yaml:"rules" json:"rules"`
+	}
+
+	/*ScoringRule - порог для значения из отчётов, например coverage >= 70. Значение - встроенное (models.Score...) или метрика с одним значением*/
+	ScoringRule struct {
+		Name     string  `yaml:"name" json:"name"`
+		Job      string  `yaml:"job" json:"job"`           // job, отчёты которой проверяются. Пусто - отчёты всех job
+		Check    string  `yaml:"check" json:"check"`       // {значение} {>=|<=|==|!=|>|<} {число}
+		Weight   float64 `yaml:"weight" json:"weight"`     // вес правила в итоговом балле, по умолчанию 1
+		Required bool    `yaml:"required" json:"required"` // без выполнения правила вердикт failed независимо от балла
+	}
+
+	/*TaskScore - итоговый балл и вердикт по задаче*/
+	TaskScore struct {
+		Score   float64      `json:"score"` // процент веса выполненных правил
+		Verdict string       `json:"verdict"`
+		Rules   []RuleResult `json:"rules"`
+	}
+
+	/*RuleResult - результат проверки одного правила*/
+	RuleResult struct {
+		Name   string   `json:"name"`
+		Check  string   `json:"check"`
+		Value  *float64 `json:"value,omitempty"`
+		Passed bool     `json:"passed"`
+		Weight float64  `json:"weight"`
+		Error  string   `json:"error,omitempty"`
+	}
+
+	scoringCheck struct {
+		variable  string
+		operation string
+		threshold float64
+	}
+)
+
+/*Validate - проверка правил оценки: условия должны разбираться, job правил - существовать в задаче*/
+func (scoring *Scoring) Validate(jobs map[string]Job) error {
+	for index, rule := range scoring.Rules {
+		if _, err := parseScoringCheck(rule.Check); err != nil {
+			return errors.New("scoring rule " + strconv.Itoa(index) + ": " + err.Error())
+		}
+		if _, exist := jobs[rule.Job]; rule.Job != "" && !exist {
+			return errors.New("scoring rule " + strconv.Itoa(index) + " uses unknown job " + rule.Job)
+		}
+		if rule.Weight < 0 {
+			return errors.New("scoring rule " + strconv.Itoa(index) + " has negative weight")
+		}
+	}
+	return nil
+}
+
+/*EvaluateScore - оценка задачи по отчётам её job (имя job: отчёты). nil - в задаче нет правил оценки*/
+func EvaluateScore(task *Task, reports map[string]ReportPerTask) *TaskScore {
+	if task.Config == nil || task.Config.Scoring == nil {
+		return nil
+	}
+	scoring := task.Config.Scoring
+	result := &TaskScore{Verdict: VerdictPassed, Rules: []RuleResult{}}
+	var totalWeight, passedWeight float64
+	requiredFailed := false
+	for _, rule := range scoring.Rules {
+		ruleResult := evaluateRule(rule, scoringValues(task, reports, rule.Job))
+		totalWeight += ruleResult.Weight
+		if ruleResult.Passed {
+			passedWeight += ruleResult.Weight
+		} else if rule.Required {
+			requiredFailed = true
+		}
+		result.Rules = append(result.Rules, ruleResult)
+	}
+	result.Score = 100
+	if totalWeight > 0 {
+		result.Score = passedWeight * 100 / totalWeight
+	}
+	switch {
+	case !task.StatusTask.IsFinal():
+		result.Verdict = VerdictPending
+	case requiredFailed || result.Score < scoring.PassScore:
+		result.Verdict = VerdictFailed
+	}
+	return result
+}
+
+func evaluateRule(rule ScoringRule, values map[string]float64) RuleResult {
+	result := RuleResult{Name: rule.Name, Check: rule.Check, Weight: rule.Weight}
+	if result.Weight == 0 {
+		result.Weight = 1
+	}
+	check, err := parseScoringCheck(rule.Check)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	value, exist := values[check.variable]
+	if !exist {
+		result.Error = "not found value in reports: " + check.variable
+		return result
+	}
+	result.Value = &value
+	result.Passed = check.matches(value)
+	return result
+}
+
+/*scoringValues - значения для правил из отчётов job (или всех job задачи, если job не задана)*/
+func scoringValues(task *Task, reports map[string]ReportPerTask, job string) map[string]float64 {
+	jobs := []string{}
+	for name := range reports {
+		if job == "" || name == job {
+			jobs = append(jobs, name)
+		}
+	}
+	sort.Strings(jobs)
+	merged := ReportPerTask{Result: map[string]MetricReport{}}
+	for _, name := range jobs {
+		merged.Append(reports[name])
+	}
+	result := map[string]float64{}
+	for name, metric := range merged.Result {
+		if values := metric.Values(); len(values) == 1 {
+			if value, err := strconv.ParseFloat(values[0], 64); err == nil {
+				result[name] = value
+			}
+		}
+	}
+	var coverage float64
+	withCoverage := 0
+	for _, report := range merged.Tests {
+		result[ScoreTotalTests] += float64(report.Total)
+		result[ScorePassedTests] += float64(report.Passed)
+		result[ScoreFailedTests] += float64(report.Failed)
+		result[ScoreSkippedTests] += float64(report.Skipped)
+		if report.Coverage != nil {
+			coverage += *report.Coverage
+			withCoverage++
+		}
+	}
+	if withCoverage > 0 {
+		result[ScoreCoverage] = coverage / float64(withCoverage)
+	}
+	result[ScoreSuccess] = 1
+	found := false
+	for _, status := range task.StatusJobs {
+		if job != "" && status.Job != job {
+			continue
+		}
+		found = true
+		if status.StatusIndex != SUCCESS {
+			result[ScoreSuccess] = 0
+		}
+	}
+	if !found {
+		result[ScoreSuccess] = 0
+	}
+	return result
+}
+
+func parseScoringCheck(check string) (scoringCheck, error) {
+	found := scoringCheckRegex.FindStringSubmatch(check)
+	if found == nil {
+		return scoringCheck{}, errors.New("can not parse check: " + check)
+	}
+	threshold, err := strconv.ParseFloat(found[3], 64)
+	if err != nil {
+		return scoringCheck{}, err
+	}
+	return scoringCheck{variable: found[1], operation: found[2], threshold: threshold}, nil
+}
+
+func (check scoringCheck) matches(value float64) bool {
+	switch check.operation {
+	case ">=":
+		return value >= check.threshold
+	case "<=":
+		return value <= check.threshold
+	case ">":
+		return value > check.threshold
+	case "<":
+		return value < check.threshold
+	case "==":
+		return value == check.threshold
+	case "!=":
+		return value != check.threshold
+	}
+	return false
+}
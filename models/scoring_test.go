@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scoringTask(status TaskStatusIndx, rules ...ScoringRule) *Task {
+	return &Task{
+		ID:         "task",
+		StatusTask: status,
+		StatusJobs: []JobStatus{{Job: "build", StatusIndex: SUCCESS}, {Job: "test", StatusIndex: FAILED}},
+		Config:     &TaskConfig{Scoring: &Scoring{PassScore: 50, Rules: rules}},
+	}
+}
+
+func Test_EvaluateScore(t *testing.T) {
+	coverage := 75.0
+	reports := map[string]ReportPerTask{
+		"lint": {Result: map[string]MetricReport{"warnings": {Aggregate: AggregateCount, Value: "3"}}},
+		"test": {Tests: []TestReport{{Total: 10, Passed: 9, Failed: 1, Coverage: &coverage}}},
+	}
+	task := scoringTask(FAILED,
+		ScoringRule{Name: "coverage", Check: "coverage >= 70", Weight: 3},
+		ScoringRule{Name: "tests", Check: "failed_tests == 0"},
+		ScoringRule{Name: "lint", Job: "lint", Check: "warnings <= 5"},
+		ScoringRule{Name: "build", Job: "build", Check: "success == 1", Required: true},
+		ScoringRule{Name: "missing", Check: "complexity < 10", Weight: 2},
+	)
+	score := EvaluateScore(task, reports)
+	assert.Equal(t, 5, len(score.Rules))
+	assert.Equal(t, []bool{true, false, true, true, false}, []bool{score.Rules[0].Passed, score.Rules[1].Passed, score.Rules[2].Passed, score.Rules[3].Passed, score.Rules[4].Passed})
+	assert.Equal(t, 1.0, *score.Rules[1].Value)
+	assert.NotEqual(t, "", score.Rules[4].Error)
+	assert.Equal(t, 62.5, score.Score)
+	assert.Equal(t, VerdictPassed, score.Verdict)
+
+	task.Config.Scoring.Rules = append(task.Config.Scoring.Rules, ScoringRule{Name: "all", Check: "success == 1", Required: true})
+	assert.Equal(t, VerdictFailed, EvaluateScore(task, reports).Verdict)
+
+	task.StatusTask = RUNNING
+	assert.Equal(t, VerdictPending, EvaluateScore(task, reports).Verdict)
+
+	assert.Nil(t, EvaluateScore(&Task{}, reports))
+}
+
+func Test_ValidateScoring(t *testing.T) {
+	jobs := map[string]Job{"test": {}}
+	assert.Nil(t, (&Scoring{Rules: []ScoringRule{{Job: "test", Check: "coverage >= 70.5"}}}).Validate(jobs))
+	assert.NotNil(t, (&Scoring{Rules: []ScoringRule{{Check: "coverage is high"}}}).Validate(jobs))
+	assert.NotNil(t, (&Scoring{Rules: []ScoringRule{{Job: "lint", Check: "coverage >= 70"}}}).Validate(jobs))
+	assert.NotNil(t, (&Scoring{Rules: []ScoringRule{{Check: "coverage >= 70", Weight: -1}}}).Validate(jobs))
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// RepoAuthToken - доступ к репозиторию по HTTP(S) с токеном
+	RepoAuthToken = "token"
+	// RepoAuthSSH - доступ к репозиторию по SSH с приватным ключом
+	RepoAuthSSH = "ssh"
+
+	// SecretMask - замена значений секретов в логах job
+	SecretMask = "[MASKED]"
+	// minMaskedSecretLength - более короткие значения не маскируются, иначе лог становится нечитаемым
+	minMaskedSecretLength = 4
+)
+
+var secretNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type (
+	/*RepoAuth - доступ к приватному репозиторию кандидата через секреты мастера*/
+	RepoAuth struct {
+		Type       string `yaml:"type" json:"type"`               // token или ssh
+		Secret     string `yaml:"secret" json:"secret"`           // секрет с токеном или приватным ключом SSH
+		Username   string `yaml:"username" json:"username"`       // пользователь для token (oauth2 для GitLab), по умолчанию git
+		KnownHosts string `yaml:"known_hosts" json:"known_hosts"` // секрет с known_hosts для ssh. Пусто - ~/.ssh/known_hosts слейва
+	}
+
+	/*SecretValues - значения секретов задачи по имени. Передаются слейву вместе с задачей и не сохраняются на мастере*/
+	SecretValues map[string]string
+)
+
+/*ValidSecretName - имя секрета или переменной окружения: латинские буквы, цифры и _*/
+func ValidSecretName(name string) bool {
+	return secretNameRegex.MatchString(name)
+}
+
+/*Validate - проверка типа доступа и имён секретов*/
+func (auth *RepoAuth) Validate() error {
+	if auth.Type != RepoAuthToken && auth.Type != RepoAuthSSH {
+		return errors.New("unknown repo_auth type: " + auth.Type)
+	}
+	if !ValidSecretName(auth.Secret) {
+		return errors.New("repo_auth has invalid secret name: " + auth.Secret)
+	}
+	if auth.KnownHosts != "" && !ValidSecretName(auth.KnownHosts) {
+		return errors.New("repo_auth has invalid known_hosts secret name: " + auth.KnownHosts)
+	}
+	return nil
+}
+
+/*SecretNames - имена всех секретов, используемых job задачи*/
+func (task *TaskConfig) SecretNames() []string {
+	names := map[string]bool{}
+	for _, job := range task.Jobs {
+		for _, secret := range job.Secrets {
+			names[secret] = true
+		}
+		if job.RepositoryAuth != nil {
+			names[job.RepositoryAuth.Secret] = true
+			if job.RepositoryAuth.KnownHosts != "" {
+				names[job.RepositoryAuth.KnownHosts] = true
+			}
+		}
+	}
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+/*Env - переменные окружения job (ИМЯ=значение) из секретов задачи*/
+func (job *Job) Env(values SecretValues) []string {
+	names := make([]string, 0, len(job.Secrets))
+	for name := range job.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, name+"="+values[job.Secrets[name]])
+	}
+	return result
+}
+
+/*String - значения секретов не выводятся в логи слейва и мастера*/
+func (values SecretValues) String() string {
+	return "[REDACTED]"
+}
+
+/*Mask - замена значений секретов в строке лога. Многострочные значения (ключи SSH) маскируются по строкам*/
+func (values SecretValues) Mask(line string) string {
+	parts := []string{}
+	for _, value := range values {
+		for _, part := range strings.Split(value, "\n") {
+			if part = strings.TrimSpace(part); len(part) >= minMaskedSecretLength {
+				parts = append(parts, part)
+			}
+		}
+	}
+	// длинные значения маскируются первыми, чтобы их не разбило маскирование вложенных в них коротких
+	sort.Slice(parts, func(i, j int) bool { return len(parts[i]) > len(parts[j]) })
+	for _, part := range parts {
+		line = strings.Replace(line, part, SecretMask, -1)
+	}
+	return line
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SecretValuesMask(t *testing.T) {
+	values := SecretValues{
+		"TOKEN":      "glpat-abcdef",
+		"PREFIX":     "glpat",
+		"SHORT":      "abc",
+		"DEPLOY_KEY": "-----BEGIN KEY-----\nc2VjcmV0\n-----END KEY-----",
+	}
+	assert.Equal(t, "token [MASKED] and [MASKED]-xyz, abc", values.Mask("token glpat-abcdef and glpat-xyz, abc"))
+	assert.Equal(t, "key: [MASKED]", values.Mask("key: c2VjcmV0"))
+	assert.Equal(t, "[REDACTED]", fmt.Sprint(values))
+	assert.NotContains(t, fmt.Sprintf("%v", &TaskConfig{SecretValues: values}), "glpat")
+}
+
+func Test_TaskSecrets(t *testing.T) {
+	task := &TaskConfig{
+		Stages: []string{"test"},
+		Jobs: map[string]Job{
+			"test": {
+				Stage:          "test",
+				RepositoryAuth: &RepoAuth{Type: RepoAuthSSH, Secret: "DEPLOY_KEY", KnownHosts: "HOSTS"},
+				Secrets:        map[string]string{"API_TOKEN": "TOKEN", "DB_PASSWORD": "DB"},
+			},
+		},
+	}
+	assert.Nil(t, task.Validate())
+	assert.Equal(t, []string{"DB", "DEPLOY_KEY", "HOSTS", "TOKEN"}, task.SecretNames())
+	job := task.Jobs["test"]
+	assert.Equal(t, []string{"API_TOKEN=token", "DB_PASSWORD="}, job.Env(SecretValues{"TOKEN": "token"}))
+
+	job.RepositoryAuth = &RepoAuth{Type: "password", Secret: "DEPLOY_KEY"}
+	task.Jobs["test"] = job
+	assert.NotNil(t, task.Validate())
+	job.RepositoryAuth = nil
+	job.Secrets = map[string]string{"API-TOKEN": "TOKEN"}
+	task.Jobs["test"] = job
+	assert.NotNil(t, task.Validate())
+}
@@ -4,12 +4,25 @@ type (
 	/*Task - description for task*/
 	Task struct {
 		ID            string
-		SlaveIndex    int
+		SlaveID       string // пустой, пока задача ожидает в очереди мастера
 		StatusTask    TaskStatusIndx
 		Stage         string
 		StatusJobs    []JobStatus
 		TimeCreated   int64
 		TimeFinishing int64
+		Priority      int
+		QueuedAt      int64         // время постановки в очередь (unix nano), определяет порядок в очереди
+		Config        *TaskConfig   // конфигурация задачи для отправки на слейв
+		Attempts      []TaskAttempt // история отправок задачи на слейвы
+		Commit        string        // SHA коммита репозитория кандидата, с которым выполняется задача
+	}
+
+	/*TaskAttempt - попытка выполнения задачи на слейве*/
+	TaskAttempt struct {
+		SlaveID   string
+		TimeStart int64
+		TimeEnd   int64  // -1, пока попытка выполняется
+		Reason    string // причина завершения попытки
 	}
 
 	/*JobStatus - статус выполненной\не выполненной джобы*/
@@ -21,12 +34,14 @@ type (
 
 	EhancedTaskForView struct {
 		ID            string
-		SlaveIndex    int
+		SlaveID       string
 		StatusTask    string
 		Stage         string
 		StatusJobs    []EnhancedJobStatus
 		TimeCreated   int64
 		TimeFinishing int64
+		Attempts      []TaskAttempt
+		Commit        string
 	}
 
 	EnhancedJobStatus struct {
@@ -50,6 +65,17 @@ const (
 	FAILED = 4 // task was failed
 	// SUCCESS - task was successfully
 	SUCCESS = 5 // task was successfull
+	// LOST - slave executor with task disappeared and task can not be rescheduled anymore
+	LOST = 6
+	// TIMEOUT - task or job was not finished in time
+	TIMEOUT = 7
+)
+
+const (
+	// AttemptReasonSlaveLost - слейв, выполнявший задачу, пропал из consul
+	AttemptReasonSlaveLost = "slave executor lost"
+	// AttemptReasonNotSent - задача не была принята слейвом и вернулась в очередь
+	AttemptReasonNotSent = "can not send task to slave executor"
 )
 
 /*GetString - строковое представление статуса*/
@@ -65,11 +91,49 @@ func (taskStatus TaskStatusIndx) GetString() string {
 		return "fail"
 	case SUCCESS:
 		return "success"
+	case LOST:
+		return "lost"
+	case TIMEOUT:
+		return "timeout"
 	default:
 		return "unknown"
 	}
 }
 
+/*IsFinal - статус является конечным и больше не изменится*/
+func (taskStatus TaskStatusIndx) IsFinal() bool {
+	return taskStatus == FAILED || taskStatus == SUCCESS || taskStatus == CANCELED || taskStatus == LOST || taskStatus == TIMEOUT
+}
+
+/*StartAttempt - начало новой попытки выполнения задачи на слейве*/
+func (task *Task) StartAttempt(slaveID string, timeStart int64) {
+	task.Attempts = append(task.Attempts, TaskAttempt{
+		SlaveID:   slaveID,
+		TimeStart: timeStart,
+		TimeEnd:   -1,
+	})
+}
+
+/*FinishAttempt - завершение текущей попытки выполнения задачи с указанием причины*/
+func (task *Task) FinishAttempt(reason string, timeEnd int64) {
+	if len(task.Attempts) == 0 || task.Attempts[len(task.Attempts)-1].TimeEnd != -1 {
+		return
+	}
+	task.Attempts[len(task.Attempts)-1].TimeEnd = timeEnd
+	task.Attempts[len(task.Attempts)-1].Reason = reason
+}
+
+/*CountAttempts - количество попыток, завершившихся по указанной причине*/
+func (task *Task) CountAttempts(reason string) int {
+	count := 0
+	for _, attempt := range task.Attempts {
+		if attempt.Reason == reason {
+			count++
+		}
+	}
+	return count
+}
+
 func (jobstatus JobStatus) ConvertToPayload() EnhancedJobStatus {
 	return EnhancedJobStatus{
 		StatusIndex:   jobstatus.StatusIndex.GetString(),
@@ -86,11 +150,13 @@ func (task Task) ConvertToPayload() EhancedTaskForView {
 	return EhancedTaskForView{
 		ID:            task.ID,
 		Stage:         task.Stage,
-		SlaveIndex:    task.SlaveIndex,
+		SlaveID:       task.SlaveID,
 		StatusJobs:    jobsEnhanced,
 		StatusTask:    task.StatusTask.GetString(),
 		TimeCreated:   task.TimeCreated,
 		TimeFinishing: task.TimeFinishing,
+		Attempts:      task.Attempts,
+		Commit:        task.Commit,
 	}
 }
 
@@ -2,32 +2,97 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net/url"
 )
 
 type (
 	/*TaskConfig - configuration task by description jobs, stages, identifier of task*/
 	TaskConfig struct {
-		Jobs   map[string]Job `yaml:"jobs" json:"jobs"`
-		Stages []string       `yaml:"stages" json:"stages"`
-		TaskID string         `yaml:"taskID" json:"taskID"`
+		Jobs        map[string]Job `yaml:"jobs" json:"jobs"`
+		Stages      []string       `yaml:"stages" json:"stages"`
+		TaskID      string         `yaml:"taskID" json:"taskID"`
+		Priority    int            `yaml:"priority" json:"priority"`         // задачи с большим приоритетом отправляются на слейвы раньше
+		SlaveLabels []string       `yaml:"slave_labels" json:"slave_labels"` // метки слейва, необходимые задаче (учитываются стратегией AFFINITY)
+		Retry       *RetryPolicy   `yaml:"retry" json:"retry"`               // политика повтора для job без собственной политики
+		Timeout     int64          `yaml:"timeout" json:"timeout"`           // таймаут выполнения всей задачи на слейве (мс), 0 - без ограничения
+		Scoring     *Scoring       `yaml:"scoring" json:"scoring"`           // оценка кандидата по отчётам job после выполнения задачи
+		CallbackURL string         `yaml:"callback_url" json:"callback_url"` // адрес, на который мастер отправляет изменения статусов задачи и её job
+		Source      string         `yaml:"source" json:"source"`             // archive - код кандидата загружается архивом до создания задачи, пусто - из repo job
+		// SecretValues - значения секретов job, добавляемые мастером при отправке задачи слейву
+		SecretValues SecretValues `yaml:"-" json:"secret_values,omitempty"`
 	}
 )
 
-// Validate - валидация входящего задания в исполняющий модуль
-func (task *TaskConfig) Validate() bool {
-	return true
+// Validate - валидация входящего задания в исполняющий модуль: адрес callback, правила оценки, репозиторий кандидата, секреты и отчёты job должны разбираться, job могут зависеть только от job предыдущих этапов
+func (task *TaskConfig) Validate() error {
+	if task.CallbackURL != "" {
+		if callback, err := url.Parse(task.CallbackURL); err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+			return errors.New("callback_url must be absolute http or https address")
+		}
+	}
+	if task.Scoring != nil {
+		if err := task.Scoring.Validate(task.Jobs); err != nil {
+			return err
+		}
+	}
+	if _, err := task.Repository(); err != nil {
+		return err
+	}
+	if err := task.validateSource(); err != nil {
+		return err
+	}
+	if err := task.Retry.Validate(); err != nil {
+		return err
+	}
+	stageIndex := map[string]int{}
+	for index, stage := range task.Stages {
+		stageIndex[stage] = index
+	}
+	for name, job := range task.Jobs {
+		if job.RepositoryAuth != nil {
+			if err := job.RepositoryAuth.Validate(); err != nil {
+				return errors.New("job " + name + ": " + err.Error())
+			}
+		}
+		if err := job.Resources.Validate(); err != nil {
+			return errors.New("job " + name + ": " + err.Error())
+		}
+		if err := job.Retry.Validate(); err != nil {
+			return errors.New("job " + name + ": " + err.Error())
+		}
+		for env, secret := range job.Secrets {
+			if !ValidSecretName(env) || !ValidSecretName(secret) {
+				return errors.New("job " + name + " has invalid secret " + env + ": " + secret)
+			}
+		}
+		for reportName, report := range job.Reports {
+			if err := report.Validate(); err != nil {
+				return errors.New("job " + name + " has invalid report " + reportName + ": " + err.Error())
+			}
+		}
+		for _, need := range job.Needs {
+			needJob, exist := task.Jobs[need]
+			if !exist {
+				return errors.New("job " + name + " needs unknown job " + need)
+			}
+			current, currentExist := stageIndex[job.Stage]
+			previous, previousExist := stageIndex[needJob.Stage]
+			if !currentExist || !previousExist || previous >= current {
+				return errors.New("job " + name + " can need only jobs of previous stages, but " + need + " is not")
+			}
+		}
+	}
+	return nil
 }
 
 // ToByteArray - конвертация текущей модели в массив байтов для передачи по сети
 func (task *TaskConfig) ToByteArray() ([]byte, error) {
 	bts, err := json.Marshal(task)
-	log.Println(string(bts))
 	if err != nil {
 		return nil, err
 	}
-	var model TaskConfig
-	json.Unmarshal(bts, &model)
-	log.Println("after unmarshaling: ", model)
+	log.Println("task config was marshaled: ", task.TaskID)
 	return bts, nil
 }
@@ -0,0 +1,32 @@
+package models
+
+const (
+	// TaskEventTask - изменился статус задачи
+	TaskEventTask = "task"
+	// TaskEventJob - изменился статус job
+	TaskEventJob = "job"
+)
+
+type (
+	/*TaskEvent - изменение статуса задачи или job, отправляемое на webhook*/
+	TaskEvent struct {
+		ID     string `json:"id"`   // уникальный идентификатор события (получатель может отбрасывать повторы)
+		Type   string `json:"type"` // models.TaskEvent...
+		TaskID string `json:"task_id"`
+		Job    string `json:"job,omitempty"`
+		Stage  string `json:"stage,omitempty"`
+		Status string `json:"status"`
+		Time   int64  `json:"time"`
+	}
+
+	/*WebhookDelivery - результат доставки события на один webhook*/
+	WebhookDelivery struct {
+		URL          string    `json:"url"`
+		Event        TaskEvent `json:"event"`
+		Attempts     int       `json:"attempts"`
+		Delivered    bool      `json:"delivered"`
+		StatusCode   int       `json:"status_code,omitempty"` // код ответа на последнюю попытку
+		Error        string    `json:"error,omitempty"`
+		TimeFinished int64     `json:"time_finished"`
+	}
+)
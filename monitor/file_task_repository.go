@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const taskFileExtension = ".json"
+
+/*fileTaskRepository - хранилище задач на файловой системе (один json файл на задачу) с кэшем в памяти*/
+type fileTaskRepository struct {
+	*memoryTaskRepository
+	writeMutex sync.Mutex
+	path       string
+}
+
+/*NewFileTaskRepository - хранилище задач в директории path. Все сохранённые ранее задачи загружаются при старте*/
+func NewFileTaskRepository(path string) (TaskRepository, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+	repository := &fileTaskRepository{
+		memoryTaskRepository: newMemoryTaskRepository(),
+		path:                 path,
+	}
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != taskFileExtension {
+			continue
+		}
+		data, errRead := ioutil.ReadFile(filepath.Join(path, file.Name()))
+		if errRead != nil {
+			return nil, errRead
+		}
+		var task models.Task
+		if errUnmarshal := json.Unmarshal(data, &task); errUnmarshal != nil {
+			log.Error("can not load task from file: ", file.Name(), " by error: ", errUnmarshal)
+			continue
+		}
+		repository.memoryTaskRepository.Save(task)
+	}
+	log.Info("loaded tasks from task store: ", len(repository.tasks))
+	return repository, nil
+}
+
+func (repository *fileTaskRepository) Save(task models.Task) error {
+	fileName, err := repository.taskFileName(task.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	repository.writeMutex.Lock()
+	defer repository.writeMutex.Unlock()
+	// запись через временный файл, чтобы при падении мастера не остался наполовину записанный файл задачи
+	if errWrite := ioutil.WriteFile(fileName+".tmp", data, 0666); errWrite != nil {
+		return errWrite
+	}
+	if errRename := os.Rename(fileName+".tmp", fileName); errRename != nil {
+		return errRename
+	}
+	return repository.memoryTaskRepository.Save(task)
+}
+
+func (repository *fileTaskRepository) taskFileName(taskID string) (string, error) {
+	if taskID == "" || taskID == "." || taskID == ".." || strings.ContainsAny(taskID, `/\`) {
+		return "", errors.New("can not save task with invalid id: " + taskID)
+	}
+	return filepath.Join(repository.path, taskID+taskFileExtension), nil
+}
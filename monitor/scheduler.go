@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"errors"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	/*Scheduler - стратегия выбора слейва для задачи из очереди. Вызывается только из DispatchQueuedTasks (под dispatchMutex)*/
+	Scheduler interface {
+		// ChooseSlave - выбор слейва, у которого есть место под задачу, из всех доступных слейвов (в порядке их обнаружения)
+		ChooseSlave(task models.Task, candidates []SlaveCandidate) (Slave, error)
+	}
+
+	/*SlaveCandidate - доступный слейв и его текущая загрузка*/
+	SlaveCandidate struct {
+		Slave    Slave
+		Load     int // количество выполняющихся на слейве задач
+		Capacity int // максимальное количество выполняющихся на слейве задач
+	}
+
+	/*roundRobinScheduler - выбор следующего слейва после последнего использованного (по идентификатору, а не по индексу, т.к. список слейвов меняется)*/
+	roundRobinScheduler struct {
+		lastUsedSlaveID string
+	}
+
+	/*leastLoadedScheduler - выбор слейва с наименьшим количеством выполняющихся задач*/
+	leastLoadedScheduler struct{}
+
+	/*binPackingScheduler - выбор наиболее загруженного слейва, у которого ещё есть место, чтобы остальные слейвы оставались свободными*/
+	binPackingScheduler struct{}
+
+	/*affinityScheduler - выбор среди слейвов, у которых есть все метки (consul tags) из конфигурации задачи, наименее загруженного*/
+	affinityScheduler struct {
+		leastLoaded leastLoadedScheduler
+	}
+)
+
+var errAllSlavesBusy = errors.New("all slave executors are busy")
+
+/*HasSpace - на слейв можно отправить ещё одну задачу*/
+func (candidate SlaveCandidate) HasSpace() bool {
+	return candidate.Load < candidate.Capacity
+}
+
+/*NewRoundRobinScheduler - слейвы выбираются по кругу*/
+func NewRoundRobinScheduler() Scheduler {
+	return &roundRobinScheduler{}
+}
+
+/*NewLeastLoadedScheduler - выбирается слейв с наименьшим количеством выполняющихся задач*/
+func NewLeastLoadedScheduler() Scheduler {
+	return leastLoadedScheduler{}
+}
+
+/*NewBinPackingScheduler - задачи отправляются на наиболее загруженный слейв, пока у него есть место*/
+func NewBinPackingScheduler() Scheduler {
+	return binPackingScheduler{}
+}
+
+/*NewAffinityScheduler - задача отправляется только на слейв, у которого есть все метки из SlaveLabels задачи*/
+func NewAffinityScheduler() Scheduler {
+	return affinityScheduler{}
+}
+
+func (scheduler *roundRobinScheduler) ChooseSlave(task models.Task, candidates []SlaveCandidate) (Slave, error) {
+	lastUsedIndex := -1
+	for index, candidate := range candidates {
+		if candidate.Slave.ID == scheduler.lastUsedSlaveID {
+			lastUsedIndex = index
+			break
+		}
+	}
+	for shift := 1; shift <= len(candidates); shift++ {
+		candidate := candidates[(lastUsedIndex+shift)%len(candidates)]
+		if candidate.HasSpace() {
+			scheduler.lastUsedSlaveID = candidate.Slave.ID
+			return candidate.Slave, nil
+		}
+	}
+	return Slave{}, errAllSlavesBusy
+}
+
+func (scheduler leastLoadedScheduler) ChooseSlave(task models.Task, candidates []SlaveCandidate) (Slave, error) {
+	chosen := -1
+	for index, candidate := range candidates {
+		if candidate.HasSpace() && (chosen < 0 || candidate.Load < candidates[chosen].Load) {
+			chosen = index
+		}
+	}
+	if chosen < 0 {
+		return Slave{}, errAllSlavesBusy
+	}
+	return candidates[chosen].Slave, nil
+}
+
+func (scheduler binPackingScheduler) ChooseSlave(task models.Task, candidates []SlaveCandidate) (Slave, error) {
+	chosen := -1
+	for index, candidate := range candidates {
+		if candidate.HasSpace() && (chosen < 0 || candidate.Load > candidates[chosen].Load) {
+			chosen = index
+		}
+	}
+	if chosen < 0 {
+		return Slave{}, errAllSlavesBusy
+	}
+	return candidates[chosen].Slave, nil
+}
+
+func (scheduler affinityScheduler) ChooseSlave(task models.Task, candidates []SlaveCandidate) (Slave, error) {
+	var labels []string
+	if task.Config != nil {
+		labels = task.Config.SlaveLabels
+	}
+	suitable := []SlaveCandidate{}
+	for _, candidate := range candidates {
+		if candidate.HasSpace() && candidate.Slave.HasLabels(labels) {
+			suitable = append(suitable, candidate)
+		}
+	}
+	if len(suitable) == 0 {
+		return Slave{}, errors.New("no free slave executor has labels required by task: " + task.ID)
+	}
+	return scheduler.leastLoaded.ChooseSlave(task, suitable)
+}
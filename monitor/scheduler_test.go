@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func candidates(loads ...int) []SlaveCandidate {
+	result := []SlaveCandidate{}
+	for index, load := range loads {
+		result = append(result, SlaveCandidate{
+			Slave:    Slave{ID: string(rune('a' + index))},
+			Load:     load,
+			Capacity: 3,
+		})
+	}
+	return result
+}
+
+func Test_RoundRobinSchedulerSkipsBusySlaves(t *testing.T) {
+	scheduler := NewRoundRobinScheduler()
+	slaves := candidates(0, 3, 0)
+	slave, _ := scheduler.ChooseSlave(models.Task{}, slaves)
+	assert.Equal(t, "a", slave.ID)
+	slave, _ = scheduler.ChooseSlave(models.Task{}, slaves)
+	assert.Equal(t, "c", slave.ID)
+	slave, _ = scheduler.ChooseSlave(models.Task{}, slaves)
+	assert.Equal(t, "a", slave.ID)
+
+	_, err := scheduler.ChooseSlave(models.Task{}, candidates(3, 3))
+	assert.NotNil(t, err)
+}
+
+func Test_LeastLoadedScheduler(t *testing.T) {
+	scheduler := NewLeastLoadedScheduler()
+	slave, _ := scheduler.ChooseSlave(models.Task{}, candidates(2, 1, 1, 3))
+	assert.Equal(t, "b", slave.ID)
+
+	_, err := scheduler.ChooseSlave(models.Task{}, candidates(3))
+	assert.NotNil(t, err)
+}
+
+func Test_BinPackingScheduler(t *testing.T) {
+	scheduler := NewBinPackingScheduler()
+	slave, _ := scheduler.ChooseSlave(models.Task{}, candidates(1, 2, 3, 2))
+	assert.Equal(t, "b", slave.ID)
+
+	_, err := scheduler.ChooseSlave(models.Task{}, []SlaveCandidate{})
+	assert.NotNil(t, err)
+}
+
+func Test_AffinityScheduler(t *testing.T) {
+	scheduler := NewAffinityScheduler()
+	slaves := candidates(0, 2, 1)
+	slaves[1].Slave.Tags = []string{"image:golang", "cpu:4"}
+	slaves[2].Slave.Tags = []string{"image:golang"}
+	task := models.Task{ID: "task", Config: &models.TaskConfig{SlaveLabels: []string{"image:golang"}}}
+
+	slave, _ := scheduler.ChooseSlave(task, slaves)
+	assert.Equal(t, "c", slave.ID)
+
+	task.Config.SlaveLabels = []string{"image:golang", "cpu:4"}
+	slave, _ = scheduler.ChooseSlave(task, slaves)
+	assert.Equal(t, "b", slave.ID)
+
+	slaves[1].Load = 3
+	_, err := scheduler.ChooseSlave(task, slaves)
+	assert.NotNil(t, err)
+
+	// задача без меток может выполняться на любом слейве
+	slave, _ = scheduler.ChooseSlave(models.Task{ID: "any"}, slaves)
+	assert.Equal(t, "a", slave.ID)
+}
+
+func Test_DispatchWithAffinityKeepsOnlyUnsuitableTaskInQueue(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring, err := InitializeNewSlaveMonitoring(10, NewMemoryTaskRepository(), NewAffinityScheduler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slave := serviceEntry(t, "slave", server)
+	slave.Service.Tags = []string{"slave", "image:golang"}
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{slave})
+
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "python", Priority: 10, SlaveLabels: []string{"image:python"}}))
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "golang", SlaveLabels: []string{"image:golang"}}))
+	monitoring.DispatchQueuedTasks()
+
+	queue := monitoring.GetQueue()
+	assert.Equal(t, 1, len(queue))
+	assert.Equal(t, "python", queue[0].ID)
+	task, _ := monitoring.GetTaskStatus("golang")
+	assert.Equal(t, "slave", task.SlaveID)
+}
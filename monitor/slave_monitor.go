@@ -1,36 +1,48 @@
 package monitor
 
 import (
-	"bytes"
 	"errors"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/kubitre/diplom/models"
 	"github.com/kubitre/diplom/payloads"
+	"github.com/kubitre/diplom/runner_auth"
 	log "github.com/sirupsen/logrus"
 )
 
 type (
-	/*SlaveMonitoring - monitoring for current available workers, current state of tasks*/
+	/*SlaveMonitoring - monitoring for current available workers, current state of tasks. Безопасен для конкурентного использования*/
 	SlaveMonitoring struct {
-		SlavesAvailable          []Slave
-		LastUsingService         int
-		AllTask                  []models.Task
-		CurrentExecutingTask     []int
-		History                  []int // TODO: Change from currentTasks to this place
+		slavesMutex              sync.RWMutex
+		slavesAvailable          []Slave
+		scheduler                Scheduler  // стратегия выбора слейва для задачи из очереди
+		tasksMutex               sync.Mutex // защищает чтение-изменение-запись задач в Tasks
+		dispatchMutex            sync.Mutex // одновременно из очереди отправляет задачи только один вызов
+		dispatchSignal           chan struct{}
+		Tasks                    TaskRepository // все задачи мастера (выполняющиеся и история)
 		MaxExecutingTaskPerSlave int
+		MaxReschedulePerTask     int                     // сколько раз задача может быть перезапущена после пропажи слейва
+		DefaultTaskTimeout       int64                   // таймаут задачи (мс), если он не задан в конфигурации задачи. 0 - без ограничения
+		Listener                 TaskEventListener       // получатель изменений статусов задач и job, nil - изменения никуда не отправляются
+		Auth                     *runner_auth.RunnerAuth // подпись запросов к слейвам, nil - запросы не подписываются
+		Secrets                  SecretResolver          // значения секретов задач для слейвов, nil - задачи с секретами не выполняются
+	}
+
+	/*SecretResolver - расшифровка секретов задачи перед её отправкой на слейв*/
+	SecretResolver interface {
+		Resolve(names []string) (models.SecretValues, error)
 	}
 
 	/*Slave - configuration of slave available*/
 	Slave struct {
-		ID                  string
-		Address             string
-		Port                int
-		CurrentExecuteTasks []int // index of SlaveMonitoring.CurrentTasks
-		HistoryTasks        []int // index of executed tasks
+		ID      string
+		Address string
+		Port    int
+		Tags    []string // метки слейва из consul (например, доступные базовые образы, количество CPU)
 	}
 
 	// SlaveStatus - статус слейв модуля
@@ -43,26 +55,36 @@ const (
 )
 
 /*InitializeNewSlaveMonitoring - инициализация части мониторинга слейв модулей*/
-func InitializeNewSlaveMonitoring(maxTaskPerSlave int) (*SlaveMonitoring, error) {
+func InitializeNewSlaveMonitoring(maxTaskPerSlave int, tasks TaskRepository, scheduler Scheduler) (*SlaveMonitoring, error) {
 	if maxTaskPerSlave == 0 {
 		return nil, errors.New("minimal task executing per slave is 1")
 	}
+	if tasks == nil {
+		return nil, errors.New("task repository can not be nil")
+	}
+	if scheduler == nil {
+		return nil, errors.New("scheduler can not be nil")
+	}
 	return &SlaveMonitoring{
 		MaxExecutingTaskPerSlave: maxTaskPerSlave,
-		LastUsingService:         0,
+		Tasks:                    tasks,
+		scheduler:                scheduler,
+		dispatchSignal:           make(chan struct{}, 1),
 	}, nil
 }
 
 /*CompareAndSave - сравнение с текущими узлами слейв и вновь полученными*/
 func (slavemonitor *SlaveMonitoring) CompareAndSave(foundedServices []*consulapi.ServiceEntry) {
+	slavemonitor.slavesMutex.Lock()
+	defer slavemonitor.slavesMutex.Unlock()
 	for _, value := range foundedServices {
 		if slavemonitor.notExistService(value) {
 			log.Println("new service not exist in this master executor")
-			slavemonitor.SlavesAvailable = append(slavemonitor.SlavesAvailable, Slave{
-				ID:                  value.Service.ID,
-				Address:             value.Node.Address,
-				Port:                value.Service.Port,
-				CurrentExecuteTasks: []int{},
+			slavemonitor.slavesAvailable = append(slavemonitor.slavesAvailable, Slave{
+				ID:      value.Service.ID,
+				Address: value.Node.Address,
+				Port:    value.Service.Port,
+				Tags:    value.Service.Tags,
 			})
 		}
 	}
@@ -70,19 +92,30 @@ func (slavemonitor *SlaveMonitoring) CompareAndSave(foundedServices []*consulapi
 
 /*ClearNotAvailableSlaves - отчистка недоступных слейвов*/
 func (slavemonitor *SlaveMonitoring) ClearNotAvailableSlaves(available []*consulapi.ServiceEntry) {
+	slavemonitor.slavesMutex.Lock()
+	defer slavemonitor.slavesMutex.Unlock()
 	result := []Slave{}
-	for _, monitoredServices := range slavemonitor.SlavesAvailable {
+	for _, monitoredServices := range slavemonitor.slavesAvailable {
 		for _, availableService := range available {
 			if monitoredServices.ID == availableService.Service.ID {
 				result = append(result, monitoredServices)
 			}
 		}
 	}
-	slavemonitor.SlavesAvailable = result
+	slavemonitor.slavesAvailable = result
+}
+
+/*GetSlaves - копия списка доступных слейвов*/
+func (slavemonitor *SlaveMonitoring) GetSlaves() []Slave {
+	slavemonitor.slavesMutex.RLock()
+	defer slavemonitor.slavesMutex.RUnlock()
+	result := make([]Slave, len(slavemonitor.slavesAvailable))
+	copy(result, slavemonitor.slavesAvailable)
+	return result
 }
 
 func (slavemonitor *SlaveMonitoring) notExistService(service *consulapi.ServiceEntry) bool {
-	for _, slave := range slavemonitor.SlavesAvailable {
+	for _, slave := range slavemonitor.slavesAvailable {
 		if slave.ID == service.Service.ID {
 			log.Debug("service already exist by slave id: ", slave.ID)
 			return false
@@ -92,261 +125,241 @@ func (slavemonitor *SlaveMonitoring) notExistService(service *consulapi.ServiceE
 	return true
 }
 
-// SendSlaveTask - проксирование запроса от клиента на один из слейв сервисов
-func (slavemonitor *SlaveMonitoring) SendSlaveTask(request *http.Request, writer http.ResponseWriter, newTask *models.TaskConfig) error {
-	if newTask.TaskID == "" {
-		return errors.New("value of taskID can not be null or empty")
-	}
-	log.Debug("start chosing slave executor")
-	// need refactoring
-	slaveID, err := slavemonitor.chooseHaveSpaceForWorkSlave()
-	if err != nil {
-		return err
-	}
-	body, err := newTask.ToByteArray()
-	if err != nil {
-		return err
-	}
-	rbody := bytes.NewReader(body)
-	log.Debug("choosed slave: ", slaveID)
-	slavemonitor.addNewTask(newTask.TaskID, slaveID)
-	addressSlave := "http://" + slavemonitor.SlavesAvailable[slaveID].Address + ":" + strconv.Itoa(slavemonitor.SlavesAvailable[slaveID].Port)
-	log.Debug("starting redirect to : ", addressSlave)
-	_, err = http.Post(addressSlave+"/task", "application/json", rbody)
-	if err != nil {
-		return err
+func (slavemonitor *SlaveMonitoring) getSlave(slaveID string) (Slave, bool) {
+	slavemonitor.slavesMutex.RLock()
+	defer slavemonitor.slavesMutex.RUnlock()
+	for _, slave := range slavemonitor.slavesAvailable {
+		if slave.ID == slaveID {
+			return slave, true
+		}
 	}
-
-	return nil
+	return Slave{}, false
 }
 
-// func (slavemonitor *SlaveMonitoring) garbageTaskCollector() {
-// 	for _, task := range slavemonitor.CurrentTasks {
-// 		if  task.TimeCreated
-// 	}
-// }
-
-// func (slavemonitor *SlaveMonitoring) checkTaskComplete(taskID int) {
-// 	time.Sleep(time.Minute * 1)
-// 	statusIndex := slavemonitor.CurrentTasks[taskID].Status.StatusIndex
-// 	if slavemonitor.CurrentTasks[taskID].Status.StatusIndex != models.SUCCESS ||
-// }
-
 // TaskResultFromSlave - обновление текущего статуса задачи со слейв модуля
 func (slavemonitor *SlaveMonitoring) TaskResultFromSlave(payload payloads.ChangeStatusTask) error {
-	for _, taskID := range slavemonitor.CurrentExecutingTask {
-		if slavemonitor.AllTask[taskID].ID == payload.TaskID {
-			return slavemonitor.updateTaskStatus(payload.TaskID, models.TaskStatusIndx(payload.NewStatus), payload.CurrentStage)
-		}
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	task, err := slavemonitor.getExecutingTaskFromSlave(payload.TaskID, payload.SlaveID)
+	if err != nil {
+		return err
 	}
-
-	return errors.New("can not find task by taskID: " + payload.TaskID)
+	if payload.NewStatus == models.TIMEOUT {
+		finishJobs(task, models.TIMEOUT)
+	}
+	if payload.Commit != "" {
+		task.Commit = payload.Commit
+	}
+	if errUpdate := slavemonitor.updateTaskStatus(task, models.TaskStatusIndx(payload.NewStatus), payload.CurrentStage); errUpdate != nil {
+		return errUpdate
+	}
+	if task.StatusTask.IsFinal() {
+		// слейв освободил место под следующую задачу из очереди
+		slavemonitor.NotifyDispatcher()
+	}
+	return nil
 }
 
 // JobResultFromSlave - обновление текущего статуса job со слейв модуля
 func (slavemonitor *SlaveMonitoring) JobResultFromSlave(payload *payloads.ChangeStatusJob) error {
 	log.Info("started update job status")
-	for _, taskID := range slavemonitor.CurrentExecutingTask {
-		if slavemonitor.AllTask[taskID].ID == payload.TaskID {
-			log.Info("start updated job status")
-			return slavemonitor.updateJobStatus(payload.TaskID, models.JobStatus{
-				Job:         payload.Job,
-				StatusIndex: models.TaskStatusIndx(payload.NewStatus),
-			})
-		}
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	task, err := slavemonitor.getExecutingTaskFromSlave(payload.TaskID, payload.SlaveID)
+	if err != nil {
+		return err
 	}
-	return errors.New("can not find task by taskID: " + payload.TaskID)
-}
-
-func (slavemonitor *SlaveMonitoring) chooseHaveSpaceForWorkSlave() (int, error) {
-	if len(slavemonitor.SlavesAvailable) == 0 {
-		return -1, errors.New("can not execute this task, because not have any available slave executors")
-	}
-	currentUseSlaveIndex := 0
-	lastUsedIndex := slavemonitor.LastUsingService
-	if lastUsedIndex == len(slavemonitor.SlavesAvailable)-1 {
-		slavemonitor.changeLastIndex(currentUseSlaveIndex)
-		return currentUseSlaveIndex, nil
-	}
-	slavemonitor.changeLastIndex(lastUsedIndex + 1)
-	return lastUsedIndex + 1, nil
+	log.Info("start updated job status")
+	return slavemonitor.updateJobStatus(task, models.JobStatus{
+		Job:         payload.Job,
+		StatusIndex: models.TaskStatusIndx(payload.NewStatus),
+	})
 }
 
-func (slavemonitor *SlaveMonitoring) changeLastIndex(newIndex int) {
-	slavemonitor.LastUsingService = newIndex
+/*chooseHaveSpaceForWorkSlave - выбор слейва для задачи стратегией scheduler среди слейвов, у которых выполняется меньше MaxExecutingTaskPerSlave задач*/
+func (slavemonitor *SlaveMonitoring) chooseHaveSpaceForWorkSlave(task models.Task, loadPerSlave map[string]int) (Slave, error) {
+	slaves := slavemonitor.GetSlaves()
+	if len(slaves) == 0 {
+		return Slave{}, errors.New("can not execute this task, because not have any available slave executors")
+	}
+	candidates := make([]SlaveCandidate, 0, len(slaves))
+	for _, slave := range slaves {
+		candidates = append(candidates, SlaveCandidate{
+			Slave:    slave,
+			Load:     loadPerSlave[slave.ID],
+			Capacity: slavemonitor.MaxExecutingTaskPerSlave,
+		})
+	}
+	return slavemonitor.scheduler.ChooseSlave(task, candidates)
 }
 
-func (slavemonitor *SlaveMonitoring) updateOneOfSlave(index int, currentExecutingTask int) {
-	slave := slavemonitor.SlavesAvailable[index]
-	slavemonitor.SlavesAvailable[index] = Slave{
-		ID:                  slave.ID,
-		Address:             slave.Address,
-		CurrentExecuteTasks: append(slave.CurrentExecuteTasks, currentExecutingTask),
-		Port:                slave.Port,
+/*HasLabels - у слейва есть все перечисленные метки*/
+func (slave Slave) HasLabels(labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, tag := range slave.Tags {
+			if tag == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
 	}
+	return true
 }
 
-func (slavemonitor *SlaveMonitoring) addNewTask(taskID string, slaveID int) {
-	slavemonitor.AllTask = append(slavemonitor.AllTask, models.Task{
-		ID:          taskID,
-		TimeCreated: time.Now().Unix(),
-		StatusJobs:  []models.JobStatus{},
-		StatusTask:  models.QUEUED,
-		SlaveIndex:  slaveID,
-	})
-	slavemonitor.CurrentExecutingTask = append(slavemonitor.CurrentExecutingTask, len(slavemonitor.AllTask)-1)
-	slavemonitor.updateInfoInSlave(slaveID, len(slavemonitor.AllTask)-1)
+/*getExecutingTask - получение задачи, которая ещё выполняется (статусы завершённых задач не обновляются). Вызывается под tasksMutex*/
+func (slavemonitor *SlaveMonitoring) getExecutingTask(taskID string) (*models.Task, error) {
+	task, err := slavemonitor.Tasks.Get(taskID)
+	if err != nil || task.StatusTask.IsFinal() {
+		return nil, errors.New("can not find task by taskID: " + taskID)
+	}
+	return task, nil
 }
 
-func (slavemonitor *SlaveMonitoring) updateInfoInSlave(slaveID int, taskID int) {
-	slave := slavemonitor.SlavesAvailable[slaveID]
-	slave.CurrentExecuteTasks = append(slave.CurrentExecuteTasks, taskID)
-	slavemonitor.SlavesAvailable[slaveID] = slave
+/*getExecutingTaskFromSlave - получение задачи для обновления статуса со слейва. Обновления от слейва, с которого задача была перезапущена, отклоняются. Вызывается под tasksMutex*/
+func (slavemonitor *SlaveMonitoring) getExecutingTaskFromSlave(taskID, slaveID string) (*models.Task, error) {
+	task, err := slavemonitor.getExecutingTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if slaveID != "" && task.SlaveID != slaveID {
+		return nil, errors.New("task " + taskID + " is not executing on slave executor: " + slaveID)
+	}
+	return task, nil
 }
 
-func (slavemonitor *SlaveMonitoring) updateTaskStatus(taskID string, newStatus models.TaskStatusIndx, stage string) error {
-	for index, taskIndex := range slavemonitor.CurrentExecutingTask {
-		timeFinish := time.Now().Unix()
-		if newStatus != models.FAILED && newStatus != models.SUCCESS && newStatus != models.CANCELED {
-			timeFinish = -1
-		}
-		if result := slavemonitor.updateTasks(slavemonitor.AllTask[taskIndex].ID, taskID, index, newStatus, timeFinish, stage); result {
-			if newStatus != models.RUNNING && newStatus != models.QUEUED {
-				log.Debug("starting update current task to history")
-				slavemonitor.updateHistory(taskID)
-				slavemonitor.updateExecutedTaskPerSlaves(index)
-			}
-			return nil
-		}
+func (slavemonitor *SlaveMonitoring) updateTaskStatus(task *models.Task, newStatus models.TaskStatusIndx, stage string) error {
+	timeFinish := time.Now().Unix()
+	if !newStatus.IsFinal() {
+		timeFinish = -1
+	}
+	task.StatusTask = newStatus
+	task.Stage = stage
+	task.TimeFinishing = timeFinish
+	if newStatus.IsFinal() {
+		task.FinishAttempt(newStatus.GetString(), timeFinish)
+		log.Debug("task moved to history: ", task.ID)
 	}
-	return errors.New("can not update task status by undefined task")
+	if err := slavemonitor.Tasks.Save(*task); err != nil {
+		return err
+	}
+	slavemonitor.notifyTaskChanged(task, nil)
+	return nil
 }
 
-/*CheckTaskIDExist - проверка, что задача с таким идентификатором существует уже*/
-func (slavemonitor *SlaveMonitoring) CheckTaskIDExist(taskID string) bool {
-	for _, task := range slavemonitor.AllTask {
-		if task.ID == taskID {
-			return true
+/*CancelTask - отмена выполняющейся задачи на слейве, который её выполняет, и пометка задачи и её незавершённых job как CANCELED. dispatchMutex не даёт отправить задачу из очереди на слейв между пометкой и выбором слейва для отмены*/
+func (slavemonitor *SlaveMonitoring) CancelTask(taskID string) error {
+	slavemonitor.dispatchMutex.Lock()
+	task, err := slavemonitor.markTaskCanceled(taskID)
+	slavemonitor.dispatchMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	// запрос к слейву выполняется без блокировки, чтобы не задерживать обновления статусов
+	if task.SlaveID != "" {
+		if errSend := slavemonitor.sendCancelToSlave(task); errSend != nil {
+			log.Warn("can not cancel task on slave executor: ", errSend)
 		}
 	}
-	return false
+	return nil
 }
 
-func (slavemonitor *SlaveMonitoring) updateJobStatus(taskID string, newStatus models.JobStatus) error {
-	for index, taskIndex := range slavemonitor.CurrentExecutingTask {
-		timeFinish := time.Now().Unix()
-		log.Debug("Time Finish job with status: ", newStatus.StatusIndex.GetString(), " time: ", timeFinish)
-		if newStatus.StatusIndex != models.FAILED && newStatus.StatusIndex != models.SUCCESS && newStatus.StatusIndex != models.CANCELED {
-			log.Debug("change time to -1")
-			timeFinish = -1
-		}
-		if result := slavemonitor.updateJob(slavemonitor.AllTask[taskIndex].ID, taskID, index, newStatus, timeFinish); result {
-			return nil
-		}
+/*markTaskCanceled - пометка задачи как CANCELED. Возвращает задачу со слейвом, который её выполнял в момент отмены*/
+func (slavemonitor *SlaveMonitoring) markTaskCanceled(taskID string) (*models.Task, error) {
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	task, err := slavemonitor.getExecutingTask(taskID)
+	if err != nil {
+		return nil, errors.New("can not cancel task which is not executing: " + taskID)
 	}
-	return errors.New("can not update job status by undefined task")
+	finishJobs(task, models.CANCELED)
+	if errUpdate := slavemonitor.updateTaskStatus(task, models.CANCELED, task.Stage); errUpdate != nil {
+		return nil, errUpdate
+	}
+	return task, nil
 }
 
-func (slavemonitor *SlaveMonitoring) updateJob(taskID string, taskIDUpdatable string, currentTaskIDX int, jobStatus models.JobStatus, timeFinished int64) bool {
-	if taskID == taskIDUpdatable {
-		log.Info("updated job. Time: ", timeFinished)
-		currentTask := slavemonitor.AllTask[currentTaskIDX]
-		log.Debug("task: ", currentTask)
-		statusPerJobs := currentTask.StatusJobs
-		log.Debug("jobs per task: ", statusPerJobs)
-		updated := false
-		for indexJob, job := range statusPerJobs {
-			if job.Job == jobStatus.Job {
-				statusPerJobs[indexJob] = models.JobStatus{
-					Job:           job.Job,
-					StatusIndex:   jobStatus.StatusIndex,
-					TimeFinishing: timeFinished,
-				}
-				updated = true
-			}
-		}
-		if !updated {
-			jobStatus.TimeFinishing = timeFinished
-			log.Info("append job to result ")
-			statusPerJobs = append(statusPerJobs, jobStatus)
+/*finishJobs - пометка незавершённых job задачи конечным статусом*/
+func finishJobs(task *models.Task, status models.TaskStatusIndx) {
+	timeFinish := time.Now().Unix()
+	for index, job := range task.StatusJobs {
+		if !job.StatusIndex.IsFinal() {
+			task.StatusJobs[index].StatusIndex = status
+			task.StatusJobs[index].TimeFinishing = timeFinish
 		}
-		log.Info("jobs status: ", statusPerJobs)
-		slavemonitor.AllTask[currentTaskIDX] = models.Task{
-			ID:            taskID,
-			TimeCreated:   currentTask.TimeCreated,
-			TimeFinishing: currentTask.TimeFinishing,
-			SlaveIndex:    currentTask.SlaveIndex,
-			StatusTask:    currentTask.StatusTask,
-			StatusJobs:    statusPerJobs,
-		}
-		return true
 	}
-	return false
 }
 
-// return true if task was updated, else return false
-func (slavemonitor *SlaveMonitoring) updateTasks(taskIDCycle string, taskIDUpdatable string, currentTaskIDx int, status models.TaskStatusIndx, timeFinished int64, stage string) bool {
-	if taskIDCycle == taskIDUpdatable {
-		currentTask := slavemonitor.AllTask[currentTaskIDx]
-		slavemonitor.AllTask[currentTaskIDx] = models.Task{
-			ID:            taskIDCycle,
-			TimeCreated:   currentTask.TimeCreated,
-			TimeFinishing: timeFinished,
-			SlaveIndex:    currentTask.SlaveIndex,
-			Stage:         stage,
-			StatusTask:    status,
-			StatusJobs:    currentTask.StatusJobs,
-		}
-
-		return true
+func (slavemonitor *SlaveMonitoring) sendCancelToSlave(task *models.Task) error {
+	slave, ok := slavemonitor.getSlave(task.SlaveID)
+	if !ok {
+		return errors.New("can not find slave executor for task: " + task.ID)
+	}
+	addressSlave := "http://" + slave.Address + ":" + strconv.Itoa(slave.Port)
+	log.Debug("send cancel task to slave: ", addressSlave)
+	request, err := http.NewRequest(http.MethodDelete, addressSlave+"/task/"+task.ID, nil)
+	if err != nil {
+		return err
 	}
-	return false
+	slavemonitor.Auth.Sign(request, nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.New("slave executor answered with status: " + response.Status)
+	}
+	return nil
 }
 
-func (slavemonitor *SlaveMonitoring) updateHistory(taskID string) {
-	result := []int{}
-	for _, taskIndex := range slavemonitor.CurrentExecutingTask {
-		if slavemonitor.AllTask[taskIndex].ID == taskID {
-			slavemonitor.History = append(slavemonitor.History, taskIndex)
-		} else {
-			result = append(result, taskIndex)
-		}
-	}
-	log.Debug("GLOBAL TASKS: ", result)
-	log.Debug("GLOBAL HISTORY: ", slavemonitor.History)
-	slavemonitor.CurrentExecutingTask = result
+/*CheckTaskIDExist - проверка, что задача с таким идентификатором существует уже*/
+func (slavemonitor *SlaveMonitoring) CheckTaskIDExist(taskID string) bool {
+	return slavemonitor.Tasks.Exist(taskID)
 }
 
-func (slavemonitor *SlaveMonitoring) updateExecutedTaskPerSlaves(indexTask int) {
-	for slaveID, slave := range slavemonitor.SlavesAvailable {
-		currentTasks := []int{}
-		currentHistroy := []int{}
-		for _, slaveTask := range slave.CurrentExecuteTasks {
-			if indexTask != slaveTask {
-				currentTasks = append(currentTasks, slaveTask)
-			} else {
-				currentHistroy = append(currentHistroy, slaveTask)
-			}
+func (slavemonitor *SlaveMonitoring) updateJobStatus(task *models.Task, jobStatus models.JobStatus) error {
+	timeFinish := time.Now().Unix()
+	log.Debug("Time Finish job with status: ", jobStatus.StatusIndex.GetString(), " time: ", timeFinish)
+	if !jobStatus.StatusIndex.IsFinal() {
+		log.Debug("change time to -1")
+		timeFinish = -1
+	}
+	log.Info("updated job. Time: ", timeFinish)
+	jobStatus.TimeFinishing = timeFinish
+	updated := false
+	for indexJob, job := range task.StatusJobs {
+		if job.Job == jobStatus.Job {
+			task.StatusJobs[indexJob] = jobStatus
+			updated = true
 		}
-		currentHistroy = append(currentHistroy, slavemonitor.SlavesAvailable[slaveID].HistoryTasks...)
-		log.Debug("change histry and current tasks: History: ", currentHistroy, " Tasks:", currentTasks)
-		slavemonitor.SlavesAvailable[slaveID].CurrentExecuteTasks = currentTasks
-		slavemonitor.SlavesAvailable[slaveID].HistoryTasks = currentHistroy
-		log.Debug("Updated task in slave: ", slavemonitor.SlavesAvailable[slaveID].HistoryTasks)
 	}
+	if !updated {
+		log.Info("append job to result ")
+		task.StatusJobs = append(task.StatusJobs, jobStatus)
+	}
+	log.Info("jobs status: ", task.StatusJobs)
+	if err := slavemonitor.Tasks.Save(*task); err != nil {
+		return err
+	}
+	slavemonitor.notifyTaskChanged(task, &jobStatus)
+	return nil
 }
 
 /*GetTaskStatus - получить текущий статус задачи по её идентификатору*/
 func (slavemonitor *SlaveMonitoring) GetTaskStatus(taskID string) (*models.Task, error) {
-	for _, taskIndex := range slavemonitor.CurrentExecutingTask {
-		if slavemonitor.AllTask[taskIndex].ID == taskID {
-			return &slavemonitor.AllTask[taskIndex], nil
-		}
-	}
-	for _, taskIndex := range slavemonitor.History {
-		if slavemonitor.AllTask[taskIndex].ID == taskID {
-			return &slavemonitor.AllTask[taskIndex], nil
-		}
-	}
-	return nil, errors.New("can not get task status by undefined task")
+	return slavemonitor.Tasks.Get(taskID)
+}
+
+/*GetAllTasks - получить все задачи (выполняющиеся и завершённые)*/
+func (slavemonitor *SlaveMonitoring) GetAllTasks() []models.Task {
+	return slavemonitor.Tasks.GetAll()
+}
+
+/*GetTasksHistory - получить завершённые задачи за промежуток времени (unix, timeTo <= 0 - без верхней границы)*/
+func (slavemonitor *SlaveMonitoring) GetTasksHistory(timeFrom, timeTo int64) []models.Task {
+	return slavemonitor.Tasks.GetHistory(timeFrom, timeTo)
 }
@@ -0,0 +1,207 @@
+package monitor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/payloads"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeSlave() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+}
+
+func serviceEntry(t *testing.T, id string, server *httptest.Server) *consulapi.ServiceEntry {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return &consulapi.ServiceEntry{
+		Node:    &consulapi.Node{Address: host},
+		Service: &consulapi.AgentService{ID: id, Port: portNumber},
+	}
+}
+
+func newTestMonitoring(t *testing.T, maxTaskPerSlave int) *SlaveMonitoring {
+	monitoring, err := InitializeNewSlaveMonitoring(maxTaskPerSlave, NewMemoryTaskRepository(), NewRoundRobinScheduler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return monitoring
+}
+
+func Test_ChooseSlaveAfterRemovingLastUsed(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	first, second, third := serviceEntry(t, "first", server), serviceEntry(t, "second", server), serviceEntry(t, "third", server)
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{first, second, third})
+
+	slave, _ := monitoring.chooseHaveSpaceForWorkSlave(models.Task{}, map[string]int{})
+	assert.Equal(t, "first", slave.ID)
+	slave, _ = monitoring.chooseHaveSpaceForWorkSlave(models.Task{}, map[string]int{})
+	assert.Equal(t, "second", slave.ID)
+
+	monitoring.ClearNotAvailableSlaves([]*consulapi.ServiceEntry{first, third})
+	slave, err := monitoring.chooseHaveSpaceForWorkSlave(models.Task{}, map[string]int{})
+	assert.Nil(t, err)
+	assert.Equal(t, "first", slave.ID)
+	slave, _ = monitoring.chooseHaveSpaceForWorkSlave(models.Task{}, map[string]int{})
+	assert.Equal(t, "third", slave.ID)
+
+	_, err = monitoring.chooseHaveSpaceForWorkSlave(models.Task{}, map[string]int{"first": 10, "third": 10})
+	assert.NotNil(t, err)
+
+	monitoring.ClearNotAvailableSlaves([]*consulapi.ServiceEntry{})
+	_, err = monitoring.chooseHaveSpaceForWorkSlave(models.Task{}, map[string]int{})
+	assert.NotNil(t, err)
+}
+
+func Test_ConcurrentStatusUpdatesWhileSlavesChange(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 100)
+	slaves := []*consulapi.ServiceEntry{
+		serviceEntry(t, "slave1", server),
+		serviceEntry(t, "slave2", server),
+	}
+	monitoring.CompareAndSave(slaves)
+
+	const amountTasks = 20
+	const amountJobs = 10
+	jobs := map[string]models.Job{}
+	for job := 0; job < amountJobs; job++ {
+		jobs["job"+strconv.Itoa(job)] = models.Job{}
+	}
+
+	wait := sync.WaitGroup{}
+	for task := 0; task < amountTasks; task++ {
+		wait.Add(1)
+		go func(taskID string) {
+			defer wait.Done()
+			assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: taskID, Jobs: jobs}))
+			monitoring.DispatchQueuedTasks()
+			jobsWait := sync.WaitGroup{}
+			for job := range jobs {
+				jobsWait.Add(1)
+				go func(job string) {
+					defer jobsWait.Done()
+					monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: taskID, NewStatus: models.RUNNING, CurrentStage: job})
+					assert.Nil(t, monitoring.JobResultFromSlave(&payloads.ChangeStatusJob{TaskID: taskID, Job: job, NewStatus: models.SUCCESS}))
+				}(job)
+			}
+			jobsWait.Wait()
+			assert.Nil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: taskID, NewStatus: models.SUCCESS}))
+		}("task" + strconv.Itoa(task))
+	}
+
+	stop := make(chan struct{})
+	background := sync.WaitGroup{}
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				monitoring.ClearNotAvailableSlaves(slaves[:1])
+				monitoring.CompareAndSave(slaves)
+			}
+		}
+	}()
+	go func() {
+		defer background.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				monitoring.GetSlaves()
+				monitoring.GetAllTasks()
+				monitoring.GetTasksHistory(0, 0)
+			}
+		}
+	}()
+	wait.Wait()
+	close(stop)
+	background.Wait()
+
+	history := monitoring.GetTasksHistory(0, 0)
+	assert.Equal(t, amountTasks, len(history))
+	for _, task := range history {
+		assert.Equal(t, models.TaskStatusIndx(models.SUCCESS), task.StatusTask)
+		assert.Equal(t, amountJobs, len(task.StatusJobs))
+		for _, job := range task.StatusJobs {
+			assert.Equal(t, models.TaskStatusIndx(models.SUCCESS), job.StatusIndex)
+		}
+	}
+}
+
+func Test_CancelTaskRejectsFurtherUpdates(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{
+		TaskID: "task",
+		Jobs:   map[string]models.Job{"build": {}, "test": {}},
+	}))
+	monitoring.DispatchQueuedTasks()
+	assert.Nil(t, monitoring.JobResultFromSlave(&payloads.ChangeStatusJob{TaskID: "task", Job: "build", NewStatus: models.SUCCESS}))
+
+	assert.Nil(t, monitoring.CancelTask("task"))
+	task, _ := monitoring.GetTaskStatus("task")
+	assert.Equal(t, models.TaskStatusIndx(models.CANCELED), task.StatusTask)
+	for _, job := range task.StatusJobs {
+		if job.Job == "build" {
+			assert.Equal(t, models.TaskStatusIndx(models.SUCCESS), job.StatusIndex)
+		} else {
+			assert.Equal(t, models.TaskStatusIndx(models.CANCELED), job.StatusIndex)
+		}
+	}
+	assert.NotNil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "task", NewStatus: models.FAILED}))
+	assert.NotNil(t, monitoring.CancelTask("task"))
+}
+
+func Test_CancelTaskDuringDispatch(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+	deleted := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodPost:
+			close(received)
+			<-release
+		case http.MethodDelete:
+			deleted <- request.URL.Path
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "task"}))
+
+	go monitoring.DispatchQueuedTasks()
+	<-received
+	canceled := make(chan error)
+	go func() {
+		canceled <- monitoring.CancelTask("task")
+	}()
+	// отмена ждёт, пока задача будет принята слейвом, и отправляет отмену на этот слейв
+	close(release)
+	assert.Nil(t, <-canceled)
+	assert.Equal(t, "/task/task", <-deleted)
+	task, _ := monitoring.GetTaskStatus("task")
+	assert.Equal(t, models.TaskStatusIndx(models.CANCELED), task.StatusTask)
+}
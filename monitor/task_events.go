@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubitre/diplom/models"
+)
+
+/*TaskEventListener - получатель изменений статусов задач и job. Вызывается под tasksMutex, поэтому не должен блокироваться*/
+type TaskEventListener interface {
+	TaskChanged(event models.TaskEvent, task models.Task)
+}
+
+var taskEventSequence uint64
+
+/*notifyTaskChanged - событие об изменении статуса задачи (или её job, если jobStatus не nil) после его сохранения*/
+func (slavemonitor *SlaveMonitoring) notifyTaskChanged(task *models.Task, jobStatus *models.JobStatus) {
+	if slavemonitor.Listener == nil {
+		return
+	}
+	now := time.Now()
+	event := models.TaskEvent{
+		ID:     task.ID + "-" + strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(atomic.AddUint64(&taskEventSequence, 1), 10),
+		Type:   models.TaskEventTask,
+		TaskID: task.ID,
+		Stage:  task.Stage,
+		Status: task.StatusTask.GetString(),
+		Time:   now.Unix(),
+	}
+	if jobStatus != nil {
+		event.Type, event.Job, event.Status = models.TaskEventJob, jobStatus.Job, jobStatus.StatusIndex.GetString()
+	}
+	slavemonitor.Listener.TaskChanged(event, *task)
+}
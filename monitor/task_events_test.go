@@ -0,0 +1,38 @@
+package monitor
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/payloads"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingListener struct {
+	events []models.TaskEvent
+}
+
+func (listener *recordingListener) TaskChanged(event models.TaskEvent, task models.Task) {
+	listener.events = append(listener.events, event)
+}
+
+func Test_StatusChangesNotifyListener(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	listener := &recordingListener{}
+	monitoring.Listener = listener
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "task", Jobs: map[string]models.Job{"build": {}}}))
+	monitoring.DispatchQueuedTasks()
+
+	assert.Nil(t, monitoring.JobResultFromSlave(&payloads.ChangeStatusJob{TaskID: "task", Job: "build", NewStatus: models.SUCCESS, SlaveID: "slave"}))
+	assert.Nil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "task", NewStatus: models.SUCCESS, SlaveID: "slave"}))
+
+	assert.Equal(t, 2, len(listener.events))
+	assert.Equal(t, models.TaskEventJob, listener.events[0].Type)
+	assert.Equal(t, "build", listener.events[0].Job)
+	assert.Equal(t, models.TaskStatusIndx(models.SUCCESS).GetString(), listener.events[1].Status)
+	assert.NotEqual(t, listener.events[0].ID, listener.events[1].ID)
+}
@@ -0,0 +1,231 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+/*EnqueueTask - постановка новой задачи в очередь мастера. Задача будет отправлена на слейв, как только у одного из них освободится место*/
+func (slavemonitor *SlaveMonitoring) EnqueueTask(newTask *models.TaskConfig) error {
+	if newTask.TaskID == "" {
+		return errors.New("value of taskID can not be null or empty")
+	}
+	if newTask.Timeout <= 0 {
+		newTask.Timeout = slavemonitor.DefaultTaskTimeout
+	}
+	if err := slavemonitor.addNewTask(newTask); err != nil {
+		return err
+	}
+	log.Debug("task was queued: ", newTask.TaskID)
+	slavemonitor.NotifyDispatcher()
+	return nil
+}
+
+func (slavemonitor *SlaveMonitoring) addNewTask(newTask *models.TaskConfig) error {
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	if slavemonitor.Tasks.Exist(newTask.TaskID) {
+		return errors.New("can not create already exist task: " + newTask.TaskID)
+	}
+	statusJobs := []models.JobStatus{}
+	for jobName := range newTask.Jobs {
+		statusJobs = append(statusJobs, models.JobStatus{
+			Job:           jobName,
+			StatusIndex:   models.QUEUED,
+			TimeFinishing: -1,
+		})
+	}
+	now := time.Now()
+	task := models.Task{
+		ID:            newTask.TaskID,
+		TimeCreated:   now.Unix(),
+		TimeFinishing: -1,
+		StatusJobs:    statusJobs,
+		StatusTask:    models.QUEUED,
+		Priority:      newTask.Priority,
+		QueuedAt:      now.UnixNano(),
+		Config:        newTask,
+	}
+	return slavemonitor.Tasks.Save(task)
+}
+
+/*GetQueue - задачи, ожидающие отправки на слейв, в порядке отправки (приоритет, затем время постановки в очередь)*/
+func (slavemonitor *SlaveMonitoring) GetQueue() []models.Task {
+	queue := []models.Task{}
+	for _, task := range slavemonitor.Tasks.GetExecuting() {
+		if task.SlaveID == "" {
+			queue = append(queue, task)
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].Priority != queue[j].Priority {
+			return queue[i].Priority > queue[j].Priority
+		}
+		return queue[i].QueuedAt < queue[j].QueuedAt
+	})
+	return queue
+}
+
+/*GetQueuePosition - позиция задачи в очереди (начиная с 1) и глубина очереди. Позиция 0 - задача не в очереди*/
+func (slavemonitor *SlaveMonitoring) GetQueuePosition(taskID string) (int, int) {
+	queue := slavemonitor.GetQueue()
+	for index, task := range queue {
+		if task.ID == taskID {
+			return index + 1, len(queue)
+		}
+	}
+	return 0, len(queue)
+}
+
+/*RunDispatcher - отправка задач из очереди на слейвы при каждом сигнале (новая задача, освободившийся или новый слейв)*/
+func (slavemonitor *SlaveMonitoring) RunDispatcher() {
+	for range slavemonitor.dispatchSignal {
+		slavemonitor.DispatchQueuedTasks()
+	}
+}
+
+/*NotifyDispatcher - сигнал о том, что задачи из очереди могут быть отправлены на слейвы*/
+func (slavemonitor *SlaveMonitoring) NotifyDispatcher() {
+	select {
+	case slavemonitor.dispatchSignal <- struct{}{}:
+	default:
+		// сигнал уже ожидает обработки
+	}
+}
+
+/*DispatchQueuedTasks - отправка задач из очереди на слейвы, пока у слейвов есть место*/
+func (slavemonitor *SlaveMonitoring) DispatchQueuedTasks() {
+	slavemonitor.dispatchMutex.Lock()
+	defer slavemonitor.dispatchMutex.Unlock()
+	for _, task := range slavemonitor.GetQueue() {
+		slave, err := slavemonitor.chooseHaveSpaceForWorkSlave(task, slavemonitor.loadPerSlave())
+		if err != nil {
+			// задача может ждать слейв с нужными метками, следующие задачи из очереди могут быть отправлены
+			log.Debug("queued task is waiting for slave executor: ", task.ID, " reason: ", err)
+			continue
+		}
+		if errSend := slavemonitor.sendTaskToSlave(task.ID, slave); errSend != nil {
+			log.Warn("can not send queued task to slave executor: ", errSend)
+			return
+		}
+	}
+}
+
+/*slaveTaskBody - конфигурация задачи для слейва со значениями её секретов. Значения добавляются в копию конфигурации и не сохраняются в хранилище задач*/
+func (slavemonitor *SlaveMonitoring) slaveTaskBody(task *models.Task) ([]byte, error) {
+	config := *task.Config
+	if names := config.SecretNames(); len(names) > 0 {
+		if slavemonitor.Secrets == nil {
+			return nil, errors.New("task uses secrets, but secret store is not configured")
+		}
+		values, err := slavemonitor.Secrets.Resolve(names)
+		if err != nil {
+			return nil, err
+		}
+		config.SecretValues = values
+	}
+	return config.ToByteArray()
+}
+
+/*loadPerSlave - количество выполняющихся задач на каждом слейве*/
+func (slavemonitor *SlaveMonitoring) loadPerSlave() map[string]int {
+	result := map[string]int{}
+	for _, task := range slavemonitor.Tasks.GetExecuting() {
+		if task.SlaveID != "" {
+			result[task.SlaveID]++
+		}
+	}
+	return result
+}
+
+func (slavemonitor *SlaveMonitoring) sendTaskToSlave(taskID string, slave Slave) error {
+	task, err := slavemonitor.assignTask(taskID, slave.ID)
+	if err != nil {
+		// задача могла быть отменена, пока ожидала в очереди
+		log.Debug("skip queued task: ", err)
+		return nil
+	}
+	body, err := slavemonitor.slaveTaskBody(task)
+	if err != nil {
+		slavemonitor.failTask(taskID, err)
+		return nil
+	}
+	addressSlave := "http://" + slave.Address + ":" + strconv.Itoa(slave.Port)
+	log.Debug("starting redirect to : ", addressSlave)
+	request, err := http.NewRequest(http.MethodPost, addressSlave+"/task", bytes.NewReader(body))
+	if err != nil {
+		slavemonitor.failTask(taskID, err)
+		return nil
+	}
+	request.Header.Set("Content-Type", "application/json")
+	slavemonitor.Auth.Sign(request, body)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		slavemonitor.returnTaskToQueue(taskID, slave.ID)
+		return err
+	}
+	defer response.Body.Close()
+	switch {
+	case response.StatusCode == http.StatusUnauthorized:
+		slavemonitor.returnTaskToQueue(taskID, slave.ID)
+		return errors.New("slave executor rejected request signature, check RUNNER_SECRET")
+	case response.StatusCode == http.StatusBadRequest:
+		slavemonitor.failTask(taskID, errors.New("slave executor can not accept task"))
+	case response.StatusCode != http.StatusOK:
+		slavemonitor.returnTaskToQueue(taskID, slave.ID)
+		return errors.New("slave executor answered with status: " + response.Status)
+	}
+	return nil
+}
+
+func (slavemonitor *SlaveMonitoring) assignTask(taskID, slaveID string) (*models.Task, error) {
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	task, err := slavemonitor.getExecutingTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.SlaveID != "" {
+		return nil, errors.New("task already sent to slave executor: " + taskID)
+	}
+	if task.Config == nil {
+		return nil, errors.New("task has no configuration: " + taskID)
+	}
+	task.SlaveID = slaveID
+	task.StartAttempt(slaveID, time.Now().Unix())
+	return task, slavemonitor.Tasks.Save(*task)
+}
+
+func (slavemonitor *SlaveMonitoring) returnTaskToQueue(taskID, slaveID string) {
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	task, err := slavemonitor.getExecutingTask(taskID)
+	if err != nil || task.SlaveID != slaveID {
+		return
+	}
+	task.SlaveID = ""
+	task.FinishAttempt(models.AttemptReasonNotSent, time.Now().Unix())
+	if errSave := slavemonitor.Tasks.Save(*task); errSave != nil {
+		log.Error("can not return task to queue: ", errSave)
+	}
+}
+
+func (slavemonitor *SlaveMonitoring) failTask(taskID string, reason error) {
+	log.Error("task can not be executed: ", taskID, " reason: ", reason)
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	task, err := slavemonitor.getExecutingTask(taskID)
+	if err != nil {
+		return
+	}
+	if errUpdate := slavemonitor.updateTaskStatus(task, models.FAILED, task.Stage); errUpdate != nil {
+		log.Error("can not fail task: ", errUpdate)
+	}
+}
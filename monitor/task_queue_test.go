@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/payloads"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_QueueDispatchByPriorityWhenSlaveFree(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 1)
+
+	tasks := []models.TaskConfig{
+		{TaskID: "first"},
+		{TaskID: "second"},
+		{TaskID: "urgent", Priority: 5},
+	}
+	for index := range tasks {
+		assert.Nil(t, monitoring.EnqueueTask(&tasks[index]))
+	}
+	assert.NotNil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "first"}))
+	position, depth := monitoring.GetQueuePosition("first")
+	assert.Equal(t, 2, position)
+	assert.Equal(t, 3, depth)
+
+	// нет слейвов - задачи остаются в очереди
+	monitoring.DispatchQueuedTasks()
+	assert.Equal(t, 3, len(monitoring.GetQueue()))
+
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+	monitoring.DispatchQueuedTasks()
+	urgent, _ := monitoring.GetTaskStatus("urgent")
+	assert.Equal(t, "slave", urgent.SlaveID)
+	position, depth = monitoring.GetQueuePosition("first")
+	assert.Equal(t, 1, position)
+	assert.Equal(t, 2, depth)
+
+	assert.Nil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "urgent", NewStatus: models.SUCCESS}))
+	monitoring.DispatchQueuedTasks()
+	first, _ := monitoring.GetTaskStatus("first")
+	assert.Equal(t, "slave", first.SlaveID)
+	position, depth = monitoring.GetQueuePosition("first")
+	assert.Equal(t, 0, position)
+	assert.Equal(t, 1, depth)
+}
+
+func Test_QueueKeepTaskWhenSlaveUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 1)
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "task"}))
+	monitoring.DispatchQueuedTasks()
+	position, _ := monitoring.GetQueuePosition("task")
+	assert.Equal(t, 1, position)
+
+	assert.Nil(t, monitoring.CancelTask("task"))
+	assert.Equal(t, 0, len(monitoring.GetQueue()))
+}
+
+func Test_TaskTimeoutFromSlave(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	monitoring.DefaultTaskTimeout = 60000
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "default", Jobs: map[string]models.Job{"build": {}}}))
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "custom", Timeout: 1000}))
+	task, _ := monitoring.GetTaskStatus("default")
+	assert.Equal(t, int64(60000), task.Config.Timeout)
+	task, _ = monitoring.GetTaskStatus("custom")
+	assert.Equal(t, int64(1000), task.Config.Timeout)
+
+	monitoring.DispatchQueuedTasks()
+	assert.Nil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "default", NewStatus: models.TIMEOUT, SlaveID: "slave"}))
+	task, _ = monitoring.GetTaskStatus("default")
+	assert.Equal(t, models.TaskStatusIndx(models.TIMEOUT), task.StatusTask)
+	assert.Equal(t, models.TaskStatusIndx(models.TIMEOUT), task.StatusJobs[0].StatusIndex)
+	assert.NotEqual(t, int64(-1), task.TimeFinishing)
+}
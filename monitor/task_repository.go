@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	/*TaskRepository - хранилище задач мастер ноды*/
+	TaskRepository interface {
+		Save(task models.Task) error                     // создание или обновление задачи
+		Get(taskID string) (*models.Task, error)         // получение задачи по идентификатору
+		Exist(taskID string) bool                        // проверка существования задачи
+		GetAll() []models.Task                           // все задачи в порядке создания
+		GetExecuting() []models.Task                     // задачи, которые ещё не завершились
+		GetHistory(timeFrom, timeTo int64) []models.Task // завершённые задачи за промежуток времени (unix)
+	}
+
+	/*memoryTaskRepository - хранилище задач в памяти*/
+	memoryTaskRepository struct {
+		mutex sync.RWMutex
+		tasks map[string]models.Task
+	}
+)
+
+/*NewMemoryTaskRepository - хранилище задач в памяти. Задачи теряются при перезапуске мастера*/
+func NewMemoryTaskRepository() TaskRepository {
+	return newMemoryTaskRepository()
+}
+
+func newMemoryTaskRepository() *memoryTaskRepository {
+	return &memoryTaskRepository{
+		tasks: map[string]models.Task{},
+	}
+}
+
+func (repository *memoryTaskRepository) Save(task models.Task) error {
+	if task.ID == "" {
+		return errors.New("can not save task with empty id")
+	}
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.tasks[task.ID] = copyTask(task)
+	return nil
+}
+
+func (repository *memoryTaskRepository) Get(taskID string) (*models.Task, error) {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	task, ok := repository.tasks[taskID]
+	if !ok {
+		return nil, errors.New("can not get task status by undefined task")
+	}
+	result := copyTask(task)
+	return &result, nil
+}
+
+func (repository *memoryTaskRepository) Exist(taskID string) bool {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	_, ok := repository.tasks[taskID]
+	return ok
+}
+
+func (repository *memoryTaskRepository) GetAll() []models.Task {
+	return repository.filter(func(task models.Task) bool {
+		return true
+	})
+}
+
+func (repository *memoryTaskRepository) GetExecuting() []models.Task {
+	return repository.filter(func(task models.Task) bool {
+		return !task.StatusTask.IsFinal()
+	})
+}
+
+func (repository *memoryTaskRepository) GetHistory(timeFrom, timeTo int64) []models.Task {
+	return repository.filter(func(task models.Task) bool {
+		if !task.StatusTask.IsFinal() || task.TimeFinishing < timeFrom {
+			return false
+		}
+		return timeTo <= 0 || task.TimeFinishing <= timeTo
+	})
+}
+
+func (repository *memoryTaskRepository) filter(need func(task models.Task) bool) []models.Task {
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+	result := []models.Task{}
+	for _, task := range repository.tasks {
+		if need(task) {
+			result = append(result, copyTask(task))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].TimeCreated == result[j].TimeCreated {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].TimeCreated < result[j].TimeCreated
+	})
+	return result
+}
+
+/*copyTask - копия задачи, чтобы статусы job и попытки не менялись в хранилище в обход Save*/
+func copyTask(task models.Task) models.Task {
+	statusJobs := make([]models.JobStatus, len(task.StatusJobs))
+	copy(statusJobs, task.StatusJobs)
+	task.StatusJobs = statusJobs
+	attempts := make([]models.TaskAttempt, len(task.Attempts))
+	copy(attempts, task.Attempts)
+	task.Attempts = attempts
+	return task
+}
@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileTaskRepositorySurviveRestart(t *testing.T) {
+	path, err := ioutil.TempDir("", "tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	repository, err := NewFileTaskRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, repository.Save(models.Task{ID: "running", SlaveID: "slave1", StatusTask: models.RUNNING, TimeCreated: 1, TimeFinishing: -1}))
+	assert.Nil(t, repository.Save(models.Task{ID: "finished", SlaveID: "slave1", StatusTask: models.SUCCESS, TimeCreated: 2, TimeFinishing: 20}))
+	assert.NotNil(t, repository.Save(models.Task{ID: "../escape"}))
+
+	restarted, err := NewFileTaskRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := restarted.Get("running")
+	assert.Nil(t, err)
+	assert.Equal(t, "slave1", task.SlaveID)
+	assert.True(t, restarted.Exist("finished"))
+	assert.Equal(t, 2, len(restarted.GetAll()))
+	assert.Equal(t, "running", restarted.GetExecuting()[0].ID)
+}
+
+func Test_TaskRepositoryHistory(t *testing.T) {
+	repository := NewMemoryTaskRepository()
+	repository.Save(models.Task{ID: "first", StatusTask: models.FAILED, TimeCreated: 1, TimeFinishing: 10})
+	repository.Save(models.Task{ID: "second", StatusTask: models.SUCCESS, TimeCreated: 2, TimeFinishing: 20})
+	repository.Save(models.Task{ID: "running", StatusTask: models.RUNNING, TimeCreated: 3, TimeFinishing: -1})
+
+	assert.Equal(t, 2, len(repository.GetHistory(0, 0)))
+	history := repository.GetHistory(15, 25)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, "second", history[0].ID)
+}
+
+func Test_TaskRepositoryReturnCopy(t *testing.T) {
+	repository := NewMemoryTaskRepository()
+	repository.Save(models.Task{ID: "task", StatusJobs: []models.JobStatus{{Job: "job", StatusIndex: models.QUEUED}}})
+	task, _ := repository.Get("task")
+	task.StatusJobs[0].StatusIndex = models.FAILED
+	stored, _ := repository.Get("task")
+	assert.Equal(t, models.TaskStatusIndx(models.QUEUED), stored.StatusJobs[0].StatusIndex)
+}
@@ -0,0 +1,47 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+/*RescheduleOrphanedTasks - возврат в очередь выполняющихся задач, слейвы которых больше недоступны. После MaxReschedulePerTask перезапусков задача помечается как LOST*/
+func (slavemonitor *SlaveMonitoring) RescheduleOrphanedTasks() {
+	available := map[string]bool{}
+	for _, slave := range slavemonitor.GetSlaves() {
+		available[slave.ID] = true
+	}
+	slavemonitor.tasksMutex.Lock()
+	defer slavemonitor.tasksMutex.Unlock()
+	for _, task := range slavemonitor.Tasks.GetExecuting() {
+		if task.SlaveID == "" || available[task.SlaveID] {
+			continue
+		}
+		log.Warn("slave executor was lost with task: ", task.ID, " slave: ", task.SlaveID)
+		if err := slavemonitor.rescheduleLostTask(&task); err != nil {
+			log.Error("can not reschedule task: ", task.ID, " by error: ", err)
+		}
+	}
+	slavemonitor.NotifyDispatcher()
+}
+
+/*rescheduleLostTask - задача возвращается в очередь со сброшенными статусами job (будет выполнена заново) или помечается как LOST. Вызывается под tasksMutex*/
+func (slavemonitor *SlaveMonitoring) rescheduleLostTask(task *models.Task) error {
+	now := time.Now().Unix()
+	task.FinishAttempt(models.AttemptReasonSlaveLost, now)
+	if task.CountAttempts(models.AttemptReasonSlaveLost) > slavemonitor.MaxReschedulePerTask {
+		log.Warn("task can not be rescheduled anymore: ", task.ID)
+		finishJobs(task, models.LOST)
+		return slavemonitor.updateTaskStatus(task, models.LOST, task.Stage)
+	}
+	for index := range task.StatusJobs {
+		task.StatusJobs[index].StatusIndex = models.QUEUED
+		task.StatusJobs[index].TimeFinishing = -1
+	}
+	// QueuedAt не меняется, чтобы задача не потеряла своё место в очереди
+	task.SlaveID = ""
+	log.Info("task returned to queue: ", task.ID)
+	return slavemonitor.updateTaskStatus(task, models.QUEUED, "")
+}
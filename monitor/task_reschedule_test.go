@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/payloads"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RescheduleTaskFromLostSlave(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	monitoring.MaxReschedulePerTask = 1
+	listener := &recordingListener{}
+	monitoring.Listener = listener
+	first, second := serviceEntry(t, "first", server), serviceEntry(t, "second", server)
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{first})
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "task", Jobs: map[string]models.Job{"build": {}}}))
+	monitoring.DispatchQueuedTasks()
+	assert.Nil(t, monitoring.JobResultFromSlave(&payloads.ChangeStatusJob{TaskID: "task", Job: "build", NewStatus: models.SUCCESS, SlaveID: "first"}))
+
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{second})
+	monitoring.ClearNotAvailableSlaves([]*consulapi.ServiceEntry{second})
+	monitoring.RescheduleOrphanedTasks()
+	task, _ := monitoring.GetTaskStatus("task")
+	assert.Equal(t, models.TaskStatusIndx(models.QUEUED), task.StatusTask)
+	assert.Equal(t, models.TaskStatusIndx(models.QUEUED), task.StatusJobs[0].StatusIndex)
+	// подписчики узнают о возврате задачи в очередь
+	lastEvent := listener.events[len(listener.events)-1]
+	assert.Equal(t, models.TaskEventTask, lastEvent.Type)
+	assert.Equal(t, models.TaskStatusIndx(models.QUEUED).GetString(), lastEvent.Status)
+
+	monitoring.DispatchQueuedTasks()
+	task, _ = monitoring.GetTaskStatus("task")
+	assert.Equal(t, "second", task.SlaveID)
+	assert.Equal(t, 2, len(task.Attempts))
+	assert.Equal(t, "first", task.Attempts[0].SlaveID)
+	assert.Equal(t, models.AttemptReasonSlaveLost, task.Attempts[0].Reason)
+	assert.Equal(t, int64(-1), task.Attempts[1].TimeEnd)
+
+	// обновления от пропавшего слейва не принимаются
+	assert.NotNil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "task", NewStatus: models.SUCCESS, SlaveID: "first"}))
+
+	monitoring.ClearNotAvailableSlaves([]*consulapi.ServiceEntry{})
+	monitoring.RescheduleOrphanedTasks()
+	task, _ = monitoring.GetTaskStatus("task")
+	assert.Equal(t, models.TaskStatusIndx(models.LOST), task.StatusTask)
+	assert.Equal(t, models.TaskStatusIndx(models.LOST), task.StatusJobs[0].StatusIndex)
+	assert.Equal(t, 2, task.CountAttempts(models.AttemptReasonSlaveLost))
+}
+
+func Test_FinishedTaskClosesAttempt(t *testing.T) {
+	server := newFakeSlave()
+	defer server.Close()
+	monitoring := newTestMonitoring(t, 10)
+	monitoring.CompareAndSave([]*consulapi.ServiceEntry{serviceEntry(t, "slave", server)})
+	assert.Nil(t, monitoring.EnqueueTask(&models.TaskConfig{TaskID: "task"}))
+	monitoring.DispatchQueuedTasks()
+	assert.Nil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "task", NewStatus: models.RUNNING, SlaveID: "slave", Commit: "0123abcd"}))
+	// коммит репозитория сохраняется, даже если следующие статусы его не содержат
+	assert.Nil(t, monitoring.TaskResultFromSlave(payloads.ChangeStatusTask{TaskID: "task", NewStatus: models.SUCCESS, SlaveID: "slave"}))
+
+	monitoring.ClearNotAvailableSlaves([]*consulapi.ServiceEntry{})
+	monitoring.RescheduleOrphanedTasks()
+	task, _ := monitoring.GetTaskStatus("task")
+	assert.Equal(t, models.TaskStatusIndx(models.SUCCESS), task.StatusTask)
+	assert.Equal(t, "0123abcd", task.Commit)
+	assert.Equal(t, 1, len(task.Attempts))
+	assert.Equal(t, models.TaskStatusIndx(models.SUCCESS).GetString(), task.Attempts[0].Reason)
+}
@@ -0,0 +1,321 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kubitre/diplom/config"
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SignatureHeader - HMAC-SHA256 тела запроса в формате sha256={hex}
+	SignatureHeader = "X-Runner-Signature"
+	// EventHeader - тип события (models.TaskEvent...)
+	EventHeader = "X-Runner-Event"
+
+	deadLetterFile   = "dead-letter.jsonl"
+	webhookQueueSize = 1000
+	webhookTimeout   = 10 * time.Second
+	// webhookQueueIdle - очередь адреса, в которую не поступали события, удаляется вместе с её горутиной
+	webhookQueueIdle = time.Minute
+)
+
+// privateNetworks - адреса внутренней сети, на которые не отправляются события callback_url задач
+var privateNetworks = []*net.IPNet{
+	parseNetwork("10.0.0.0/8"),
+	parseNetwork("172.16.0.0/12"),
+	parseNetwork("192.168.0.0/16"),
+	parseNetwork("100.64.0.0/10"),
+	parseNetwork("fc00::/7"),
+}
+
+type (
+	/*WebhookDispatcher - доставка изменений статусов задач на глобальный webhook и callback_url задач. События одного адреса доставляются по порядку, недоступный адрес не задерживает остальные*/
+	WebhookDispatcher struct {
+		globalURL   string
+		secret      []byte
+		maxAttempts int
+		backoff     time.Duration
+		path        string
+		client      *http.Client
+		// callbackClient - клиент для callback_url задач, без WEBHOOK_CALLBACK_ALLOW_PRIVATE не соединяется с внутренними адресами
+		callbackClient *http.Client
+		allowPrivate   bool
+		queueIdle      time.Duration
+
+		queuesMutex sync.Mutex
+		queues      map[string]chan models.TaskEvent // очередь событий по адресу получателя
+		filesMutex  sync.Mutex                       // защищает запись истории доставок и журнала недоставленных событий
+	}
+)
+
+/*NewWebhookDispatcher - инициализация доставки webhook по настройкам мастера*/
+func NewWebhookDispatcher(masterConfig *config.ConfigurationMasterRunner) (*WebhookDispatcher, error) {
+	if err := os.MkdirAll(masterConfig.PathToWebhooksWork, os.ModePerm); err != nil {
+		return nil, err
+	}
+	maxAttempts := masterConfig.WebhookMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	callbackClient := &http.Client{Timeout: webhookTimeout}
+	if !masterConfig.WebhookAllowPrivate {
+		callbackClient.Transport = publicTransport()
+	}
+	return &WebhookDispatcher{
+		globalURL:      masterConfig.WebhookURL,
+		secret:         []byte(masterConfig.WebhookSecret),
+		maxAttempts:    maxAttempts,
+		backoff:        time.Duration(masterConfig.WebhookBackoff) * time.Millisecond,
+		path:           masterConfig.PathToWebhooksWork,
+		client:         &http.Client{Timeout: webhookTimeout},
+		callbackClient: callbackClient,
+		allowPrivate:   masterConfig.WebhookAllowPrivate,
+		queueIdle:      webhookQueueIdle,
+		queues:         map[string]chan models.TaskEvent{},
+	}, nil
+}
+
+/*CheckCallbackURL - callback_url задачи не может указывать на локальный или внутренний адрес, если это не разрешено WEBHOOK_CALLBACK_ALLOW_PRIVATE. Адреса доменных имён проверяются при отправке*/
+func (dispatcher *WebhookDispatcher) CheckCallbackURL(callbackURL string) error {
+	if dispatcher == nil || dispatcher.allowPrivate || callbackURL == "" {
+		return nil
+	}
+	callback, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	host := callback.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errors.New("callback_url can not point to local address: " + host)
+	}
+	if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+		return errors.New("callback_url can not point to local or private address: " + host)
+	}
+	return nil
+}
+
+/*TaskChanged - постановка события в очереди адресов получателей без ожидания доставки*/
+func (dispatcher *WebhookDispatcher) TaskChanged(event models.TaskEvent, task models.Task) {
+	urls := []string{}
+	if dispatcher.globalURL != "" {
+		urls = append(urls, dispatcher.globalURL)
+	}
+	if task.Config != nil && task.Config.CallbackURL != "" && task.Config.CallbackURL != dispatcher.globalURL {
+		urls = append(urls, task.Config.CallbackURL)
+	}
+	for _, url := range urls {
+		if !dispatcher.enqueue(url, event) {
+			log.Error("webhook queue is full, event was not delivered: ", event.ID, " url: ", url)
+			dispatcher.record(models.WebhookDelivery{URL: url, Event: event, Error: "webhook queue is full", TimeFinished: time.Now().Unix()})
+		}
+	}
+}
+
+/*History - история доставок событий задачи в порядке их завершения*/
+func (dispatcher *WebhookDispatcher) History(taskID string) ([]models.WebhookDelivery, error) {
+	result := []models.WebhookDelivery{}
+	file, err := os.Open(dispatcher.historyFile(taskID))
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var delivery models.WebhookDelivery
+		if errUnmarshal := json.Unmarshal(scanner.Bytes(), &delivery); errUnmarshal != nil {
+			return nil, errUnmarshal
+		}
+		result = append(result, delivery)
+	}
+	return result, scanner.Err()
+}
+
+/*Sign - подпись тела запроса ключом WEBHOOK_SECRET*/
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*enqueue - постановка события в очередь адреса без ожидания. Выполняется под queuesMutex, чтобы событие не попало в очередь, удаляемую по простою*/
+func (dispatcher *WebhookDispatcher) enqueue(url string, event models.TaskEvent) bool {
+	dispatcher.queuesMutex.Lock()
+	defer dispatcher.queuesMutex.Unlock()
+	queue, exist := dispatcher.queues[url]
+	if !exist {
+		queue = make(chan models.TaskEvent, webhookQueueSize)
+		dispatcher.queues[url] = queue
+		go dispatcher.deliverQueue(url, queue)
+	}
+	select {
+	case queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+/*deliverQueue - доставка событий адреса по порядку. Горутина завершается, если в очередь не поступали события queueIdle*/
+func (dispatcher *WebhookDispatcher) deliverQueue(url string, queue chan models.TaskEvent) {
+	for {
+		select {
+		case event := <-queue:
+			dispatcher.record(dispatcher.deliver(url, event))
+		case <-time.After(dispatcher.queueIdle):
+			if dispatcher.removeIdleQueue(url, queue) {
+				return
+			}
+		}
+	}
+}
+
+func (dispatcher *WebhookDispatcher) removeIdleQueue(url string, queue chan models.TaskEvent) bool {
+	dispatcher.queuesMutex.Lock()
+	defer dispatcher.queuesMutex.Unlock()
+	if len(queue) > 0 {
+		return false
+	}
+	delete(dispatcher.queues, url)
+	return true
+}
+
+/*deliver - отправка события с повторами. Повтор выполняется при ошибке сети и при ответе не 2xx*/
+func (dispatcher *WebhookDispatcher) deliver(url string, event models.TaskEvent) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{URL: url, Event: event}
+	body, err := json.Marshal(event)
+	if err != nil {
+		delivery.Error = err.Error()
+		delivery.TimeFinished = time.Now().Unix()
+		return delivery
+	}
+	for delivery.Attempts < dispatcher.maxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(dispatcher.backoff << uint(delivery.Attempts-1))
+		}
+		delivery.Attempts++
+		delivery.StatusCode, err = dispatcher.send(url, event.Type, body)
+		if err == nil {
+			delivery.Delivered, delivery.Error = true, ""
+			break
+		}
+		delivery.Error = err.Error()
+		log.Warn("can not deliver webhook: ", event.ID, " url: ", url, " attempt: ", delivery.Attempts, " by error: ", err)
+	}
+	delivery.TimeFinished = time.Now().Unix()
+	return delivery
+}
+
+func (dispatcher *WebhookDispatcher) send(url, eventType string, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, eventType)
+	if len(dispatcher.secret) > 0 {
+		request.Header.Set(SignatureHeader, Sign(dispatcher.secret, body))
+	}
+	client := dispatcher.client
+	if url != dispatcher.globalURL {
+		client = dispatcher.callbackClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, errors.New("webhook responded with status " + response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+/*record - запись результата в историю доставок задачи. Недоставленные события также пишутся в журнал dead-letter.jsonl*/
+func (dispatcher *WebhookDispatcher) record(delivery models.WebhookDelivery) {
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		log.Error("can not marshal webhook delivery: ", err)
+		return
+	}
+	dispatcher.filesMutex.Lock()
+	defer dispatcher.filesMutex.Unlock()
+	if errWrite := appendLine(dispatcher.historyFile(delivery.Event.TaskID), line); errWrite != nil {
+		log.Error("can not write webhook delivery history: ", errWrite)
+	}
+	if delivery.Delivered {
+		return
+	}
+	if errWrite := appendLine(dispatcher.path+"/"+deadLetterFile, line); errWrite != nil {
+		log.Error("can not write webhook dead letter: ", errWrite)
+	}
+}
+
+func (dispatcher *WebhookDispatcher) historyFile(taskID string) string {
+	return dispatcher.path + "/" + taskID + ".jsonl"
+}
+
+func appendLine(fileName string, line []byte) error {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+/*publicTransport - соединения только с публичными адресами, проверяется адрес, в который разрешилось имя хоста*/
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+				return errors.New("callback_url resolved to local or private address: " + host)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: webhookTimeout}
+}
+
+func privateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNetwork(cidr string) *net.IPNet {
+	_, network, _ := net.ParseCIDR(cidr)
+	return network
+}
@@ -0,0 +1,157 @@
+package notifications
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubitre/diplom/config"
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDispatcher(t *testing.T, globalURL string) (*WebhookDispatcher, string) {
+	return newTestDispatcherPrivate(t, globalURL, true)
+}
+
+/*newTestDispatcherPrivate - allowPrivate разрешает callback_url на адреса тестовых серверов (127.0.0.1)*/
+func newTestDispatcherPrivate(t *testing.T, globalURL string, allowPrivate bool) (*WebhookDispatcher, string) {
+	path, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher, err := NewWebhookDispatcher(&config.ConfigurationMasterRunner{
+		WebhookURL:          globalURL,
+		WebhookSecret:       "secret",
+		WebhookMaxAttempts:  3,
+		WebhookBackoff:      1,
+		PathToWebhooksWork:  path,
+		WebhookAllowPrivate: allowPrivate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dispatcher, path
+}
+
+func waitHistory(t *testing.T, dispatcher *WebhookDispatcher, taskID string, count int) []models.WebhookDelivery {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		history, err := dispatcher.History(taskID)
+		assert.Nil(t, err)
+		if len(history) >= count {
+			return history
+		}
+	}
+	t.Fatal("webhook deliveries were not recorded")
+	return nil
+}
+
+func Test_WebhookRetriesAndSignature(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		assert.Equal(t, Sign([]byte("secret"), body), request.Header.Get(SignatureHeader))
+		assert.Equal(t, models.TaskEventJob, request.Header.Get(EventHeader))
+		if atomic.AddInt32(&requests, 1) == 1 {
+			writer.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	dispatcher, path := newTestDispatcher(t, server.URL)
+	defer os.RemoveAll(path)
+
+	dispatcher.TaskChanged(models.TaskEvent{ID: "event", Type: models.TaskEventJob, TaskID: "task", Job: "build"}, models.Task{ID: "task"})
+	history := waitHistory(t, dispatcher, "task", 1)
+	assert.True(t, history[0].Delivered)
+	assert.Equal(t, 2, history[0].Attempts)
+	assert.Equal(t, http.StatusOK, history[0].StatusCode)
+	_, err := os.Stat(path + "/" + deadLetterFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_WebhookDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	dispatcher, path := newTestDispatcher(t, "")
+	defer os.RemoveAll(path)
+
+	task := models.Task{ID: "task", Config: &models.TaskConfig{CallbackURL: server.URL}}
+	dispatcher.TaskChanged(models.TaskEvent{ID: "event", Type: models.TaskEventTask, TaskID: "task", Status: "fail"}, task)
+	history := waitHistory(t, dispatcher, "task", 1)
+	assert.False(t, history[0].Delivered)
+	assert.Equal(t, 3, history[0].Attempts)
+	assert.Equal(t, server.URL, history[0].URL)
+	deadLetter, err := ioutil.ReadFile(path + "/" + deadLetterFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(string(deadLetter), "\n"))
+}
+
+func Test_WebhookCallbackPrivateAddress(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+	// глобальный webhook задаётся администратором и может быть внутренним адресом
+	dispatcher, path := newTestDispatcherPrivate(t, server.URL, false)
+	defer os.RemoveAll(path)
+
+	assert.NotNil(t, dispatcher.CheckCallbackURL("http://127.0.0.1:8500/v1/agent/service/register"))
+	assert.NotNil(t, dispatcher.CheckCallbackURL("http://localhost/callback"))
+	assert.NotNil(t, dispatcher.CheckCallbackURL("http://10.0.0.5/callback"))
+	assert.NotNil(t, dispatcher.CheckCallbackURL("http://[::1]/callback"))
+	assert.NotNil(t, dispatcher.CheckCallbackURL("http://169.254.169.254/latest/meta-data"))
+	assert.Nil(t, dispatcher.CheckCallbackURL("https://portal.example.com/callback"))
+	assert.Nil(t, dispatcher.CheckCallbackURL(""))
+
+	// имя хоста проверяется после разрешения в адрес
+	callback := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/callback"
+	task := models.Task{ID: "task", Config: &models.TaskConfig{CallbackURL: callback}}
+	dispatcher.TaskChanged(models.TaskEvent{ID: "event", Type: models.TaskEventTask, TaskID: "task", Status: "fail"}, task)
+	history := waitHistory(t, dispatcher, "task", 2)
+	for _, delivery := range history {
+		assert.Equal(t, delivery.URL == server.URL, delivery.Delivered)
+		if delivery.URL == callback {
+			assert.Contains(t, delivery.Error, "private address")
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func Test_WebhookIdleQueueRemoved(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	defer server.Close()
+	dispatcher, path := newTestDispatcher(t, "")
+	defer os.RemoveAll(path)
+	dispatcher.queueIdle = 50 * time.Millisecond
+
+	for index := 0; index < 3; index++ {
+		taskID := "task" + string(rune('0'+index))
+		task := models.Task{ID: taskID, Config: &models.TaskConfig{CallbackURL: server.URL + "/" + taskID}}
+		dispatcher.TaskChanged(models.TaskEvent{ID: "event", Type: models.TaskEventTask, TaskID: taskID}, task)
+		waitHistory(t, dispatcher, taskID, 1)
+	}
+	queues := func() int {
+		dispatcher.queuesMutex.Lock()
+		defer dispatcher.queuesMutex.Unlock()
+		return len(dispatcher.queues)
+	}
+	for start := time.Now(); queues() > 0 && time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+	}
+	assert.Equal(t, 0, queues())
+
+	// после удаления очереди события адреса снова доставляются
+	task := models.Task{ID: "task0", Config: &models.TaskConfig{CallbackURL: server.URL + "/task0"}}
+	dispatcher.TaskChanged(models.TaskEvent{ID: "second", Type: models.TaskEventTask, TaskID: "task0"}, task)
+	history := waitHistory(t, dispatcher, "task0", 2)
+	assert.True(t, history[1].Delivered)
+}
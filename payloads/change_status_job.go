@@ -4,4 +4,5 @@ type ChangeStatusJob struct {
 	TaskID    string `json:"task_id"`
 	NewStatus int    `json:"new_status"`
 	Job       string `json:"job"`
+	SlaveID   string `json:"slave_id"` // слейв, выполняющий задачу
 }
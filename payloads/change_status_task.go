@@ -8,11 +8,13 @@ type ChangeStatusTask struct {
 	TaskID       string `json:"task_id"`
 	NewStatus    int    `json:"new_status"`
 	CurrentStage string `json:"stage"`
+	SlaveID      string `json:"slave_id"`         // слейв, выполняющий задачу
+	Commit       string `json:"commit,omitempty"` // SHA склонированного репозитория кандидата
 }
 
 /*Validate - валидация пришедшего обновления статуса*/
 func (statusWork *ChangeStatusTask) Validate() error {
-	if statusWork.NewStatus < 0 || statusWork.NewStatus > 5 {
+	if statusWork.NewStatus < 0 || statusWork.NewStatus > 7 {
 		return errors.New("can not find this status")
 	}
 	return nil
@@ -23,9 +23,9 @@ func TestMarshal(t *testing.T) {
 					"{{workdir repoCandidate}}",
 				},
 				RepositoryCandidate: "https://github.com/kubitre/for_diplom.git",
-				Reports: map[string]string{
-					"allOutInfo": "^(?P<statusTest>FAIL|ok)\\s+(?P<Placement>[\\w_\\/]+)\\s+(?P<Time>[\\w.]+)$",
-					"failedTest": "(?P<TEST>(--- FAIL: )(?P<TestName>[\\w]+)\\s+\\((?P<Time>[\\w.]+)\\))|(?P<Logs>\\s+(?P<fileName>[\\w_.]+):(?P<LineNumber>\\w+): (?P<LogText>.+))",
+				Reports: map[string]models.ReportConfig{
+					"allOutInfo": {Type: models.ReportTypeRegex, Regex: "^(?P<statusTest>FAIL|ok)\\s+(?P<Placement>[\\w_\\/]+)\\s+(?P<Time>[\\w.]+)$"},
+					"failedTest": {Type: models.ReportTypeRegex, Regex: "(?P<TEST>(--- FAIL: )(?P<TestName>[\\w]+)\\s+\\((?P<Time>[\\w.]+)\\))|(?P<Logs>\\s+(?P<fileName>[\\w_.]+):(?P<LineNumber>\\w+): (?P<LogText>.+))"},
 				},
 			},
 		},
@@ -33,3 +33,22 @@ func TestMarshal(t *testing.T) {
 	result, _ := yaml.Marshal(&taskConfig)
 	t.Log(result)
 }
+
+func TestUnmarshalReports(t *testing.T) {
+	var job models.Job
+	if err := yaml.Unmarshal([]byte(`
+reports:
+  warnings: "(\\d+) warnings"
+  unit:
+    type: junit
+    path: /app/report.xml
+`), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Reports["warnings"] != (models.ReportConfig{Type: models.ReportTypeRegex, Regex: "(\\d+) warnings"}) {
+		t.Error("legacy regex report was not parsed: ", job.Reports["warnings"])
+	}
+	if job.Reports["unit"] != (models.ReportConfig{Type: models.ReportTypeJUnit, Path: "/app/report.xml"}) {
+		t.Error("junit report was not parsed: ", job.Reports["unit"])
+	}
+}
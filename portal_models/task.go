@@ -9,8 +9,15 @@ import (
 type (
 	/*PortalTask - формальное описание задачи, приходящей из портала*/
 	PortalTask struct {
-		TaskID    string     `json:"id"`
-		JobGroups []JobGroup `json:"job_groups"`
+		TaskID      string              `json:"id"`
+		Priority    int                 `json:"priority"`
+		SlaveLabels []string            `json:"slave_labels"`
+		Retry       *models.RetryPolicy `json:"retry"`
+		Timeout     int64               `json:"timeout"`
+		Scoring     *models.Scoring     `json:"scoring"`
+		CallbackURL string              `json:"callback_url"`
+		Source      string              `json:"source"` // archive - код кандидата загружен на мастер архивом
+		JobGroups   []JobGroup          `json:"job_groups"`
 	}
 
 	/*JobGroup - группа джоб*/
@@ -22,23 +29,48 @@ type (
 
 	/*Job - джоба*/
 	Job struct {
-		JobName    string   `json:"name"`
-		Dockerfile string   `json:"docker_file"`
-		Timeout    int64    `json:"timeout"`
-		Metrics    []Metric `json:"metrics"`
+		JobName    string              `json:"name"`
+		Dockerfile string              `json:"docker_file"`
+		Timeout    int64               `json:"timeout"`
+		Metrics    []Metric            `json:"metrics"`
+		Retry      *models.RetryPolicy `json:"retry"`
+
+		AllowFailure     bool                       `json:"allow_failure"`
+		SuccessExitCodes []int64                    `json:"success_exit_codes"`
+		Resources        *models.ContainerResources `json:"resources"`
+		Artefacts        []string                   `json:"artefacts"`
+		Needs            []string                   `json:"needs"`
+		Repo             string                     `json:"repo"`       // репозиторий кандидата, копируется в образ вместо {{repoCandidate}}
+		Ref              string                     `json:"ref"`        // ветка или тег репозитория
+		Commit           string                     `json:"commit"`     // полный SHA коммита репозитория
+		Depth            int                        `json:"depth"`      // глубина истории при клонировании
+		Submodules       bool                       `json:"submodules"` // клонировать подмодули
+		RepoAuth         *models.RepoAuth           `json:"repo_auth"`
+		Secrets          map[string]string          `json:"secrets"` // переменная окружения -> имя секрета мастера
 	}
 
-	/*Metric - метрики для отчёта*/
+	/*Metric - метрики для отчёта. Без типа - регулярное выражение для логов job*/
 	Metric struct {
 		MetricName string `json:"key"`
 		Regex      string `json:"regex"`
+		Type       string `json:"type"`      // models.ReportType...
+		Path       string `json:"path"`      // файл или директория с отчётами в контейнере job
+		Aggregate  string `json:"aggregate"` // агрегация совпадений регулярного выражения (count, sum, min, max, last)
+		Field      string `json:"field"`     // именованная группа, значения которой агрегируются
 	}
 )
 
 // ConvertToAgentTask - конвертер в модель агента
 func (task *PortalTask) ConvertToAgentTask() models.TaskConfig {
 	needModel := models.TaskConfig{
-		TaskID: task.TaskID,
+		TaskID:      task.TaskID,
+		Priority:    task.Priority,
+		SlaveLabels: task.SlaveLabels,
+		Retry:       task.Retry,
+		Timeout:     task.Timeout,
+		Scoring:     task.Scoring,
+		CallbackURL: task.CallbackURL,
+		Source:      task.Source,
 	}
 	stages := []string{}
 	jobs := map[string]models.Job{}
@@ -87,14 +119,38 @@ func (job *Job) convertToAgent(taskID, stageName string) models.Job {
 		Stage:   stageName,
 		Timeout: job.Timeout,
 		TaskID:  taskID,
+		Retry:   job.Retry,
+
+		AllowFailure:        job.AllowFailure,
+		SuccessExitCodes:    job.SuccessExitCodes,
+		Resources:           job.Resources,
+		Artefacts:           job.Artefacts,
+		Needs:               job.Needs,
+		RepositoryCandidate: job.Repo,
+		RepositoryRef:       job.Ref,
+		RepositoryCommit:    job.Commit,
+		RepositoryDepth:     job.Depth,
+		RepositorySubmodule: job.Submodules,
+		RepositoryAuth:      job.RepoAuth,
+		Secrets:             job.Secrets,
 	}
 }
 
 /*convertMetricsToMap - конвертирование необходимых метрик, которые надо парсить из логов*/
-func (job *Job) convertMetricsToMap() map[string]string {
-	result := map[string]string{}
+func (job *Job) convertMetricsToMap() map[string]models.ReportConfig {
+	result := map[string]models.ReportConfig{}
 	for _, metric := range job.Metrics {
-		result[metric.MetricName] = metric.Regex
+		reportType := metric.Type
+		if reportType == "" {
+			reportType = models.ReportTypeRegex
+		}
+		result[metric.MetricName] = models.ReportConfig{
+			Type:      reportType,
+			Path:      metric.Path,
+			Regex:     metric.Regex,
+			Aggregate: metric.Aggregate,
+			Field:     metric.Field,
+		}
 	}
 	return result
 }
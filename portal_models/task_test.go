@@ -45,8 +45,8 @@ func Test_ConvertFromPortalObjectToAgentTask(t *testing.T) {
 					"CMD echo \"kubitre awesome\"",
 				},
 				Stage: "stage1",
-				Reports: map[string]string{
-					"checkTest": "mySuperRegex",
+				Reports: map[string]models.ReportConfig{
+					"checkTest": {Type: models.ReportTypeRegex, Regex: "mySuperRegex"},
 				},
 				TaskID:  "test",
 				JobName: "test1",
@@ -0,0 +1,39 @@
+package report_parsers
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	/*coberturaParser - Cobertura XML: покрытие берётся из атрибутов корневого элемента coverage*/
+	coberturaParser struct{}
+
+	coberturaCoverage struct {
+		XMLName      xml.Name `xml:"coverage"`
+		LineRate     string   `xml:"line-rate,attr"`
+		LinesCovered int64    `xml:"lines-covered,attr"`
+		LinesValid   int64    `xml:"lines-valid,attr"`
+	}
+)
+
+func (parser coberturaParser) Parse(content []byte) (models.TestReport, error) {
+	var root coberturaCoverage
+	if err := xml.Unmarshal(content, &root); err != nil {
+		return models.TestReport{}, err
+	}
+	var coverage float64
+	if root.LinesValid > 0 {
+		coverage = float64(root.LinesCovered) * 100 / float64(root.LinesValid)
+	} else {
+		rate, err := strconv.ParseFloat(root.LineRate, 64)
+		if err != nil {
+			return models.TestReport{}, errors.New("cobertura report without line-rate")
+		}
+		coverage = rate * 100
+	}
+	return models.TestReport{Coverage: &coverage}, nil
+}
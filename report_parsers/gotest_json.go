@@ -0,0 +1,90 @@
+package report_parsers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	/*goTestJSONParser - вывод go test -json (события test2json по строке на событие). Строки не в формате JSON (например, ошибки сборки) пропускаются*/
+	goTestJSONParser struct{}
+
+	goTestEvent struct {
+		Action  string  `json:"Action"`
+		Package string  `json:"Package"`
+		Test    string  `json:"Test"`
+		Elapsed float64 `json:"Elapsed"`
+		Output  string  `json:"Output"`
+	}
+)
+
+var goCoverageRegex = regexp.MustCompile(`coverage: (\d+(?:\.\d+)?)% of statements`)
+
+func (parser goTestJSONParser) Parse(content []byte) (models.TestReport, error) {
+	cases := map[string]*models.TestCase{}
+	outputs := map[string]*strings.Builder{}
+	order := []string{}
+	coverages := map[string]float64{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Action == "" {
+			continue
+		}
+		if event.Test == "" {
+			if found := goCoverageRegex.FindStringSubmatch(event.Output); found != nil {
+				coverages[event.Package], _ = strconv.ParseFloat(found[1], 64)
+			}
+			continue
+		}
+		key := event.Package + "." + event.Test
+		testCase, exist := cases[key]
+		if !exist {
+			testCase = &models.TestCase{Name: event.Test, Suite: event.Package}
+			cases[key], outputs[key] = testCase, &strings.Builder{}
+			order = append(order, key)
+		}
+		switch event.Action {
+		case "output":
+			outputs[key].WriteString(event.Output)
+		case "pass":
+			testCase.Status, testCase.Duration = models.TestStatusPassed, event.Elapsed
+		case "fail":
+			testCase.Status, testCase.Duration = models.TestStatusFailed, event.Elapsed
+		case "skip":
+			testCase.Status, testCase.Duration = models.TestStatusSkipped, event.Elapsed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return models.TestReport{}, err
+	}
+	report := models.TestReport{}
+	for _, key := range order {
+		testCase := cases[key]
+		// тест без результата - пакет завершился во время его выполнения (panic, таймаут go test)
+		if testCase.Status == "" {
+			testCase.Status = models.TestStatusFailed
+		}
+		if testCase.Status == models.TestStatusFailed {
+			testCase.Failure = strings.TrimSpace(outputs[key].String())
+		}
+		report.Cases = append(report.Cases, *testCase)
+	}
+	if len(coverages) > 0 {
+		var coverage float64
+		for _, packageCoverage := range coverages {
+			coverage += packageCoverage
+		}
+		coverage /= float64(len(coverages))
+		report.Coverage = &coverage
+	}
+	report.Summarize()
+	return report, nil
+}
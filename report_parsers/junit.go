@@ -0,0 +1,95 @@
+package report_parsers
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	/*junitParser - JUnit XML: корневой элемент testsuites или testsuite, наборы тестов могут быть вложенными*/
+	junitParser struct{}
+
+	junitSuite struct {
+		Name   string       `xml:"name,attr"`
+		Time   string       `xml:"time,attr"`
+		Suites []junitSuite `xml:"testsuite"`
+		Cases  []junitCase  `xml:"testcase"`
+	}
+
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure"`
+		Error     *junitFailure `xml:"error"`
+		Skipped   *junitFailure `xml:"skipped"`
+	}
+
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+func (parser junitParser) Parse(content []byte) (models.TestReport, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(content, &root); err != nil {
+		return models.TestReport{}, err
+	}
+	report := models.TestReport{Duration: parseSeconds(root.Time)}
+	report.Cases = junitCases(root)
+	report.Summarize()
+	return report, nil
+}
+
+func junitCases(suite junitSuite) []models.TestCase {
+	result := []models.TestCase{}
+	for _, testCase := range suite.Cases {
+		converted := models.TestCase{
+			Name:     testCase.Name,
+			Suite:    testCase.ClassName,
+			Status:   models.TestStatusPassed,
+			Duration: parseSeconds(testCase.Time),
+		}
+		if converted.Suite == "" {
+			converted.Suite = suite.Name
+		}
+		switch {
+		case testCase.Failure != nil:
+			converted.Status, converted.Failure = models.TestStatusFailed, testCase.Failure.message()
+		case testCase.Error != nil:
+			converted.Status, converted.Failure = models.TestStatusFailed, testCase.Error.message()
+		case testCase.Skipped != nil:
+			converted.Status = models.TestStatusSkipped
+		}
+		result = append(result, converted)
+	}
+	for _, nested := range suite.Suites {
+		result = append(result, junitCases(nested)...)
+	}
+	return result
+}
+
+/*message - сообщение об ошибке и её подробности (обычно stack trace)*/
+func (failure *junitFailure) message() string {
+	text := strings.TrimSpace(failure.Text)
+	switch {
+	case failure.Message == "":
+		return text
+	case text == "":
+		return failure.Message
+	}
+	return failure.Message + "\n" + text
+}
+
+/*parseSeconds - длительность в секундах. Некоторые генераторы JUnit отчётов добавляют разделители разрядов*/
+func parseSeconds(value string) float64 {
+	seconds, err := strconv.ParseFloat(strings.Replace(value, ",", "", -1), 64)
+	if err != nil {
+		return 0
+	}
+	return seconds
+}
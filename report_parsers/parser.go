@@ -0,0 +1,51 @@
+package report_parsers
+
+import (
+	"errors"
+
+	"github.com/kubitre/diplom/models"
+)
+
+type (
+	/*Parser - разбор содержимого одного файла отчёта в тесты и покрытие*/
+	Parser interface {
+		Parse(content []byte) (models.TestReport, error)
+	}
+)
+
+/*parsers - разборщики отчётов по типу отчёта job (models.ReportType...). Отчёты regex разбираются слейвом отдельно, т.к. дают метрики, а не тесты*/
+var parsers = map[string]Parser{
+	models.ReportTypeJUnit:      junitParser{},
+	models.ReportTypeGoTestJSON: goTestJSONParser{},
+	models.ReportTypeTAP:        tapParser{},
+	models.ReportTypeCobertura:  coberturaParser{},
+}
+
+/*Parse - разбор файлов отчёта одного типа (например, всех файлов из директории отчётов job) в один отчёт. Покрытие - среднее по файлам, в которых оно есть*/
+func Parse(reportType string, contents [][]byte) (models.TestReport, error) {
+	parser, exist := parsers[reportType]
+	if !exist {
+		return models.TestReport{}, errors.New("not found parser for report type: " + reportType)
+	}
+	result := models.TestReport{Type: reportType}
+	var coverage float64
+	var withCoverage int
+	for _, content := range contents {
+		report, err := parser.Parse(content)
+		if err != nil {
+			return models.TestReport{}, err
+		}
+		result.Cases = append(result.Cases, report.Cases...)
+		result.Duration += report.Duration
+		if report.Coverage != nil {
+			coverage += *report.Coverage
+			withCoverage++
+		}
+	}
+	if withCoverage > 0 {
+		coverage /= float64(withCoverage)
+		result.Coverage = &coverage
+	}
+	result.Summarize()
+	return result, nil
+}
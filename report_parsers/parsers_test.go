@@ -0,0 +1,89 @@
+package report_parsers
+
+import (
+	"testing"
+
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JUnitParser(t *testing.T) {
+	report, err := Parse(models.ReportTypeJUnit, [][]byte{[]byte(`<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="api" time="1,002.5">
+    <testcase name="create" classname="api.Tasks" time="0.5"/>
+    <testcase name="cancel" classname="api.Tasks" time="1.5">
+      <failure message="expected 200">stack trace</failure>
+    </testcase>
+    <testsuite name="nested">
+      <testcase name="skipped"><skipped/></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, models.TestCase{Name: "cancel", Suite: "api.Tasks", Status: models.TestStatusFailed, Duration: 1.5, Failure: "expected 200\nstack trace"}, report.Cases[1])
+	assert.Equal(t, "nested", report.Cases[2].Suite)
+
+	_, err = Parse(models.ReportTypeJUnit, [][]byte{[]byte("not xml")})
+	assert.NotNil(t, err)
+}
+
+func Test_GoTestJSONParser(t *testing.T) {
+	report, err := Parse(models.ReportTypeGoTestJSON, [][]byte{[]byte(`# github.com/kubitre/diplom/broken
+{"Action":"run","Package":"pkg/a","Test":"TestOk"}
+{"Action":"pass","Package":"pkg/a","Test":"TestOk","Elapsed":0.25}
+{"Action":"run","Package":"pkg/a","Test":"TestFail"}
+{"Action":"output","Package":"pkg/a","Test":"TestFail","Output":"    a_test.go:10: wrong value\n"}
+{"Action":"fail","Package":"pkg/a","Test":"TestFail","Elapsed":0.5}
+{"Action":"output","Package":"pkg/a","Output":"coverage: 80.0% of statements\n"}
+{"Action":"run","Package":"pkg/b","Test":"TestPanic"}
+{"Action":"output","Package":"pkg/b","Test":"TestPanic","Output":"panic: boom\n"}
+{"Action":"output","Package":"pkg/b","Output":"coverage: 60.0% of statements\n"}
+{"Action":"fail","Package":"pkg/b","Elapsed":1}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, "a_test.go:10: wrong value", report.Cases[1].Failure)
+	assert.Equal(t, models.TestStatusFailed, report.Cases[2].Status)
+	assert.Equal(t, 0.75, report.Duration)
+	assert.Equal(t, 70.0, *report.Coverage)
+}
+
+func Test_TAPParser(t *testing.T) {
+	report, err := Parse(models.ReportTypeTAP, [][]byte{[]byte(`TAP version 13
+1..4
+ok 1 - first
+not ok 2 - second
+  ---
+  message: values differ
+  duration_ms: 250
+  ...
+ok 3 # SKIP not supported
+not ok 4 - later # TODO implement
+`)})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, models.TestCase{Name: "second", Status: models.TestStatusFailed, Duration: 0.25, Failure: "values differ"}, report.Cases[1])
+	assert.Equal(t, "test 3", report.Cases[2].Name)
+}
+
+func Test_CoberturaParserAveragesFiles(t *testing.T) {
+	report, err := Parse(models.ReportTypeCobertura, [][]byte{
+		[]byte(`<coverage line-rate="0.5" lines-covered="1" lines-valid="4"></coverage>`),
+		[]byte(`<coverage line-rate="0.75"></coverage>`),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Total)
+	assert.Equal(t, 50.0, *report.Coverage)
+
+	_, err = Parse("unknown", nil)
+	assert.NotNil(t, err)
+}
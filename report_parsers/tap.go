@@ -0,0 +1,112 @@
+package report_parsers
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubitre/diplom/models"
+	"gopkg.in/yaml.v2"
+)
+
+/*tapParser - Test Anything Protocol: строки ok/not ok с директивами SKIP и TODO и yaml блоками диагностики после теста. Остальные строки пропускаются*/
+type tapParser struct{}
+
+var (
+	tapTestRegex    = regexp.MustCompile(`^(not )?ok\b\s*(\d+)?\s*(?:-\s*)?([^#]*?)\s*(?:#\s*(\w+)\b\s*(.*))?$`)
+	tapBailOutRegex = regexp.MustCompile(`^Bail out!\s*(.*)$`)
+)
+
+func (parser tapParser) Parse(content []byte) (models.TestReport, error) {
+	report := models.TestReport{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var diagnostic []string
+	diagnosticIndent := -1
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if diagnosticIndent >= 0 {
+			if trimmed == "..." {
+				applyTAPDiagnostic(&report.Cases[len(report.Cases)-1], diagnostic)
+				diagnostic, diagnosticIndent = nil, -1
+				continue
+			}
+			if len(line) >= diagnosticIndent {
+				line = line[diagnosticIndent:]
+			}
+			diagnostic = append(diagnostic, line)
+			continue
+		}
+		if trimmed == "---" && len(report.Cases) > 0 {
+			diagnosticIndent = strings.Index(line, "---")
+			continue
+		}
+		if found := tapBailOutRegex.FindStringSubmatch(trimmed); found != nil {
+			report.Cases = append(report.Cases, models.TestCase{Name: "Bail out!", Status: models.TestStatusFailed, Failure: found[1]})
+			break
+		}
+		if found := tapTestRegex.FindStringSubmatch(trimmed); found != nil {
+			report.Cases = append(report.Cases, tapCase(found, len(report.Cases)+1))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return models.TestReport{}, err
+	}
+	report.Summarize()
+	return report, nil
+}
+
+/*tapCase - тест из строки результата. Тесты с TODO не считаются упавшими и отмечаются пропущенными*/
+func tapCase(found []string, number int) models.TestCase {
+	testCase := models.TestCase{Name: found[3], Status: models.TestStatusPassed}
+	if testCase.Name == "" {
+		if found[2] != "" {
+			number, _ = strconv.Atoi(found[2])
+		}
+		testCase.Name = "test " + strconv.Itoa(number)
+	}
+	if found[1] != "" {
+		testCase.Status = models.TestStatusFailed
+	}
+	switch strings.ToUpper(found[4]) {
+	case "SKIP", "TODO":
+		testCase.Status = models.TestStatusSkipped
+	}
+	return testCase
+}
+
+/*applyTAPDiagnostic - сообщение об ошибке и длительность (duration_ms) из yaml блока диагностики теста*/
+func applyTAPDiagnostic(testCase *models.TestCase, diagnostic []string) {
+	block := strings.Join(diagnostic, "\n")
+	var fields map[string]interface{}
+	// блок может быть не в формате yaml - тогда сообщением об ошибке считается весь блок
+	yaml.Unmarshal([]byte(block), &fields)
+	if duration, ok := fields["duration_ms"]; ok {
+		if milliseconds, err := strconv.ParseFloat(strings.TrimSpace(toString(duration)), 64); err == nil {
+			testCase.Duration = milliseconds / 1000
+		}
+	}
+	if testCase.Status != models.TestStatusFailed {
+		return
+	}
+	if message, ok := fields["message"]; ok {
+		testCase.Failure = toString(message)
+		return
+	}
+	testCase.Failure = strings.TrimSpace(block)
+}
+
+func toString(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case int:
+		return strconv.Itoa(typed)
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	}
+	return ""
+}
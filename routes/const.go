@@ -6,16 +6,31 @@ const (
 	ApiAvailableWorkers      = ApiWorkers + "/status"
 	ApiTask                  = "/task"
 	ApiTaskCreate            = ApiTask
+	ApiTaskCancel            = ApiTask + "/{taskID:\\w+}"
 	ApiTaskChangeOrGetStatus = ApiTask + "/{taskID:\\w+}/status"
 	ApiJobChangeOrGetStatus  = ApiTaskChangeOrGetStatus + "/{jobName:\\w+}"
-	ApiTaskReport            = ApiTask + "/{taskID:\\w+}/reports/{job:\\w+}"
+	ApiTaskReports           = ApiTask + "/{taskID:\\w+}/reports"
+	ApiTaskReport            = ApiTaskReports + "/{job:\\w+}"
 	ApiTaskLogJob            = ApiTask + "/{taskID:\\w+}/log/{stage:\\w+}/{job:\\w+}"
+	ApiTaskLogJobStream      = ApiTaskLogJob + "/stream"
 	ApiTaskLogStage          = ApiTask + "/{taskID:\\w+}/log/{stage:\\w+}"
 	ApiTaskLogTask           = ApiTask + "/{taskID:\\w+}/log"
 
 	ApiTaskLogAll = ApiTask + "/getlogs"
 
+	ApiTaskArtefacts    = ApiTask + "/{taskID:\\w+}/artefacts"
+	ApiTaskArtefactsJob = ApiTaskArtefacts + "/{stage:\\w+}/{job:\\w+}"
+	ApiTaskArtefactFile = ApiTaskArtefactsJob + "/file"
+
+	ApiTaskWebhooks = ApiTask + "/{taskID:\\w+}/webhooks"
+
+	ApiTaskSource = ApiTask + "/{taskID:\\w+}/source"
+
 	ApiHealthCheck = "/health"
 
-	ApiTasksView = ApiTask + "/all"
+	ApiSecrets = "/secrets"
+	ApiSecret  = ApiSecrets + "/{name:\\w+}"
+
+	ApiTasksView    = ApiTask + "/all"
+	ApiTasksHistory = ApiTask + "/history"
 )
@@ -9,12 +9,25 @@ import (
 /*IMaster - интерфейс, который должны реализовать любые плагины для мастер ноды*/
 type IMaster interface {
 	CreateNewTask(http.ResponseWriter, *http.Request)
+	CancelTask(http.ResponseWriter, *http.Request)
 	ChangeTaskStatus(http.ResponseWriter, *http.Request)
 	GetLogTask(http.ResponseWriter, *http.Request)
 	CreateLogTask(http.ResponseWriter, *http.Request)
+	StreamLogTask(http.ResponseWriter, *http.Request)
+	TailLogTask(http.ResponseWriter, *http.Request)
 	GetTaskStatus(http.ResponseWriter, *http.Request)
 	GetStatusWorkers(http.ResponseWriter, *http.Request)
 	GetReportsPerTask(http.ResponseWriter, *http.Request)
+	GetReportsTask(http.ResponseWriter, *http.Request)
+	CreateArtefacts(http.ResponseWriter, *http.Request)
+	GetArtefacts(http.ResponseWriter, *http.Request)
+	GetArtefactFile(http.ResponseWriter, *http.Request)
+	GetWebhookDeliveries(http.ResponseWriter, *http.Request)
+	PutSecret(http.ResponseWriter, *http.Request)
+	DeleteSecret(http.ResponseWriter, *http.Request)
+	GetSecrets(http.ResponseWriter, *http.Request)
+	UploadSource(http.ResponseWriter, *http.Request)
+	GetSource(http.ResponseWriter, *http.Request)
 	GetRouter() *mux.Router // system method
 	ConfigureRouter()       //system method
 }
@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 
 	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/api_keys"
 	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/middlewares"
 	"github.com/kubitre/diplom/models"
 	"github.com/kubitre/diplom/payloads"
 	"github.com/kubitre/diplom/routes"
@@ -56,6 +58,12 @@ func (route *MasterRunnerRouterDefault) CreateNewTask(writer http.ResponseWriter
 	route.service.NewTask(&createNewTaskPayload, request, writer)
 }
 
+// CancelTask - отмена задачи DELETE /task/:taskID
+func (route *MasterRunnerRouterDefault) CancelTask(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	route.service.CancelTask(request, writer, vars["taskID"])
+}
+
 //ChangeTaskStatus - изменить текущий статус работы (остановить, запустить) post {taskID, status: [STARTED, STOPING, FINISHING, FAILED]}
 func (route *MasterRunnerRouterDefault) ChangeTaskStatus(writer http.ResponseWriter, request *http.Request) {
 	var statusTaskChangePayload payloads.ChangeStatusTask
@@ -126,6 +134,34 @@ func (route *MasterRunnerRouterDefault) CreateLogTask(writer http.ResponseWriter
 	route.service.CreateLogTask(request, writer)
 }
 
+// StreamLogTask - приём строк лога выполняющейся job со слейва post (по строке JSON {stream, line})
+func (route *MasterRunnerRouterDefault) StreamLogTask(writer http.ResponseWriter, request *http.Request) {
+	route.service.CreateLogStream(request, writer)
+}
+
+// TailLogTask - трансляция лога выполняющейся job через SSE get ?offset=:offset
+func (route *MasterRunnerRouterDefault) TailLogTask(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	route.service.TailLogStream(request, writer, vars["taskID"], vars["stage"], vars["job"])
+}
+
+// CreateArtefacts - сохранение артефактов job со слейва post tar архив ?path=:path
+func (route *MasterRunnerRouterDefault) CreateArtefacts(writer http.ResponseWriter, request *http.Request) {
+	route.service.CreateArtefacts(request, writer)
+}
+
+// GetArtefacts - список артефактов задачи или job
+func (route *MasterRunnerRouterDefault) GetArtefacts(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	route.service.GetArtefacts(request, writer, vars["taskID"], vars["stage"], vars["job"])
+}
+
+// GetArtefactFile - скачивание файла артефакта job get ?path=:path
+func (route *MasterRunnerRouterDefault) GetArtefactFile(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	route.service.GetArtefactFile(request, writer, vars["taskID"], vars["stage"], vars["job"], request.URL.Query().Get("path"))
+}
+
 // GetTaskStatus - получение статуса задачи GET /taskID=:taskID
 func (route *MasterRunnerRouterDefault) GetTaskStatus(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
@@ -143,7 +179,16 @@ func (route *MasterRunnerRouterDefault) GetTaskStatus(writer http.ResponseWriter
 		}, http.StatusBadRequest)
 		return
 	}
-	route.service.GetTaskStatus(request, writer, taskID)
+	task := route.service.GetTaskStatus(request, writer, taskID)
+	if task != nil {
+		position, depth := route.service.GetQueuePosition(task.ID)
+		enhancer.Response(request, writer, map[string]interface{}{
+			"task":           task.ConvertToPayload(),
+			"queue_position": position,
+			"queue_depth":    depth,
+			"score":          route.service.GetTaskScore(task),
+		}, http.StatusOK)
+	}
 }
 
 // GetStatusWorkers -  получение текущего статуса всех slave нод
@@ -153,7 +198,24 @@ func (route *MasterRunnerRouterDefault) GetStatusWorkers(writer http.ResponseWri
 
 // GetReportsPerTask - получение отчётов по задаче
 func (route *MasterRunnerRouterDefault) GetReportsPerTask(writer http.ResponseWriter, request *http.Request) {
-	route.service.GetReportPerTask(request, writer)
+	reports := route.service.GetReportPerTask(request, writer)
+	if reports == nil {
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"metrics": reports.Result,
+		"tests":   reports.Tests,
+	}, http.StatusOK)
+}
+
+// GetWebhookDeliveries - история доставок изменений статусов задачи на webhook
+func (route *MasterRunnerRouterDefault) GetWebhookDeliveries(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetWebhookDeliveries(request, writer, mux.Vars(request)["taskID"])
+}
+
+// GetReportsTask - получение отчётов всех job задачи
+func (route *MasterRunnerRouterDefault) GetReportsTask(writer http.ResponseWriter, request *http.Request) {
+	route.service.WriteReportsTask(request, writer, mux.Vars(request)["taskID"])
 }
 
 // CreateReportsPerTask - создание метрик на задачу из слейва
@@ -162,6 +224,16 @@ func (route *MasterRunnerRouterDefault) CreateReportsPerTask(writer http.Respons
 	route.service.CreateReportsPerTask(request, writer)
 }
 
+// GetAllTasks - получение всех задач мастера
+func (route *MasterRunnerRouterDefault) GetAllTasks(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetAllTasks(request, writer)
+}
+
+// GetTasksHistory - получение истории задач GET ?from=:unix&to=:unix
+func (route *MasterRunnerRouterDefault) GetTasksHistory(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetTasksHistory(request, writer)
+}
+
 // healthcheck - статус сервиса для service discovery
 func (route *MasterRunnerRouterDefault) healthCheck(writer http.ResponseWriter, request *http.Request) {
 	// implement logic for return current running works and amount slaves
@@ -189,23 +261,69 @@ func (route *MasterRunnerRouterDefault) notFoundHandler(writer http.ResponseWrit
 	}, http.StatusNotFound)
 }
 
+// PutSecret - сохранение секрета для job PUT {value}
+func (route *MasterRunnerRouterDefault) PutSecret(writer http.ResponseWriter, request *http.Request) {
+	route.service.PutSecret(request, writer, mux.Vars(request)["name"])
+}
+
+// DeleteSecret - удаление секрета
+func (route *MasterRunnerRouterDefault) DeleteSecret(writer http.ResponseWriter, request *http.Request) {
+	route.service.DeleteSecret(request, writer, mux.Vars(request)["name"])
+}
+
+// GetSecrets - имена секретов без значений
+func (route *MasterRunnerRouterDefault) GetSecrets(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetSecrets(request, writer)
+}
+
+// UploadSource - загрузка архива кода кандидата до создания задачи POST {zip, tar.gz или tar}
+func (route *MasterRunnerRouterDefault) UploadSource(writer http.ResponseWriter, request *http.Request) {
+	route.service.UploadSource(request, writer, mux.Vars(request)["taskID"])
+}
+
+// GetSource - архив кода кандидата для слейва
+func (route *MasterRunnerRouterDefault) GetSource(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetSource(request, writer, mux.Vars(request)["taskID"])
+}
+
+/*withScope - проверка ключа API с правом scope и запись запроса в журнал*/
+func (route *MasterRunnerRouterDefault) withScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return middlewares.CheckAPIKey(route.service.GetAPIKeys(), route.service.GetAuditLog(), scope, handler)
+}
+
 /*ConfigureRouter - конфигурирование маршрутов
  */
 func (route *MasterRunnerRouterDefault) ConfigureRouter() {
-	route.Router.HandleFunc(routes.ApiTaskCreate, route.CreateNewTask).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiTaskChangeOrGetStatus, route.ChangeTaskStatus).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiTaskChangeOrGetStatus, route.GetTaskStatus).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiJobChangeOrGetStatus, route.ChangeJobStatus).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiTaskLogJob, route.CreateLogTask).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiTaskLogJob, route.GetLogTask).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskLogStage, route.GetLogTask).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskLogTask, route.GetLogTask).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskLogTask, route.removeLogsPerTask).Methods(http.MethodDelete) // удаление логов задачи
-	route.Router.HandleFunc(routes.ApiTaskLogAll, route.getAllLogsTree).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiAvailableWorkers, route.GetStatusWorkers).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskReport, route.GetReportsPerTask).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskReport, route.CreateReportsPerTask).Methods(http.MethodPost) // создание отчёта по задаче
+	route.Router.HandleFunc(routes.ApiTaskCreate, route.withScope(api_keys.ScopeSubmit, route.CreateNewTask)).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskCancel, route.withScope(api_keys.ScopeSubmit, route.CancelTask)).Methods(http.MethodDelete)
+	route.Router.HandleFunc(routes.ApiTaskChangeOrGetStatus, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.ChangeTaskStatus))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskChangeOrGetStatus, route.withScope(api_keys.ScopeStatus, route.GetTaskStatus)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiJobChangeOrGetStatus, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.ChangeJobStatus))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskLogJob, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.CreateLogTask))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskLogJob, route.withScope(api_keys.ScopeLogs, route.GetLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogJobStream, middlewares.CheckRunnerStreamSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.StreamLogTask))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskLogJobStream, route.withScope(api_keys.ScopeLogs, route.TailLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogStage, route.withScope(api_keys.ScopeLogs, route.GetLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogTask, route.withScope(api_keys.ScopeLogs, route.GetLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogTask, route.withScope(api_keys.ScopeAdmin, route.removeLogsPerTask)).Methods(http.MethodDelete) // удаление логов задачи
+	route.Router.HandleFunc(routes.ApiTaskLogAll, route.withScope(api_keys.ScopeAdmin, route.getAllLogsTree)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskArtefacts, route.withScope(api_keys.ScopeLogs, route.GetArtefacts)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskArtefactsJob, route.withScope(api_keys.ScopeLogs, route.GetArtefacts)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskArtefactsJob, middlewares.CheckRunnerStreamSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.CreateArtefacts))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskArtefactFile, route.withScope(api_keys.ScopeLogs, route.GetArtefactFile)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiAvailableWorkers, route.withScope(api_keys.ScopeAdmin, route.GetStatusWorkers)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskReports, route.withScope(api_keys.ScopeStatus, route.GetReportsTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskWebhooks, route.withScope(api_keys.ScopeStatus, route.GetWebhookDeliveries)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskReport, route.withScope(api_keys.ScopeStatus, route.GetReportsPerTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskReport, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.CreateReportsPerTask))).Methods(http.MethodPost) // создание отчёта по задаче
+	route.Router.HandleFunc(routes.ApiTasksView, route.withScope(api_keys.ScopeStatus, route.GetAllTasks)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTasksHistory, route.withScope(api_keys.ScopeStatus, route.GetTasksHistory)).Methods(http.MethodGet)
 	route.Router.HandleFunc(routes.ApiHealthCheck, route.healthCheck).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiSecrets, route.withScope(api_keys.ScopeAdmin, route.GetSecrets)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiSecret, route.withScope(api_keys.ScopeAdmin, route.PutSecret)).Methods(http.MethodPut)
+	route.Router.HandleFunc(routes.ApiSecret, route.withScope(api_keys.ScopeAdmin, route.DeleteSecret)).Methods(http.MethodDelete)
+	route.Router.HandleFunc(routes.ApiTaskSource, route.withScope(api_keys.ScopeSubmit, route.UploadSource)).Methods(http.MethodPost, http.MethodPut)
+	route.Router.HandleFunc(routes.ApiTaskSource, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.GetSource))).Methods(http.MethodGet)
 	route.Router.NotFoundHandler = http.HandlerFunc(route.notFoundHandler)
 }
 
@@ -4,5 +4,10 @@ const (
 	APITask        = "/tasks"
 	APIStatusTask  = APITask + "/{taskID:\\w+}"
 	ApILogsPerTask = APITask + "/{taskID:\\w+}/log"
+	ApILogsStream  = ApILogsPerTask + "/stream"
+	APIArtefacts   = APITask + "/{taskID:\\w+}/artefacts"
+	APIArtefact    = APIArtefacts + "/file"
+	APIReports     = APITask + "/{taskID:\\w+}/reports"
+	APIWebhooks    = APITask + "/{taskID:\\w+}/webhooks"
 	ApiTaskReport  = APITask + "/{taskID:\\w+}/reports/{stage:\\w++}/{job:\\w+}"
 )
@@ -6,12 +6,13 @@ import (
 	"mime"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/api_keys"
 	"github.com/kubitre/diplom/enhancer"
 	"github.com/kubitre/diplom/middlewares"
-	"github.com/kubitre/diplom/models"
 	"github.com/kubitre/diplom/payloads"
 	"github.com/kubitre/diplom/portal_models"
 	"github.com/kubitre/diplom/routes"
@@ -61,6 +62,12 @@ func (route *MasterRunnerRouterPortal) CreateNewTask(writer http.ResponseWriter,
 	route.service.NewTask(&convertedTask, request, writer)
 }
 
+// CancelTask - отмена задачи по запросу из портала
+func (route *MasterRunnerRouterPortal) CancelTask(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	route.service.CancelTask(request, writer, vars["taskID"])
+}
+
 //ChangeTaskStatus - изменить текущий статус работы (остановить, запустить) post {taskID, status: [STARTED, STOPING, FINISHING, FAILED]}
 func (route *MasterRunnerRouterPortal) ChangeTaskStatus(writer http.ResponseWriter, request *http.Request) {
 	var statusTaskChangePayload payloads.ChangeStatusTask
@@ -111,6 +118,43 @@ func (route *MasterRunnerRouterPortal) CreateLogTask(writer http.ResponseWriter,
 	route.service.CreateLogTask(request, writer)
 }
 
+// StreamLogTask - приём строк лога выполняющейся job со слейва post (по строке JSON {stream, line})
+func (route *MasterRunnerRouterPortal) StreamLogTask(writer http.ResponseWriter, request *http.Request) {
+	route.service.CreateLogStream(request, writer)
+}
+
+// TailLogTask - трансляция лога выполняющейся job через SSE get ?job_group=:stage&job=:job&offset=:offset
+func (route *MasterRunnerRouterPortal) TailLogTask(writer http.ResponseWriter, request *http.Request) {
+	stage, job := stageAndJob(request)
+	route.service.TailLogStream(request, writer, mux.Vars(request)["taskID"], stage, job)
+}
+
+// CreateArtefacts - сохранение артефактов job со слейва post tar архив ?path=:path
+func (route *MasterRunnerRouterPortal) CreateArtefacts(writer http.ResponseWriter, request *http.Request) {
+	route.service.CreateArtefacts(request, writer)
+}
+
+// GetArtefacts - список артефактов задачи или job get ?job_group=:stage&job=:job
+func (route *MasterRunnerRouterPortal) GetArtefacts(writer http.ResponseWriter, request *http.Request) {
+	stage, job := stageAndJob(request)
+	route.service.GetArtefacts(request, writer, mux.Vars(request)["taskID"], stage, job)
+}
+
+// GetArtefactFile - скачивание файла артефакта job get ?job_group=:stage&job=:job&path=:path
+func (route *MasterRunnerRouterPortal) GetArtefactFile(writer http.ResponseWriter, request *http.Request) {
+	stage, job := stageAndJob(request)
+	route.service.GetArtefactFile(request, writer, mux.Vars(request)["taskID"], stage, job, request.URL.Query().Get("path"))
+}
+
+/*stageAndJob - этап и job из пути запроса или, для маршрутов портала, из параметров job_group и job*/
+func stageAndJob(request *http.Request) (string, string) {
+	vars := mux.Vars(request)
+	if vars["stage"] != "" {
+		return vars["stage"], vars["job"]
+	}
+	return request.URL.Query().Get("job_group"), request.URL.Query().Get("job")
+}
+
 // GetTaskStatus - получение статуса задачи GET /taskID=:taskID
 func (route *MasterRunnerRouterPortal) GetTaskStatus(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
@@ -144,17 +188,23 @@ func (route *MasterRunnerRouterPortal) GetTaskStatus(writer http.ResponseWriter,
 		} else {
 			resultData["stage"] = task.Stage
 		}
+		if position, depth := route.service.GetQueuePosition(task.ID); position > 0 {
+			resultData["queue_position"] = strconv.Itoa(position)
+			resultData["queue_depth"] = strconv.Itoa(depth)
+		}
+		if task.Commit != "" {
+			resultData["commit"] = task.Commit
+		}
+		if score := route.service.GetTaskScore(task); score != nil {
+			resultData = tools.AppendMap(resultData, enhancer.MergeScoreToString(score))
+		}
 
 		if errNotEnhanced != nil {
 			runnerData["reports"] = errNotEnhanced.Error()
 		} else {
-			reports, err := validators.ValidateMetricsForPortal(notEnhancedReports)
-			if err != nil {
-				runnerData["reports"] = err.Error()
-			} else {
-				runnerData = tools.AppendMap(runnerData, reports)
-			}
-			runnerData = tools.AppendMap(runnerData, enhancer.MergeMetricsToString(notEnhancedReports))
+			runnerData = tools.AppendMap(runnerData, validators.ValidateMetricsForPortal(notEnhancedReports.Result))
+			runnerData = tools.AppendMap(runnerData, enhancer.MergeMetricsToString(notEnhancedReports.Result))
+			runnerData = tools.AppendMap(runnerData, enhancer.MergeTestReportsToString(notEnhancedReports.Tests))
 		}
 
 		statusEnhanced := portal_models.PortalTaskStatus{
@@ -191,21 +241,26 @@ func (route *MasterRunnerRouterPortal) GetStatusWorkers(writer http.ResponseWrit
 
 // GetReportsPerTask - получение отчётов по задаче
 func (route *MasterRunnerRouterPortal) GetReportsPerTask(writer http.ResponseWriter, request *http.Request) {
-	metrics := route.service.GetReportPerTask(request, writer)
-	enhancedMetrics, errorValidating := validators.ValidateMetricsForPortal(metrics)
-	if errorValidating != nil {
-		log.Println("can not validated metrics: ", enhancedMetrics)
-		enhancer.Response(request, writer, map[string]interface{}{
-			"status": "bad validating metrics",
-			"trace":  errorValidating.Error(),
-		}, http.StatusConflict)
+	reports := route.service.GetReportPerTask(request, writer)
+	if reports == nil {
 		return
 	}
 	enhancer.Response(request, writer, map[string]interface{}{
-		"data": enhancedMetrics,
+		"data":  validators.ValidateMetricsForPortal(reports.Result),
+		"tests": reports.Tests,
 	}, http.StatusOK)
 }
 
+// GetReportsTask - получение отчётов всех job задачи (метрики и отчёты о тестах)
+func (route *MasterRunnerRouterPortal) GetReportsTask(writer http.ResponseWriter, request *http.Request) {
+	route.service.WriteReportsTask(request, writer, mux.Vars(request)["taskID"])
+}
+
+// GetWebhookDeliveries - история доставок изменений статусов задачи на webhook
+func (route *MasterRunnerRouterPortal) GetWebhookDeliveries(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetWebhookDeliveries(request, writer, mux.Vars(request)["taskID"])
+}
+
 // healthcheck - статус сервиса для service discovery
 func (route *MasterRunnerRouterPortal) healthCheck(writer http.ResponseWriter, request *http.Request) {
 	// implement logic for return current running works and amount slaves
@@ -214,8 +269,10 @@ func (route *MasterRunnerRouterPortal) healthCheck(writer http.ResponseWriter, r
 }
 
 func (route *MasterRunnerRouterPortal) agentVerification(writer http.ResponseWriter, request *http.Request) {
+	key, _ := api_keys.AuthenticatedKey(request)
 	enhancer.Response(request, writer, map[string]interface{}{
-		"runner_id": route.service.GetAgentID(),
+		"key":    key.Name,
+		"scopes": key.Scopes,
 	}, http.StatusOK)
 }
 
@@ -264,32 +321,81 @@ func (route *MasterRunnerRouterPortal) ChangeJobStatus(writer http.ResponseWrite
 }
 
 func (route *MasterRunnerRouterPortal) getHistoryAndCurrentExecutingTasks(writer http.ResponseWriter, request *http.Request) {
-	enhancer.Response(request, writer, map[string]interface{}{
-		"allTasks": models.ConvertArrayTasks(route.service.GetCore().SlaveMoniring.AllTask),
-	}, http.StatusOK)
+	route.service.GetAllTasks(request, writer)
+}
+
+func (route *MasterRunnerRouterPortal) getTasksHistory(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetTasksHistory(request, writer)
 }
 
 /*ConfigureRouter - конфигурирование маршрутов
  */
 func (route *MasterRunnerRouterPortal) ConfigureRouter() {
-	route.Router.HandleFunc(APITask, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.CreateNewTask))).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiTaskChangeOrGetStatus, route.ChangeTaskStatus).Methods(http.MethodPost)
-	route.Router.HandleFunc(APIStatusTask, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.GetTaskStatus))).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskLogJob, route.CreateLogTask).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiTaskLogJob, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.GetLogTask))).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskLogStage, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.GetLogTask))).Methods(http.MethodGet)
-	route.Router.HandleFunc(ApILogsPerTask, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.GetLogTask))).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskLogAll, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.getAllLogsTree))).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiAvailableWorkers, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.GetStatusWorkers))).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskReport, middlewares.CheckAgentID(route.service.GetAgentID(), http.HandlerFunc(route.GetReportsPerTask))).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTaskReport, route.CreateReportsPerTask).Methods(http.MethodPost)
-	route.Router.HandleFunc(routes.ApiJobChangeOrGetStatus, route.ChangeJobStatus).Methods(http.MethodPost)
+	route.Router.HandleFunc(APITask, route.withScope(api_keys.ScopeSubmit, route.CreateNewTask)).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskChangeOrGetStatus, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.ChangeTaskStatus))).Methods(http.MethodPost)
+	route.Router.HandleFunc(APIStatusTask, route.withScope(api_keys.ScopeStatus, route.GetTaskStatus)).Methods(http.MethodGet)
+	route.Router.HandleFunc(APIStatusTask, route.withScope(api_keys.ScopeSubmit, route.CancelTask)).Methods(http.MethodDelete)
+	route.Router.HandleFunc(routes.ApiTaskLogJob, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.CreateLogTask))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskLogJob, route.withScope(api_keys.ScopeLogs, route.GetLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogJobStream, middlewares.CheckRunnerStreamSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.StreamLogTask))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskLogJobStream, route.withScope(api_keys.ScopeLogs, route.TailLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(ApILogsStream, route.withScope(api_keys.ScopeLogs, route.TailLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogStage, route.withScope(api_keys.ScopeLogs, route.GetLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(ApILogsPerTask, route.withScope(api_keys.ScopeLogs, route.GetLogTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskLogAll, route.withScope(api_keys.ScopeAdmin, route.getAllLogsTree)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskArtefactsJob, middlewares.CheckRunnerStreamSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.CreateArtefacts))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiTaskArtefactsJob, route.withScope(api_keys.ScopeLogs, route.GetArtefacts)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskArtefactFile, route.withScope(api_keys.ScopeLogs, route.GetArtefactFile)).Methods(http.MethodGet)
+	route.Router.HandleFunc(APIArtefacts, route.withScope(api_keys.ScopeLogs, route.GetArtefacts)).Methods(http.MethodGet)
+	route.Router.HandleFunc(APIArtefact, route.withScope(api_keys.ScopeLogs, route.GetArtefactFile)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiAvailableWorkers, route.withScope(api_keys.ScopeAdmin, route.GetStatusWorkers)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskReport, route.withScope(api_keys.ScopeStatus, route.GetReportsPerTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(APIReports, route.withScope(api_keys.ScopeStatus, route.GetReportsTask)).Methods(http.MethodGet)
+	route.Router.HandleFunc(APIWebhooks, route.withScope(api_keys.ScopeStatus, route.GetWebhookDeliveries)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTaskReport, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.CreateReportsPerTask))).Methods(http.MethodPost)
+	route.Router.HandleFunc(routes.ApiJobChangeOrGetStatus, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.ChangeJobStatus))).Methods(http.MethodPost)
 	route.Router.HandleFunc(routes.ApiHealthCheck, route.healthCheck).Methods(http.MethodGet)
-	route.Router.HandleFunc("/", route.agentVerification).Methods(http.MethodGet)
-	route.Router.HandleFunc(routes.ApiTasksView, route.getHistoryAndCurrentExecutingTasks).Methods(http.MethodGet)
+	route.Router.HandleFunc("/", route.withScope("", route.agentVerification)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTasksView, route.withScope(api_keys.ScopeStatus, route.getHistoryAndCurrentExecutingTasks)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiTasksHistory, route.withScope(api_keys.ScopeStatus, route.getTasksHistory)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiSecrets, route.withScope(api_keys.ScopeAdmin, route.GetSecrets)).Methods(http.MethodGet)
+	route.Router.HandleFunc(routes.ApiSecret, route.withScope(api_keys.ScopeAdmin, route.PutSecret)).Methods(http.MethodPut)
+	route.Router.HandleFunc(routes.ApiSecret, route.withScope(api_keys.ScopeAdmin, route.DeleteSecret)).Methods(http.MethodDelete)
+	route.Router.HandleFunc(routes.ApiTaskSource, route.withScope(api_keys.ScopeSubmit, route.UploadSource)).Methods(http.MethodPost, http.MethodPut)
+	route.Router.HandleFunc(routes.ApiTaskSource, middlewares.CheckRunnerSignature(route.service.GetRunnerAuth(), http.HandlerFunc(route.GetSource))).Methods(http.MethodGet)
 	route.Router.NotFoundHandler = http.HandlerFunc(route.notFoundHandler)
 }
 
+// PutSecret - сохранение секрета для job PUT {value}
+func (route *MasterRunnerRouterPortal) PutSecret(writer http.ResponseWriter, request *http.Request) {
+	route.service.PutSecret(request, writer, mux.Vars(request)["name"])
+}
+
+// DeleteSecret - удаление секрета
+func (route *MasterRunnerRouterPortal) DeleteSecret(writer http.ResponseWriter, request *http.Request) {
+	route.service.DeleteSecret(request, writer, mux.Vars(request)["name"])
+}
+
+// GetSecrets - имена секретов без значений
+func (route *MasterRunnerRouterPortal) GetSecrets(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetSecrets(request, writer)
+}
+
+// UploadSource - загрузка архива кода кандидата до создания задачи POST {zip, tar.gz или tar}
+func (route *MasterRunnerRouterPortal) UploadSource(writer http.ResponseWriter, request *http.Request) {
+	route.service.UploadSource(request, writer, mux.Vars(request)["taskID"])
+}
+
+// GetSource - архив кода кандидата для слейва
+func (route *MasterRunnerRouterPortal) GetSource(writer http.ResponseWriter, request *http.Request) {
+	route.service.GetSource(request, writer, mux.Vars(request)["taskID"])
+}
+
+/*withScope - проверка ключа API с правом scope и запись запроса в журнал*/
+func (route *MasterRunnerRouterPortal) withScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return middlewares.CheckAPIKey(route.service.GetAPIKeys(), route.service.GetAuditLog(), scope, handler)
+}
+
 /*GetRouter - получить сконфигурированный роутер*/
 func (route *MasterRunnerRouterPortal) GetRouter() *mux.Router {
 	return route.Router
@@ -0,0 +1,73 @@
+package route_portal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/config"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/services"
+	"github.com/stretchr/testify/assert"
+)
+
+/*newTestRouter - роутер портала с мастером, зарегистрированным в консуле без слейвов*/
+func newTestRouter(t *testing.T, path string) (*MasterRunnerRouterPortal, func()) {
+	consul := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if strings.HasPrefix(request.URL.Path, "/v1/health/service/") {
+			writer.Write([]byte("[]"))
+		}
+	}))
+	service, err := services.InitializeMasterRunnerService(&config.ServiceConfig{
+		ConsulAddress: strings.TrimPrefix(consul.URL, "http://"),
+		RunnerSecret:  "secret",
+	}, &config.ConfigurationMasterRunner{
+		MaxTaskPerSlave:    1,
+		PathToLogsWork:     path + "/logs",
+		PathToReportsWork:  path + "/reports",
+		TaskStoreType:      config.TASKSTOREMEMORY,
+		PathToWebhooksWork: path + "/webhooks",
+		PathToSecrets:      path + "/secrets",
+		PathToSources:      path + "/sources",
+	})
+	if err != nil {
+		consul.Close()
+		t.Fatal(err)
+	}
+	return InitializeMasterRunnerRouter(service), consul.Close
+}
+
+func Test_GetReportsPerTaskMultiMatch(t *testing.T) {
+	path, err := ioutil.TempDir("", "portal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	route, closeConsul := newTestRouter(t, path)
+	defer closeConsul()
+
+	// метрика без aggregate совпала в логах job несколько раз
+	reports, _ := json.Marshal(map[string]models.MetricReport{
+		"coverage": {Matches: []map[string]string{{"coverage": "60.0"}, {"coverage": "75.5"}}, Field: "coverage"},
+		"total":    {Matches: []map[string]string{{"count": "1"}, {"count": "2"}}, Aggregate: models.AggregateCount, Field: "count", Value: "2"},
+	})
+	os.MkdirAll(path+"/reports/task", 0700)
+	if err := ioutil.WriteFile(path+"/reports/task/test.json", reports, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/task/task/reports/test", nil), map[string]string{"taskID": "task", "job": "test"})
+	recorder := httptest.NewRecorder()
+	route.GetReportsPerTask(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response struct {
+		Data map[string]string `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, map[string]string{"coverage": "75.5", "total": "2"}, response.Data)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/kubitre/diplom/core"
+	"github.com/kubitre/diplom/middlewares"
 	"github.com/kubitre/diplom/models"
 	log "github.com/sirupsen/logrus"
 )
@@ -33,12 +34,25 @@ func (route *SlaveRunnerRouter) createNewTask(writer http.ResponseWriter, reques
 		return
 	}
 	log.Println("start executing new task: ", model)
-	route.Core.WorkerPull <- model
+	route.Core.AddTask(model)
 	log.Println("completed prepared for task: ", model.TaskID)
 	writer.WriteHeader(http.StatusOK)
 	writer.Write([]byte("completed saved and start preparing task for working with that"))
 }
 
+// cancelTask - отмена задачи, которая ожидает выполнения или выполняется на слейве
+func (route *SlaveRunnerRouter) cancelTask(writer http.ResponseWriter, request *http.Request) {
+	taskID := mux.Vars(request)["taskID"]
+	if err := route.Core.CancelTask(taskID); err != nil {
+		log.Println("can not cancel task: ", err)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+	writer.Write([]byte("task was canceled"))
+}
+
 func (route *SlaveRunnerRouter) healthCheck(writer http.ResponseWriter, request *http.Request) {
 	writer.WriteHeader(http.StatusOK)
 	writer.Write([]byte("service are running"))
@@ -47,7 +61,8 @@ func (route *SlaveRunnerRouter) healthCheck(writer http.ResponseWriter, request
 // ConfigureRouter - конфигурирование роутера
 func (route *SlaveRunnerRouter) ConfigureRouter() {
 	log.Println("start configuring routes")
-	route.Router.HandleFunc(ApiTask, route.createNewTask).Methods(http.MethodPost)
+	route.Router.HandleFunc(ApiTask, middlewares.CheckRunnerSignature(route.Core.Auth, http.HandlerFunc(route.createNewTask))).Methods(http.MethodPost)
+	route.Router.HandleFunc(ApiTaskCancel, middlewares.CheckRunnerSignature(route.Core.Auth, http.HandlerFunc(route.cancelTask))).Methods(http.MethodDelete)
 	route.Router.HandleFunc(ApiHealthCheck, route.healthCheck).Methods(http.MethodGet)
 	log.Println("completed configuring routes")
 }
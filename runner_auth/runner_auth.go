@@ -0,0 +1,186 @@
+package runner_auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RunnerTimestampHeader - время подписи запроса (unix, секунды)
+	RunnerTimestampHeader = "X-Runner-Timestamp"
+	// RunnerNonceHeader - случайное значение, по которому отклоняются повторно отправленные запросы
+	RunnerNonceHeader = "X-Runner-Nonce"
+	// RunnerContentHashHeader - sha256 тела запроса (hex) или UnsignedPayload для потоковых запросов
+	RunnerContentHashHeader = "X-Runner-Content-SHA256"
+	// RunnerAuthHeader - HMAC-SHA256 подпись запроса общим ключом мастера и слейвов
+	RunnerAuthHeader = "X-Runner-Auth"
+	// UnsignedPayload - тело запроса передаётся потоком (логи, артефакты) и не входит в подпись. Принимается только VerifyStream
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// MaxSignedBodySize - наибольшее тело запроса, хеш которого проверяется при проверке подписи
+	MaxSignedBodySize = 64 << 20
+
+	// RunnerSignatureTTL - допустимое расхождение времени подписи и времени проверки запроса
+	RunnerSignatureTTL = 5 * time.Minute
+)
+
+/*RunnerAuth - подпись и проверка запросов между мастером и слейвами общим ключом RUNNER_SECRET. Без ключа (RUNNER_AUTH_DISABLED=true) подпись и проверка отключены*/
+type RunnerAuth struct {
+	secret []byte
+
+	noncesMutex sync.Mutex
+	nonces      map[string]time.Time // использованные nonce и время, после которого их можно забыть
+}
+
+// ErrEmptySecret - сервис не запускается без общего ключа, если проверка запросов не отключена явно
+var ErrEmptySecret = errors.New("RUNNER_SECRET is empty: set it or disable authentication of runner requests with RUNNER_AUTH_DISABLED=true")
+
+/*NewRunnerAuth - инициализация подписи запросов по общему ключу. disabled отключает подпись и проверку запросов*/
+func NewRunnerAuth(secret string, disabled bool) (*RunnerAuth, error) {
+	if disabled {
+		log.Warn("RUNNER_AUTH_DISABLED is set: requests between master and slaves are not authenticated")
+		secret = ""
+	} else if secret == "" {
+		return nil, ErrEmptySecret
+	}
+	return &RunnerAuth{
+		secret: []byte(secret),
+		nonces: map[string]time.Time{},
+	}, nil
+}
+
+/*Enabled - задан ли общий ключ*/
+func (auth *RunnerAuth) Enabled() bool {
+	return auth != nil && len(auth.secret) > 0
+}
+
+/*Sign - подпись запроса с телом body (nil - запрос без тела)*/
+func (auth *RunnerAuth) Sign(request *http.Request, body []byte) {
+	hash := sha256.Sum256(body)
+	auth.sign(request, hex.EncodeToString(hash[:]))
+}
+
+/*SignStream - подпись запроса, тело которого передаётся потоком и не входит в подпись*/
+func (auth *RunnerAuth) SignStream(request *http.Request) {
+	auth.sign(request, UnsignedPayload)
+}
+
+func (auth *RunnerAuth) sign(request *http.Request, contentHash string) {
+	if !auth.Enabled() {
+		return
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		log.Error("can not generate request nonce: ", err)
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(RunnerTimestampHeader, timestamp)
+	request.Header.Set(RunnerNonceHeader, hex.EncodeToString(nonce))
+	request.Header.Set(RunnerContentHashHeader, contentHash)
+	request.Header.Set(RunnerAuthHeader, auth.signature(request))
+}
+
+/*Verify - проверка подписи запроса от мастера или слейва вместе с хешем его тела. Nil - подпись отключена или верна*/
+func (auth *RunnerAuth) Verify(request *http.Request) error {
+	if !auth.Enabled() {
+		return nil
+	}
+	return auth.verify(request, false)
+}
+
+/*VerifyStream - проверка подписи потокового запроса (логи, артефакты), тело которого может не входить в подпись*/
+func (auth *RunnerAuth) VerifyStream(request *http.Request) error {
+	if !auth.Enabled() {
+		return nil
+	}
+	return auth.verify(request, true)
+}
+
+func (auth *RunnerAuth) verify(request *http.Request, allowUnsigned bool) error {
+	timestamp, err := strconv.ParseInt(request.Header.Get(RunnerTimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("invalid request timestamp")
+	}
+	signedAt := time.Unix(timestamp, 0)
+	if time.Since(signedAt) > RunnerSignatureTTL || time.Until(signedAt) > RunnerSignatureTTL {
+		return errors.New("request signature expired")
+	}
+	if request.Header.Get(RunnerNonceHeader) == "" {
+		return errors.New("request without nonce")
+	}
+	expected := auth.signature(request)
+	if !hmac.Equal([]byte(expected), []byte(request.Header.Get(RunnerAuthHeader))) {
+		return errors.New("invalid request signature")
+	}
+	switch contentHash := request.Header.Get(RunnerContentHashHeader); {
+	case contentHash != UnsignedPayload:
+		if err := checkBody(request, contentHash); err != nil {
+			return err
+		}
+	case !allowUnsigned:
+		return errors.New("unsigned request body is not allowed for " + request.URL.Path)
+	}
+	return auth.useNonce(request.Header.Get(RunnerNonceHeader), signedAt)
+}
+
+/*signature - подпись метода, пути с параметрами, времени, nonce и хеша тела запроса*/
+func (auth *RunnerAuth) signature(request *http.Request) string {
+	mac := hmac.New(sha256.New, auth.secret)
+	mac.Write([]byte(request.Method + "\n" +
+		request.URL.RequestURI() + "\n" +
+		request.Header.Get(RunnerTimestampHeader) + "\n" +
+		request.Header.Get(RunnerNonceHeader) + "\n" +
+		request.Header.Get(RunnerContentHashHeader)))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*checkBody - сравнение хеша тела с подписанным. Тело больше MaxSignedBodySize не читается. Прочитанное тело возвращается в запрос*/
+func checkBody(request *http.Request, contentHash string) error {
+	body := []byte{}
+	if request.Body != nil {
+		read, err := ioutil.ReadAll(io.LimitReader(request.Body, MaxSignedBodySize+1))
+		request.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(read) > MaxSignedBodySize {
+			return errors.New("request body is larger than " + strconv.Itoa(MaxSignedBodySize) + " bytes")
+		}
+		body = read
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	hash := sha256.Sum256(body)
+	if !hmac.Equal([]byte(hex.EncodeToString(hash[:])), []byte(contentHash)) {
+		return errors.New("request body does not match signature")
+	}
+	return nil
+}
+
+/*useNonce - запоминание nonce до истечения срока подписи. Повторный запрос с тем же nonce отклоняется*/
+func (auth *RunnerAuth) useNonce(nonce string, signedAt time.Time) error {
+	auth.noncesMutex.Lock()
+	defer auth.noncesMutex.Unlock()
+	now := time.Now()
+	for used, expire := range auth.nonces {
+		if now.After(expire) {
+			delete(auth.nonces, used)
+		}
+	}
+	if _, exist := auth.nonces[nonce]; exist {
+		return errors.New("request was already handled")
+	}
+	auth.nonces[nonce] = signedAt.Add(RunnerSignatureTTL)
+	return nil
+}
@@ -0,0 +1,100 @@
+package runner_auth
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*receive - запрос в том виде, в котором его получит сервер*/
+func receive(request *http.Request, body []byte) *http.Request {
+	received := httptest.NewRequest(request.Method, request.URL.RequestURI(), bytes.NewReader(body))
+	received.Header = request.Header.Clone()
+	return received
+}
+
+func newTestAuth(t *testing.T, secret string) *RunnerAuth {
+	auth, err := NewRunnerAuth(secret, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func Test_RunnerAuthSignedRequest(t *testing.T) {
+	slave, master := newTestAuth(t, "secret"), newTestAuth(t, "secret")
+	body := []byte(`{"taskID":"task","status":2}`)
+	request, _ := http.NewRequest(http.MethodPost, "http://master/task/task/status", bytes.NewReader(body))
+	slave.Sign(request, body)
+
+	received := receive(request, body)
+	assert.Nil(t, master.Verify(received))
+	read, _ := ioutil.ReadAll(received.Body)
+	assert.Equal(t, body, read)
+
+	// повторная отправка того же запроса
+	assert.NotNil(t, master.Verify(receive(request, body)))
+	// подмена тела
+	slave.Sign(request, body)
+	assert.NotNil(t, master.Verify(receive(request, []byte(`{"taskID":"task","status":3}`))))
+	// другой ключ
+	slave.Sign(request, body)
+	assert.NotNil(t, newTestAuth(t, "other").Verify(receive(request, body)))
+	// другая задача
+	slave.Sign(request, body)
+	forged := receive(request, body)
+	forged.URL.Path = "/task/other/status"
+	assert.NotNil(t, master.Verify(forged))
+}
+
+func Test_RunnerAuthStreamAndExpiration(t *testing.T) {
+	auth := newTestAuth(t, "secret")
+	request, _ := http.NewRequest(http.MethodPost, "http://master/task/task/artefacts/build/compile?path=/out", strings.NewReader("archive"))
+	auth.SignStream(request)
+	assert.Equal(t, UnsignedPayload, request.Header.Get(RunnerContentHashHeader))
+	// тело без хеша принимается только на потоковых маршрутах
+	assert.NotNil(t, auth.Verify(receive(request, []byte("archive"))))
+	auth.SignStream(request)
+	assert.Nil(t, auth.VerifyStream(receive(request, []byte("archive"))))
+
+	request.Header.Set(RunnerTimestampHeader, strconv.FormatInt(time.Now().Add(-2*RunnerSignatureTTL).Unix(), 10))
+	request.Header.Set(RunnerAuthHeader, auth.signature(request))
+	assert.NotNil(t, auth.VerifyStream(receive(request, []byte("archive"))))
+
+	unsigned, _ := http.NewRequest(http.MethodDelete, "http://slave/task/task", nil)
+	assert.NotNil(t, auth.Verify(receive(unsigned, nil)))
+}
+
+func Test_RunnerAuthBodyLimit(t *testing.T) {
+	auth := newTestAuth(t, "secret")
+	body := bytes.Repeat([]byte("a"), MaxSignedBodySize+1)
+	request, _ := http.NewRequest(http.MethodPost, "http://master/task/task/report", bytes.NewReader(body))
+	auth.Sign(request, body)
+	err := auth.Verify(receive(request, body))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "larger than")
+}
+
+func Test_RunnerAuthDisabled(t *testing.T) {
+	// пустой ключ без явного отключения проверки не принимается
+	_, err := NewRunnerAuth("", false)
+	assert.Equal(t, ErrEmptySecret, err)
+
+	disabled, err := NewRunnerAuth("secret", true)
+	assert.Nil(t, err)
+	assert.False(t, disabled.Enabled())
+	request, _ := http.NewRequest(http.MethodDelete, "http://slave/task/task", nil)
+	var missing *RunnerAuth
+	missing.Sign(request, nil)
+	disabled.Sign(request, nil)
+	assert.Equal(t, "", request.Header.Get(RunnerAuthHeader))
+	assert.Nil(t, missing.Verify(request))
+	assert.Nil(t, disabled.Verify(request))
+}
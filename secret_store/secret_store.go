@@ -0,0 +1,180 @@
+package secret_store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubitre/diplom/models"
+)
+
+const secretSuffix = ".secret"
+
+// ErrDisabled - SECRETS_KEY не задан, секреты не могут быть сохранены или прочитаны
+var ErrDisabled = errors.New("secret store is disabled: SECRETS_KEY is empty")
+
+/*SecretStore - секреты мастера. Каждый секрет хранится в отдельном файле, зашифрованным AES-256-GCM ключом SECRETS_KEY*/
+type SecretStore struct {
+	path  string
+	aead  cipher.AEAD // nil - ключ не задан
+	mutex sync.Mutex
+}
+
+/*NewSecretStore - хранилище секретов в path. key - 32 байта в base64. Пустой ключ отключает хранилище*/
+func NewSecretStore(path, key string) (*SecretStore, error) {
+	store := &SecretStore{path: path}
+	if key == "" {
+		return store, nil
+	}
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.New("SECRETS_KEY must be base64: " + err.Error())
+	}
+	if len(rawKey) != 32 {
+		return nil, errors.New("SECRETS_KEY must contain 32 bytes")
+	}
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return nil, err
+	}
+	if store.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+/*Enabled - задан ли ключ шифрования секретов*/
+func (store *SecretStore) Enabled() bool {
+	return store != nil && store.aead != nil
+}
+
+/*Put - сохранение или замена секрета*/
+func (store *SecretStore) Put(name, value string) error {
+	if !store.Enabled() {
+		return ErrDisabled
+	}
+	if !models.ValidSecretName(name) {
+		return errors.New("invalid secret name: " + name)
+	}
+	nonce := make([]byte, store.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	// имя секрета входит в проверку целостности: файл одного секрета нельзя выдать за другой
+	sealed := store.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	temporary, err := ioutil.TempFile(store.path, name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(sealed); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), store.fileName(name))
+}
+
+/*Delete - удаление секрета*/
+func (store *SecretStore) Delete(name string) error {
+	if !models.ValidSecretName(name) {
+		return errors.New("invalid secret name: " + name)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	err := os.Remove(store.fileName(name))
+	if os.IsNotExist(err) {
+		return errors.New("secret not found: " + name)
+	}
+	return err
+}
+
+/*Names - имена сохранённых секретов. Значения секретов через API не возвращаются*/
+func (store *SecretStore) Names() ([]string, error) {
+	files, err := ioutil.ReadDir(store.path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, file := range files {
+		if name := strings.TrimSuffix(file.Name(), secretSuffix); name != file.Name() && models.ValidSecretName(name) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+/*Missing - секреты из names, которых нет в хранилище*/
+func (store *SecretStore) Missing(names []string) []string {
+	result := []string{}
+	for _, name := range names {
+		if _, err := os.Stat(store.fileName(name)); !models.ValidSecretName(name) || err != nil {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+/*Resolve - расшифровка секретов задачи перед её отправкой на слейв*/
+func (store *SecretStore) Resolve(names []string) (models.SecretValues, error) {
+	result := models.SecretValues{}
+	if len(names) == 0 {
+		return result, nil
+	}
+	if !store.Enabled() {
+		return nil, ErrDisabled
+	}
+	for _, name := range names {
+		value, err := store.get(name)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+func (store *SecretStore) get(name string) (string, error) {
+	if !models.ValidSecretName(name) {
+		return "", errors.New("invalid secret name: " + name)
+	}
+	sealed, err := ioutil.ReadFile(store.fileName(name))
+	if os.IsNotExist(err) {
+		return "", errors.New("secret not found: " + name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < store.aead.NonceSize() {
+		return "", errors.New("secret is corrupted: " + name)
+	}
+	nonce, ciphertext := sealed[:store.aead.NonceSize()], sealed[store.aead.NonceSize():]
+	value, err := store.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", errors.New("can not decrypt secret " + name + ": " + err.Error())
+	}
+	return string(value), nil
+}
+
+func (store *SecretStore) fileName(name string) string {
+	return store.path + "/" + name + secretSuffix
+}
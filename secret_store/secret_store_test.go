@@ -0,0 +1,78 @@
+package secret_store
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey(fill byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32)))
+}
+
+func Test_SecretStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewSecretStore(dir, testKey('k'))
+	assert.Nil(t, err)
+	assert.True(t, store.Enabled())
+	assert.Nil(t, store.Put("TOKEN", "token_value"))
+	assert.Nil(t, store.Put("DEPLOY_KEY", "line1\nline2"))
+	assert.Nil(t, store.Put("TOKEN", "rotated_value"))
+	assert.NotNil(t, store.Put("bad-name", "value"))
+
+	names, err := store.Names()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"DEPLOY_KEY", "TOKEN"}, names)
+	assert.Equal(t, []string{"OTHER"}, store.Missing([]string{"TOKEN", "OTHER"}))
+
+	values, err := store.Resolve([]string{"TOKEN", "DEPLOY_KEY"})
+	assert.Nil(t, err)
+	assert.Equal(t, "rotated_value", values["TOKEN"])
+	assert.Equal(t, "line1\nline2", values["DEPLOY_KEY"])
+
+	// значение не хранится в открытом виде
+	content, err := ioutil.ReadFile(dir + "/TOKEN" + secretSuffix)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "rotated_value")
+
+	// файл одного секрета нельзя выдать за другой
+	assert.Nil(t, ioutil.WriteFile(dir+"/COPY"+secretSuffix, content, 0600))
+	_, err = store.Resolve([]string{"COPY"})
+	assert.NotNil(t, err)
+
+	other, err := NewSecretStore(dir, testKey('o'))
+	assert.Nil(t, err)
+	_, err = other.Resolve([]string{"TOKEN"})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, store.Delete("TOKEN"))
+	assert.NotNil(t, store.Delete("TOKEN"))
+	_, err = store.Resolve([]string{"TOKEN"})
+	assert.NotNil(t, err)
+}
+
+func Test_SecretStoreDisabled(t *testing.T) {
+	store, err := NewSecretStore("", "")
+	assert.Nil(t, err)
+	assert.False(t, store.Enabled())
+	assert.Equal(t, ErrDisabled, store.Put("TOKEN", "value"))
+	values, err := store.Resolve(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+	_, err = store.Resolve([]string{"TOKEN"})
+	assert.Equal(t, ErrDisabled, err)
+
+	_, err = NewSecretStore("", "not base64")
+	assert.NotNil(t, err)
+	_, err = NewSecretStore("", base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+/*artefactsRetentionInterval - период проверки устаревших артефактов*/
+const artefactsRetentionInterval = time.Hour
+
+var errArtefactNotFound = errors.New("artefact not found")
+
+func (service *MasterRunnerService) artefactsPath(taskID, stage, job string) string {
+	result := service.masterConfig.PathToArtefactsWork + "/" + taskID
+	if stage != "" {
+		result += "/" + stage
+	}
+	if job != "" {
+		result += "/" + job
+	}
+	return result
+}
+
+// CreateArtefacts - закрытый метод разрешённый только для воркеров. Сохранение артефактов job из tar архива (в формате docker cp)
+func (service *MasterRunnerService) CreateArtefacts(request *http.Request, writer http.ResponseWriter) {
+	vars := mux.Vars(request)
+	log.Println("saving artefact: ", request.URL.Query().Get("path"), " of job: ", vars["job"])
+	limit := service.masterConfig.MaxArtefactsSize * 1024 * 1024
+	saved, err := enhancer.ExtractArchive(request.Body, service.artefactsPath(vars["taskID"], vars["stage"], vars["job"]), limit)
+	if err != nil {
+		log.Println("can not save artefacts: ", err)
+		code := http.StatusBadRequest
+		if err == enhancer.ErrArchiveTooLarge {
+			code = http.StatusRequestEntityTooLarge
+		}
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "createArtefacts",
+			},
+			"detailed": map[string]string{
+				"message": "can't save artefacts",
+				"trace":   err.Error(),
+			},
+		}, code)
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "artefacts saved",
+		"files":  saved,
+	}, http.StatusOK)
+}
+
+// GetArtefacts - список артефактов задачи (или её этапа и job)
+func (service *MasterRunnerService) GetArtefacts(request *http.Request, writer http.ResponseWriter, taskID, stage, job string) {
+	artefacts, err := service.listArtefacts(taskID, stage, job)
+	if err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "getArtefacts",
+			},
+			"detailed": map[string]string{
+				"message": "can not list artefacts",
+				"trace":   err.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"artefacts": artefacts,
+	}, http.StatusOK)
+}
+
+// GetArtefactFile - скачивание файла артефакта job
+func (service *MasterRunnerService) GetArtefactFile(request *http.Request, writer http.ResponseWriter, taskID, stage, job, filePath string) {
+	name, err := enhancer.CleanArchivePath(filePath)
+	if err == nil {
+		name = service.artefactsPath(taskID, stage, job) + "/" + name
+		if info, errStat := os.Stat(name); errStat != nil || !info.Mode().IsRegular() {
+			err = errArtefactNotFound
+		}
+	}
+	if err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "getArtefactFile",
+			},
+			"detailed": map[string]string{
+				"message": "can not get artefact",
+				"trace":   err.Error(),
+			},
+		}, http.StatusNotFound)
+		return
+	}
+	writer.Header().Set("Content-Disposition", "attachment; filename=\""+filepath.Base(name)+"\"")
+	http.ServeFile(writer, request, name)
+}
+
+func (service *MasterRunnerService) listArtefacts(taskID, stage, job string) ([]models.Artefact, error) {
+	taskPath := service.artefactsPath(taskID, "", "")
+	result := []models.Artefact{}
+	err := filepath.Walk(service.artefactsPath(taskID, stage, job), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relative, errRelative := filepath.Rel(taskPath, filePath)
+		if errRelative != nil {
+			return errRelative
+		}
+		// путь относительно директории задачи: {stage}/{job}/{путь артефакта}
+		parts := strings.SplitN(filepath.ToSlash(relative), "/", 3)
+		if len(parts) < 3 {
+			return nil
+		}
+		result = append(result, models.Artefact{
+			Stage:        parts[0],
+			Job:          parts[1],
+			Path:         parts[2],
+			Size:         info.Size(),
+			TimeModified: info.ModTime().Unix(),
+		})
+		return nil
+	})
+	return result, err
+}
+
+/*runArtefactsRetention - периодическое удаление артефактов, которые хранятся дольше ArtefactsRetention*/
+func (service *MasterRunnerService) runArtefactsRetention() {
+	if service.masterConfig.ArtefactsRetention <= 0 {
+		return
+	}
+	for {
+		service.removeExpiredArtefacts(time.Now())
+		time.Sleep(artefactsRetentionInterval)
+	}
+}
+
+/*removeExpiredArtefacts - удаление устаревших файлов артефактов и оставшихся пустыми директорий*/
+func (service *MasterRunnerService) removeExpiredArtefacts(now time.Time) {
+	root := service.masterConfig.PathToArtefactsWork
+	expired := now.Add(-time.Duration(service.masterConfig.ArtefactsRetention) * time.Hour)
+	directories := []string{}
+	filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if filePath != root {
+				directories = append(directories, filePath)
+			}
+			return nil
+		}
+		if info.ModTime().Before(expired) {
+			log.Println("remove expired artefact: ", filePath)
+			if errRemove := os.Remove(filePath); errRemove != nil {
+				log.Println("can not remove artefact: ", errRemove)
+			}
+		}
+		return nil
+	})
+	// вложенные директории идут после родительских, поэтому удаляются в обратном порядке
+	for index := len(directories) - 1; index >= 0; index-- {
+		os.Remove(directories[index])
+	}
+}
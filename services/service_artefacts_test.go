@@ -0,0 +1,121 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func artefactArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	buffer := new(bytes.Buffer)
+	writer := tar.NewWriter(buffer)
+	for name, content := range files {
+		if err := writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(content))
+	}
+	writer.Close()
+	return buffer
+}
+
+func uploadArtefacts(service *MasterRunnerService, archive *bytes.Buffer) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/task/task/artefacts/lint/check?path=/report.xml", archive)
+	request = mux.SetURLVars(request, map[string]string{"taskID": "task", "stage": "lint", "job": "check"})
+	recorder := httptest.NewRecorder()
+	service.CreateArtefacts(request, recorder)
+	return recorder
+}
+
+func Test_Artefacts(t *testing.T) {
+	path, err := ioutil.TempDir("", "artefacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, path)
+	service.masterConfig.PathToArtefactsWork = path
+	service.masterConfig.MaxArtefactsSize = 1
+
+	recorder := uploadArtefacts(service, artefactArchive(t, map[string]string{
+		"report.xml":       "<testsuites/>",
+		"../../escape.txt": "escape",
+	}))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	_, errEscape := os.Stat(path + "/task/escape.txt")
+	assert.True(t, os.IsNotExist(errEscape))
+
+	recorder = httptest.NewRecorder()
+	service.GetArtefacts(httptest.NewRequest(http.MethodGet, "/task/task/artefacts", nil), recorder, "task", "", "")
+	var list map[string][]models.Artefact
+	json.NewDecoder(recorder.Body).Decode(&list)
+	assert.Equal(t, 2, len(list["artefacts"]))
+	assert.Equal(t, "lint", list["artefacts"][0].Stage)
+	assert.Equal(t, "check", list["artefacts"][0].Job)
+
+	recorder = httptest.NewRecorder()
+	service.GetArtefactFile(httptest.NewRequest(http.MethodGet, "/task/task/artefacts/lint/check/file", nil), recorder, "task", "lint", "check", "/report.xml")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "<testsuites/>", recorder.Body.String())
+	recorder = httptest.NewRecorder()
+	service.GetArtefactFile(httptest.NewRequest(http.MethodGet, "/task/task/artefacts/lint/check/file", nil), recorder, "task", "lint", "check", "missing.xml")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// архив больше ARTEFACTS_MAX_SIZE_MB не сохраняется
+	recorder = uploadArtefacts(service, artefactArchive(t, map[string]string{
+		"big.bin": string(make([]byte, 2*1024*1024)),
+	}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	_, errBig := os.Stat(path + "/task/lint/check/big.bin")
+	assert.True(t, os.IsNotExist(errBig))
+}
+
+func Test_ArtefactsRetention(t *testing.T) {
+	path, err := ioutil.TempDir("", "artefacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, path)
+	service.masterConfig.PathToArtefactsWork = path
+	service.masterConfig.ArtefactsRetention = 24
+	assert.Equal(t, http.StatusOK, uploadArtefacts(service, artefactArchive(t, map[string]string{"report.xml": "report"})).Code)
+
+	service.removeExpiredArtefacts(time.Now().Add(time.Hour))
+	_, errStat := os.Stat(path + "/task/lint/check/report.xml")
+	assert.Nil(t, errStat)
+
+	service.removeExpiredArtefacts(time.Now().Add(25 * time.Hour))
+	_, errStat = os.Stat(path + "/task")
+	assert.True(t, os.IsNotExist(errStat))
+	_, errStat = os.Stat(path)
+	assert.Nil(t, errStat)
+}
+
+func Test_NewTaskValidatesNeeds(t *testing.T) {
+	service := newTestService(t, "")
+	create := func(task models.TaskConfig) int {
+		recorder := httptest.NewRecorder()
+		service.NewTask(&task, httptest.NewRequest(http.MethodPost, "/task", nil), recorder)
+		return recorder.Code
+	}
+	jobs := map[string]models.Job{
+		"build": {Stage: "build", Artefacts: []string{"/service"}},
+		"test":  {Stage: "test", Needs: []string{"build"}},
+	}
+	assert.Equal(t, http.StatusBadRequest, create(models.TaskConfig{TaskID: "reversed", Stages: []string{"test", "build"}, Jobs: jobs}))
+	jobs["lint"] = models.Job{Stage: "build", Needs: []string{"unknown"}}
+	assert.Equal(t, http.StatusBadRequest, create(models.TaskConfig{TaskID: "unknown", Stages: []string{"build", "test"}, Jobs: jobs}))
+	delete(jobs, "lint")
+	assert.Equal(t, http.StatusOK, create(models.TaskConfig{TaskID: "task", Stages: []string{"build", "test"}, Jobs: jobs}))
+}
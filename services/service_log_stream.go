@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+/*logStreamPollInterval - период проверки новых строк лога при трансляции через SSE*/
+const logStreamPollInterval = 500 * time.Millisecond
+
+type (
+	/*logTail - чтение дописываемого файла потока логов с сохранением позиции между чтениями*/
+	logTail struct {
+		path    string
+		file    *os.File
+		reader  *bufio.Reader
+		partial []byte // начало строки, которая ещё не дописана
+	}
+)
+
+func (service *MasterRunnerService) logStreamPath(taskID, stage string) string {
+	return service.masterConfig.PathToLogsWork + "/" + taskID + "/" + stage
+}
+
+// CreateLogStream - закрытый метод разрешённый только для воркеров. Приём строк лога выполняющейся job (по строке JSON models.LogLine) и дописывание их в структурированный лог job
+func (service *MasterRunnerService) CreateLogStream(request *http.Request, writer http.ResponseWriter) {
+	vars := mux.Vars(request)
+	logPath := service.logStreamPath(vars["taskID"], vars["stage"])
+	if errDirCreating := os.MkdirAll(logPath, os.ModePerm); errDirCreating != nil {
+		log.Println("can not be creating dir for log stream: ", errDirCreating)
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "createLogStream",
+			},
+			"detailed": map[string]string{
+				"message": "can't create work id path",
+				"trace":   errDirCreating.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	fileName := logPath + "/" + vars["job"] + enhancer.StructuredLogExt
+	offset, errCount := countLines(fileName)
+	if errCount != nil {
+		log.Println("can not read log stream: ", errCount)
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "createLogStream",
+			},
+			"detailed": map[string]string{
+				"message": "can't read log stream",
+				"trace":   errCount.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("can not open log stream: ", err)
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "createLogStream",
+			},
+			"detailed": map[string]string{
+				"message": "can't open log stream",
+				"trace":   err.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(request.Body)
+	// строки пишутся в файл сразу, чтобы читатели SSE получали их во время выполнения job
+	encoder := json.NewEncoder(file)
+	for {
+		var line models.LogLine
+		if errDecode := decoder.Decode(&line); errDecode != nil {
+			if errDecode == io.EOF {
+				break
+			}
+			log.Println("log stream was interrupted: ", errDecode)
+			enhancer.Response(request, writer, map[string]interface{}{
+				"context": map[string]string{
+					"module":  "master_executor",
+					"package": "routers",
+					"func":    "createLogStream",
+				},
+				"detailed": map[string]string{
+					"message": "can't parse log line",
+					"trace":   errDecode.Error(),
+				},
+			}, http.StatusBadRequest)
+			return
+		}
+		line.Offset = offset
+		if errWrite := encoder.Encode(&line); errWrite != nil {
+			log.Println("can not write log stream: ", errWrite)
+			enhancer.Response(request, writer, map[string]interface{}{
+				"context": map[string]string{
+					"module":  "master_executor",
+					"package": "routers",
+					"func":    "createLogStream",
+				},
+				"detailed": map[string]string{
+					"message": "can't write log line",
+					"trace":   errWrite.Error(),
+				},
+			}, http.StatusConflict)
+			return
+		}
+		offset++
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "completed log stream",
+		"lines":  offset,
+	}, http.StatusOK)
+}
+
+// TailLogStream - трансляция лога job через Server-Sent Events до завершения job. Продолжение с позиции: заголовок Last-Event-ID или параметр offset
+func (service *MasterRunnerService) TailLogStream(request *http.Request, writer http.ResponseWriter, taskID, stage, job string) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "tailLogStream",
+			},
+			"detailed": map[string]string{
+				"message": "streaming is not supported",
+			},
+		}, http.StatusInternalServerError)
+		return
+	}
+	offset, errOffset := startOffset(request)
+	if errOffset != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "tailLogStream",
+			},
+			"detailed": map[string]string{
+				"message": "invalid offset",
+				"trace":   errOffset.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	tail := &logTail{path: service.logStreamPath(taskID, stage) + "/" + job + enhancer.StructuredLogExt}
+	defer tail.close()
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	ticker := time.NewTicker(logStreamPollInterval)
+	defer ticker.Stop()
+	for {
+		// статус проверяется до чтения: всё, что записано до завершения job, будет прочитано в этом же цикле
+		finished := service.jobFinished(taskID, job)
+		lines, errRead := tail.read()
+		if errRead != nil {
+			log.Println("can not read log stream: ", errRead)
+			return
+		}
+		for _, data := range lines {
+			var line models.LogLine
+			if errDecode := json.Unmarshal(data, &line); errDecode != nil || line.Offset < offset {
+				continue
+			}
+			fmt.Fprintf(writer, "id: %d\nevent: log\ndata: %s\n\n", line.Offset, data)
+		}
+		if finished {
+			fmt.Fprint(writer, "event: end\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*jobFinished - job (или вся задача) завершена и новых строк лога не будет. Неизвестная задача считается завершённой*/
+func (service *MasterRunnerService) jobFinished(taskID, job string) bool {
+	task, err := service.masterCore.SlaveMoniring.GetTaskStatus(taskID)
+	if err != nil {
+		return true
+	}
+	if task.StatusTask.IsFinal() {
+		return true
+	}
+	for _, jobStatus := range task.StatusJobs {
+		if jobStatus.Job == job {
+			return jobStatus.StatusIndex.IsFinal()
+		}
+	}
+	return false
+}
+
+/*startOffset - первая строка, которую нужно отправить клиенту*/
+func startOffset(request *http.Request) (int64, error) {
+	if lastEventID := request.Header.Get("Last-Event-ID"); lastEventID != "" {
+		offset, err := strconv.ParseInt(lastEventID, 10, 64)
+		return offset + 1, err
+	}
+	if offset := request.URL.Query().Get("offset"); offset != "" {
+		return strconv.ParseInt(offset, 10, 64)
+	}
+	return 0, nil
+}
+
+func countLines(fileName string) (int64, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+	var count int64
+	buffer := make([]byte, 32*1024)
+	for {
+		readed, errRead := file.Read(buffer)
+		count += int64(bytes.Count(buffer[:readed], []byte{'\n'}))
+		if errRead == io.EOF {
+			return count, nil
+		}
+		if errRead != nil {
+			return count, errRead
+		}
+	}
+}
+
+/*read - новые полностью записанные строки. Файл может ещё не существовать, если job не начала выполняться*/
+func (tail *logTail) read() ([][]byte, error) {
+	if tail.file == nil {
+		file, err := os.Open(tail.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		tail.file = file
+		tail.reader = bufio.NewReader(file)
+	}
+	lines := [][]byte{}
+	for {
+		data, err := tail.reader.ReadBytes('\n')
+		tail.partial = append(tail.partial, data...)
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, bytes.TrimSuffix(tail.partial, []byte{'\n'}))
+		tail.partial = nil
+	}
+}
+
+func (tail *logTail) close() {
+	if tail.file != nil {
+		tail.file.Close()
+	}
+}
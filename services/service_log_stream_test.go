@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/config"
+	"github.com/kubitre/diplom/core"
+	"github.com/kubitre/diplom/models"
+	"github.com/kubitre/diplom/monitor"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestService(t *testing.T, logsPath string) *MasterRunnerService {
+	monitoring, err := monitor.InitializeNewSlaveMonitoring(10, monitor.NewMemoryTaskRepository(), monitor.NewRoundRobinScheduler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MasterRunnerService{
+		masterCore:   &core.MasterRunnerCore{SlaveMoniring: monitoring},
+		masterConfig: &config.ConfigurationMasterRunner{PathToLogsWork: logsPath},
+	}
+}
+
+func streamLines(service *MasterRunnerService, body string) int {
+	request := httptest.NewRequest(http.MethodPost, "/task/task/log/build/compile/stream", strings.NewReader(body))
+	request = mux.SetURLVars(request, map[string]string{"taskID": "task", "stage": "build", "job": "compile"})
+	recorder := httptest.NewRecorder()
+	service.CreateLogStream(request, recorder)
+	return recorder.Code
+}
+
+func Test_LogStreamTail(t *testing.T) {
+	path, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, path)
+	assert.Equal(t, http.StatusOK, streamLines(service, `{"stream":"stdout","line":"first"}
+{"stream":"stderr","line":"second"}
+`))
+	assert.Equal(t, http.StatusOK, streamLines(service, `{"stream":"stdout","line":"third"}`))
+	assert.Equal(t, http.StatusBadRequest, streamLines(service, `{"stream":`))
+
+	// задача неизвестна мастеру, поэтому трансляция завершается после отправки уже записанных строк
+	request := httptest.NewRequest(http.MethodGet, "/task/task/log/build/compile/stream?offset=1", nil)
+	recorder := httptest.NewRecorder()
+	service.TailLogStream(request, recorder, "task", "build", "compile")
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+	body := recorder.Body.String()
+	assert.NotContains(t, body, "first")
+	assert.Contains(t, body, "id: 1\nevent: log\ndata: {\"offset\":1,\"timestamp\":\"0001-01-01T00:00:00Z\",\"stream\":\""+models.LogStreamStderr+"\",\"line\":\"second\"}\n\n")
+	assert.Contains(t, body, "id: 2\n")
+	assert.True(t, strings.HasSuffix(body, "event: end\ndata: {}\n\n"))
+
+	request = httptest.NewRequest(http.MethodGet, "/task/task/log/build/compile/stream", nil)
+	request.Header.Set("Last-Event-ID", "1")
+	recorder = httptest.NewRecorder()
+	service.TailLogStream(request, recorder, "task", "build", "compile")
+	assert.NotContains(t, recorder.Body.String(), "second")
+	assert.Contains(t, recorder.Body.String(), "third")
+}
+
+func Test_CreateLogTaskWithFilters(t *testing.T) {
+	path, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, path)
+	started := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	body, _ := json.Marshal(models.LogsPerTask{Records: []models.LogLine{
+		{Timestamp: started, Stream: models.LogStreamBuild, Line: "Step 1/2"},
+		{Timestamp: started.Add(time.Second), Stream: models.LogStreamStdout, Line: "coverage: 80%"},
+		{Timestamp: started.Add(2 * time.Second), Stream: models.LogStreamStderr, Line: "warning"},
+		{Timestamp: started.Add(3 * time.Second), Stream: models.LogStreamStdout, Line: "coverage: 90%"},
+	}})
+	request := httptest.NewRequest(http.MethodPost, "/task/task/log/build/compile", bytes.NewReader(body))
+	request = mux.SetURLVars(request, map[string]string{"taskID": "task", "stage": "build", "job": "compile"})
+	recorder := httptest.NewRecorder()
+	service.CreateLogTask(request, recorder)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	text, _ := ioutil.ReadFile(path + "/task/build/compile.log")
+	assert.Equal(t, "2020-05-01 10:00:00.000 [build] Step 1/2\n", strings.SplitAfter(string(text), "\n")[0])
+	assert.Equal(t, 4, strings.Count(string(text), "\n"))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		service.GetLogsPerTask(httptest.NewRequest(http.MethodGet, "/task/task/log?"+query, nil), recorder, "task", "build", "compile")
+		return recorder
+	}
+	assert.Equal(t, "2020-05-01 10:00:02.000 [stderr] warning\n", get("stream=stderr").Body.String())
+	assert.Equal(t, "2020-05-01 10:00:03.000 [stdout] coverage: 90%\n", get("grep=coverage&from=2").Body.String())
+	assert.Equal(t, 2, strings.Count(get("stream=stdout,build&to=1").Body.String(), "\n"))
+	assert.Equal(t, http.StatusBadRequest, get("from=first").Code)
+	assert.Equal(t, http.StatusBadRequest, get("grep=(").Code)
+
+	// записи всех job этапа
+	assert.Equal(t, 2, strings.Count(get("grep=coverage").Body.String(), "coverage"))
+	recorder = httptest.NewRecorder()
+	service.GetLogsPerTask(httptest.NewRequest(http.MethodGet, "/task/task/log?stream=build", nil), recorder, "task", "build", "")
+	assert.Equal(t, "2020-05-01 10:00:00.000 [build] Step 1/2\n", recorder.Body.String())
+}
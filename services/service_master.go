@@ -3,18 +3,23 @@ package services
 import (
 	"bufio"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/api_keys"
 	"github.com/kubitre/diplom/config"
 	"github.com/kubitre/diplom/core"
 	"github.com/kubitre/diplom/enhancer"
 	"github.com/kubitre/diplom/models"
 	"github.com/kubitre/diplom/payloads"
+	"github.com/kubitre/diplom/runner_auth"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -22,6 +27,8 @@ import (
 type MasterRunnerService struct {
 	masterCore   *core.MasterRunnerCore
 	masterConfig *config.ConfigurationMasterRunner
+	apiKeys      *api_keys.KeyStore
+	auditLog     *api_keys.AuditLog
 }
 
 // GetCore - отдать текущее ядро
@@ -41,14 +48,76 @@ func InitializeMasterRunnerService(configService *config.ServiceConfig, masterCo
 		return nil, err
 	}
 	coreMaster.Run()
-	return &MasterRunnerService{
+	service := &MasterRunnerService{
 		masterCore:   coreMaster,
 		masterConfig: masterConfig,
-	}, nil
+		apiKeys:      api_keys.NewKeyStore(masterConfig.PathToAPIKeys, masterConfig.AgentID),
+		auditLog:     api_keys.NewAuditLog(masterConfig.PathToAuditLog),
+	}
+	go service.runArtefactsRetention()
+	go service.runSourcesCleanup()
+	return service, nil
 }
 
 /*NewTask - создание задачи*/
 func (service *MasterRunnerService) NewTask(taskConfig *models.TaskConfig, request *http.Request, writer http.ResponseWriter) {
+	api_keys.SetAuditTask(request, taskConfig.TaskID)
+	if errValidate := taskConfig.Validate(); errValidate != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "monitor",
+				"func":    "NewTask",
+			},
+			"detailed": map[string]string{
+				"message": "invalid task configuration",
+				"trace":   errValidate.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	if errCallback := service.masterCore.Webhooks.CheckCallbackURL(taskConfig.CallbackURL); errCallback != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "NewTask",
+			},
+			"detailed": map[string]string{
+				"message": "invalid task configuration",
+				"trace":   errCallback.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	if errSecrets := service.checkTaskSecrets(taskConfig); errSecrets != "" {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "NewTask",
+			},
+			"detailed": map[string]string{
+				"message": "invalid task configuration",
+				"trace":   errSecrets,
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	if errSource := service.checkTaskSource(taskConfig); errSource != "" {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "NewTask",
+			},
+			"detailed": map[string]string{
+				"message": "invalid task configuration",
+				"trace":   errSource,
+			},
+		}, http.StatusBadRequest)
+		return
+	}
 	if exist := service.masterCore.SlaveMoniring.CheckTaskIDExist(taskConfig.TaskID); exist {
 		enhancer.Response(request, writer, map[string]interface{}{
 			"context": map[string]string{
@@ -62,7 +131,7 @@ func (service *MasterRunnerService) NewTask(taskConfig *models.TaskConfig, reque
 		}, http.StatusConflict)
 		return
 	}
-	if errRedirect := service.masterCore.SlaveMoniring.SendSlaveTask(request, writer, taskConfig); errRedirect != nil {
+	if errQueue := service.masterCore.SlaveMoniring.EnqueueTask(taskConfig); errQueue != nil {
 		enhancer.Response(request, writer, map[string]interface{}{
 			"context": map[string]string{
 				"module":  "master_executor",
@@ -70,17 +139,25 @@ func (service *MasterRunnerService) NewTask(taskConfig *models.TaskConfig, reque
 				"func":    "NewTask",
 			},
 			"detailed": map[string]string{
-				"message": "can't redirect new task into slave executor",
-				"trace":   errRedirect.Error(),
+				"message": "can't queue new task",
+				"trace":   errQueue.Error(),
 			},
 		}, http.StatusConflict)
 		return
 	}
+	position, depth := service.masterCore.SlaveMoniring.GetQueuePosition(taskConfig.TaskID)
 	enhancer.Response(request, writer, map[string]interface{}{
-		"status": "completed created task",
+		"status":         "completed created task",
+		"queue_position": position,
+		"queue_depth":    depth,
 	}, http.StatusOK)
 }
 
+// GetQueuePosition - позиция задачи в очереди мастера (0 - задача уже отправлена на слейв) и глубина очереди
+func (service *MasterRunnerService) GetQueuePosition(taskID string) (int, int) {
+	return service.masterCore.SlaveMoniring.GetQueuePosition(taskID)
+}
+
 /*ChangeStatusTask - изменить статус задачи*/
 func (service *MasterRunnerService) ChangeStatusTask(statusTaskChangePayload *payloads.ChangeStatusTask, request *http.Request, writer http.ResponseWriter) {
 	if errValide := statusTaskChangePayload.Validate(); errValide != nil {
@@ -137,8 +214,48 @@ func (service *MasterRunnerService) ChangeStatusJob(statusTaskChangePayload *pay
 	}, http.StatusOK)
 }
 
-// GetLogsPerTask - получение логов по задаче (в случае если будет передан только taskID мержатся все логи из задачи, если будет taskID и stage - тогда только логи по стади и таске ну и по job в случае передачи taskID, stage, job)
+// CancelTask - отмена задачи по её идентификатору
+func (service *MasterRunnerService) CancelTask(request *http.Request, writer http.ResponseWriter, taskID string) {
+	if errCancel := service.masterCore.SlaveMoniring.CancelTask(taskID); errCancel != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "monitor",
+				"func":    "CancelTask",
+			},
+			"detailed": map[string]string{
+				"message": "can not cancel task",
+				"trace":   errCancel.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "task was canceled",
+	}, http.StatusOK)
+}
+
+// GetLogsPerTask - получение логов по задаче (в случае если будет передан только taskID мержатся все логи из задачи, если будет taskID и stage - тогда только логи по стади и таске ну и по job в случае передачи taskID, stage, job). Параметры stream, from, to и grep фильтруют записи лога
 func (service *MasterRunnerService) GetLogsPerTask(request *http.Request, writer http.ResponseWriter, taskID, stage, job string) {
+	filter, errFilter := enhancer.ParseLogFilter(request.URL.Query())
+	if errFilter != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "getLogTask",
+			},
+			"detailed": map[string]string{
+				"message": "invalid log filter",
+				"trace":   errFilter.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	if !filter.IsEmpty() {
+		service.getFilteredLogs(request, writer, taskID, stage, job, filter)
+		return
+	}
 	resultFile, errPreparing := enhancer.Mergelog(service.masterConfig.PathToLogsWork, taskID, stage, job)
 	if errPreparing != nil {
 		log.Println("can not preparing log: ", errPreparing)
@@ -160,6 +277,29 @@ func (service *MasterRunnerService) GetLogsPerTask(request *http.Request, writer
 	http.ServeFile(writer, request, resultFile)
 }
 
+/*getFilteredLogs - текстовое представление отфильтрованных записей лога*/
+func (service *MasterRunnerService) getFilteredLogs(request *http.Request, writer http.ResponseWriter, taskID, stage, job string, filter enhancer.LogFilter) {
+	records, err := enhancer.FilterLogRecords(service.masterConfig.PathToLogsWork, taskID, stage, job, filter)
+	if err != nil {
+		log.Println("can not filter log: ", err)
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "getLogTask",
+			},
+			"detailed": map[string]string{
+				"message": "can not be filtered logs",
+				"trace":   err.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	service.textNotationLog(records, writer)
+}
+
 // CreateLogTask - закрытый метод разрешённый только для воркеров. Создание логов по задаче (по каждой конкретной job)
 func (service *MasterRunnerService) CreateLogTask(request *http.Request, writer http.ResponseWriter) {
 	var model models.LogsPerTask
@@ -200,6 +340,31 @@ func (service *MasterRunnerService) CreateLogTask(request *http.Request, writer
 		}, http.StatusBadRequest)
 		return
 	}
+	records := model.LogRecords()
+	for index := range records {
+		records[index].Offset = int64(index)
+		if records[index].Stage == "" {
+			records[index].Stage = stage
+		}
+		if records[index].Job == "" {
+			records[index].Job = job
+		}
+	}
+	if errWrite := writeLogRecords(logPath+"/"+job+enhancer.StructuredLogExt, records); errWrite != nil {
+		log.Println("can not write structured log: ", errWrite)
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "routers",
+				"func":    "createLogWork",
+			},
+			"detailed": map[string]string{
+				"message": "can't create new log",
+				"trace":   errWrite.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
 	file, err := os.Create(logPath + "/" + job + ".log")
 	if err != nil {
 		log.Println("can not create log file: ", err)
@@ -216,28 +381,46 @@ func (service *MasterRunnerService) CreateLogTask(request *http.Request, writer
 		}, http.StatusBadRequest)
 		return
 	}
-	service.textNotationLog(&model, file)
+	defer file.Close()
+	service.textNotationLog(records, file)
 	enhancer.Response(request, writer, map[string]interface{}{
 		"status": "completed create log task by taskID and stage name",
 	}, http.StatusOK)
 }
 
-func (service *MasterRunnerService) textNotationLog(logs *models.LogsPerTask, file *os.File) {
+/*textNotationLog - текстовое представление записей лога*/
+func (service *MasterRunnerService) textNotationLog(records []models.LogLine, file io.Writer) {
 	writer := bufio.NewWriter(file)
-	if len(logs.STDOUT) > 0 {
-		writer.WriteString("Stdout:\n")
-		for _, line := range logs.STDOUT {
-			writer.WriteString(line)
-		}
-		writer.WriteString("\n")
+	for _, record := range records {
+		writer.WriteString(record.Render())
 	}
-	if len(logs.STDERR) > 0 {
-		writer.WriteString("Stderr:\n")
-		for _, line := range logs.STDERR {
-			writer.WriteString(line)
+	writer.Flush()
+}
+
+/*writeLogRecords - запись лога job в формате JSON lines. Файл заменяется целиком, чтобы читатели потока логов дочитали предыдущую версию*/
+func writeLogRecords(fileName string, records []models.LogLine) error {
+	temporary, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(temporary)
+	encoder := json.NewEncoder(writer)
+	for index := range records {
+		if err = encoder.Encode(&records[index]); err != nil {
+			break
 		}
 	}
-	writer.Flush()
+	if err == nil {
+		err = writer.Flush()
+	}
+	if errClose := temporary.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	return os.Rename(temporary.Name(), fileName)
 }
 
 // GetTaskStatus - получить статус задачи по её идентификатору
@@ -263,13 +446,53 @@ func (service *MasterRunnerService) GetTaskStatus(request *http.Request, writer
 // GetStatusWorkers - получение текущего состояния всех воркеров
 func (service *MasterRunnerService) GetStatusWorkers(request *http.Request, writer http.ResponseWriter) {
 	enhancer.Response(request, writer, map[string]interface{}{
-		"available": enhancer.MergeTasksWithSlaves(service.masterCore.SlaveMoniring.SlavesAvailable, service.masterCore.SlaveMoniring.AllTask, service.masterCore.SlaveMoniring.CurrentExecutingTask, service.masterCore.SlaveMoniring.History),
+		"available":   enhancer.MergeTasksWithSlaves(service.masterCore.SlaveMoniring.GetSlaves(), service.masterCore.SlaveMoniring.GetAllTasks()),
+		"queue_depth": len(service.masterCore.SlaveMoniring.GetQueue()),
+	}, http.StatusOK)
+}
+
+// GetAllTasks - получение всех задач мастера (выполняющихся и завершённых)
+func (service *MasterRunnerService) GetAllTasks(request *http.Request, writer http.ResponseWriter) {
+	enhancer.Response(request, writer, map[string]interface{}{
+		"allTasks": models.ConvertArrayTasks(service.masterCore.SlaveMoniring.GetAllTasks()),
 	}, http.StatusOK)
 }
 
-/*CreateReportsPerTask - запись отчётов по задаче*/
+// GetTasksHistory - получение завершённых задач за промежуток времени ?from=:unix&to=:unix
+func (service *MasterRunnerService) GetTasksHistory(request *http.Request, writer http.ResponseWriter) {
+	timeFrom, errFrom := parseUnixTime(request.URL.Query().Get("from"))
+	timeTo, errTo := parseUnixTime(request.URL.Query().Get("to"))
+	if errFrom != nil || errTo != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "GetTasksHistory",
+			},
+			"detailed": map[string]string{
+				"message": "from and to should be unix time in seconds",
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"history": models.ConvertArrayTasks(service.masterCore.SlaveMoniring.GetTasksHistory(timeFrom, timeTo)),
+	}, http.StatusOK)
+}
+
+func parseUnixTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+/*testsReportSuffix - суффикс файла отчётов о тестах job в директории отчётов задачи*/
+const testsReportSuffix = ".tests.json"
+
+/*CreateReportsPerTask - запись отчётов job: метрики в {job}.json, отчёты о тестах в {job}.tests.json*/
 func (service *MasterRunnerService) CreateReportsPerTask(request *http.Request, writer http.ResponseWriter) {
-	var model map[string][]string
+	var model models.ReportPerTask
 	if err := json.NewDecoder(request.Body).Decode(&model); err != nil {
 		log.Println("can not parsed body: ", err)
 		enhancer.Response(request, writer, map[string]interface{}{
@@ -306,99 +529,197 @@ func (service *MasterRunnerService) CreateReportsPerTask(request *http.Request,
 		}, http.StatusBadRequest)
 		return
 	}
-
-	_, err := os.Create(reportPath + "/" + job + ".json")
-	if err != nil {
-		log.Println("can not create log file: ", err)
+	if model.Result == nil {
+		model.Result = map[string]models.MetricReport{}
+	}
+	errWrite := writeJSONFile(reportPath+"/"+job+".json", model.Result)
+	if errWrite == nil && len(model.Tests) > 0 {
+		errWrite = writeJSONFile(reportPath+"/"+job+testsReportSuffix, model.Tests)
+	}
+	if errWrite != nil {
+		log.Error("can not write reports: ", errWrite)
 		enhancer.Response(request, writer, map[string]interface{}{
 			"context": map[string]string{
 				"module":  "master_executor",
-				"package": "routers",
-				"func":    "createLogWork",
+				"package": "services",
+				"func":    "CreateReportsPerTask",
 			},
 			"detailed": map[string]string{
-				"message": "can't create new log",
-				"trace":   err.Error(),
+				"message": "can't write reports",
+				"trace":   errWrite.Error(),
 			},
-		}, http.StatusBadRequest)
+		}, http.StatusInternalServerError)
 		return
 	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "success writing reports",
+	}, http.StatusOK)
+}
+
+/*writeJSONFile - запись модели в файл через временный файл, чтобы читатели не видели недописанный отчёт*/
+func writeJSONFile(fileName string, model interface{}) error {
 	marshaling, errMarshal := json.Marshal(model)
 	if errMarshal != nil {
-		log.Error(errMarshal)
-		return
+		return errMarshal
 	}
-	log.Debug("Value after marshaling: ", string(marshaling))
-	ioutil.WriteFile(reportPath+"/"+job+".json", marshaling, 0666)
-	enhancer.Response(request, writer, map[string]interface{}{
-		"status": "success writing reports",
-	}, http.StatusNotImplemented)
+	if err := ioutil.WriteFile(fileName+".tmp", marshaling, 0666); err != nil {
+		return err
+	}
+	return os.Rename(fileName+".tmp", fileName)
 }
 
-// GetReportPerTask - получение отчёта по задаче (в случае, если в задаче использовались extra параметры, для выделения каких-либо метрик и т.д.)
-func (service *MasterRunnerService) GetReportPerTask(request *http.Request, writer http.ResponseWriter) map[string][]string {
+// GetReportPerTask - получение отчётов job (метрики, выделенные из логов, и отчёты о тестах). При ошибке ответ уже отправлен и возвращается nil
+func (service *MasterRunnerService) GetReportPerTask(request *http.Request, writer http.ResponseWriter) *models.ReportPerTask {
 	vars := mux.Vars(request)
-	taskID := vars["taskID"]
-	job := vars["job"]
-	result, errReportGetting := service.GetReportsForStatus(taskID, job)
+	reportPath := service.GetReportPath() + "/" + vars["taskID"] + "/" + vars["job"]
+	result, errReportGetting := service.GetReportsForStatus(vars["taskID"], reportPath+".json")
+	tests, errTests := readTestReports(reportPath + testsReportSuffix)
+	if errReportGetting == nil {
+		errReportGetting = errTests
+	}
 	if errReportGetting != nil {
+		status := http.StatusConflict
+		if os.IsNotExist(errReportGetting) {
+			status = http.StatusNotFound
+		}
 		enhancer.Response(request, writer, map[string]interface{}{
 			"trace": errReportGetting.Error(),
-		}, http.StatusConflict)
+		}, status)
+		return nil
 	}
-	return result
+	return &models.ReportPerTask{Result: result, Tests: tests}
 }
 
-func (service *MasterRunnerService) GetReportsTask(taskID string) (map[string][]string, error) {
-	reportPath := service.GetReportPath() + "/" + taskID
-	var files []string
-	result := map[string][]string{}
-	if err := filepath.Walk(reportPath, func(path string, info os.FileInfo, err error) error {
-		if path == reportPath {
-			return nil
-		}
-		files = append(files, path)
-		return nil
-	}); err != nil {
-		return nil, err
+/*GetReportsTask - отчёты всех job задачи: метрики объединяются по имени, отчёты о тестах - в порядке имён job*/
+func (service *MasterRunnerService) GetReportsTask(taskID string) (models.ReportPerTask, error) {
+	result := models.ReportPerTask{Result: map[string]models.MetricReport{}, Tests: []models.TestReport{}}
+	jobReports, jobs, err := service.getJobsReports(taskID)
+	if err != nil {
+		return result, err
 	}
-	for _, file := range files {
-		log.Debug("started getting metrics from file: ", file)
-		resJob, errMetrics := service.GetReportsForStatus(taskID, file)
-		log.Debug("getting metrics: ", resJob)
-		if errMetrics != nil {
-			return nil, errMetrics
-		}
-		result = service.appendMap(result, resJob)
+	for _, job := range jobs {
+		result.Append(jobReports[job])
 	}
-	log.Debug("result metrics map: ", result)
+	log.Debug("result metrics map: ", result.Result)
 	return result, nil
 }
 
-func (service *MasterRunnerService) appendMap(resultMap map[string][]string, additionalMap map[string][]string) map[string][]string {
-	enhancedResult := map[string][]string{}
-	for firstname, values := range resultMap {
-		additionalValues := values
-		for secondName, valuesSecond := range additionalMap {
-			if secondName == firstname {
-				additionalValues = append(additionalValues, valuesSecond...)
+/*getJobsReports - отчёты каждой job задачи и имена job в порядке их файлов отчётов*/
+func (service *MasterRunnerService) getJobsReports(taskID string) (map[string]models.ReportPerTask, []string, error) {
+	reportPath := service.GetReportPath() + "/" + taskID
+	result := map[string]models.ReportPerTask{}
+	jobs := []string{}
+	files, err := ioutil.ReadDir(reportPath)
+	if os.IsNotExist(err) {
+		return result, jobs, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		fileName := reportPath + "/" + file.Name()
+		if file.IsDir() || !strings.HasSuffix(fileName, ".json") {
+			continue
+		}
+		job := strings.TrimSuffix(strings.TrimSuffix(file.Name(), testsReportSuffix), ".json")
+		report, exist := result[job]
+		if !exist {
+			jobs = append(jobs, job)
+		}
+		if strings.HasSuffix(fileName, testsReportSuffix) {
+			tests, errTests := readTestReports(fileName)
+			if errTests != nil {
+				return nil, nil, errTests
+			}
+			report.Tests = tests
+		} else {
+			log.Debug("started getting metrics from file: ", fileName)
+			metrics, errMetrics := service.GetReportsForStatus(taskID, fileName)
+			if errMetrics != nil {
+				return nil, nil, errMetrics
 			}
+			report.Result = metrics
 		}
-		enhancedResult[firstname] = additionalValues
+		result[job] = report
 	}
-	for firstname, values := range additionalMap {
-		additionalValues := values
-		for secondName, valuesSecond := range enhancedResult {
-			if secondName == firstname {
-				additionalValues = append(additionalValues, valuesSecond...)
-			}
+	return result, jobs, nil
+}
+
+/*GetWebhookDeliveries - история доставок изменений статусов задачи на webhook*/
+func (service *MasterRunnerService) GetWebhookDeliveries(request *http.Request, writer http.ResponseWriter, taskID string) {
+	deliveries := []models.WebhookDelivery{}
+	if service.masterCore.Webhooks != nil {
+		var err error
+		if deliveries, err = service.masterCore.Webhooks.History(taskID); err != nil {
+			enhancer.Response(request, writer, map[string]interface{}{
+				"context": map[string]string{
+					"module":  "master_executor",
+					"package": "services",
+					"func":    "GetWebhookDeliveries",
+				},
+				"detailed": map[string]string{
+					"message": "can't read webhook deliveries",
+					"trace":   err.Error(),
+				},
+			}, http.StatusConflict)
+			return
 		}
-		enhancedResult[firstname] = additionalValues
 	}
-	return enhancedResult
+	enhancer.Response(request, writer, map[string]interface{}{
+		"deliveries": deliveries,
+	}, http.StatusOK)
+}
+
+/*GetTaskScore - оценка задачи по правилам scoring из её конфигурации. nil - правил нет или отчёты не удалось прочитать*/
+func (service *MasterRunnerService) GetTaskScore(task *models.Task) *models.TaskScore {
+	jobReports, _, err := service.getJobsReports(task.ID)
+	if err != nil {
+		log.Error("can not read reports for scoring task: ", task.ID, " by error: ", err)
+		return nil
+	}
+	return models.EvaluateScore(task, jobReports)
+}
+
+/*WriteReportsTask - ответ с отчётами всех job задачи*/
+func (service *MasterRunnerService) WriteReportsTask(request *http.Request, writer http.ResponseWriter, taskID string) {
+	reports, err := service.GetReportsTask(taskID)
+	if err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "WriteReportsTask",
+			},
+			"detailed": map[string]string{
+				"message": "can't read reports of task",
+				"trace":   err.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"metrics": reports.Result,
+		"tests":   reports.Tests,
+	}, http.StatusOK)
 }
 
-func (service *MasterRunnerService) GetReportsForStatus(taskID, fileName string) (map[string][]string, error) {
+/*readTestReports - отчёты о тестах job. Отсутствие файла - в job нет таких отчётов*/
+func readTestReports(fileName string) ([]models.TestReport, error) {
+	readed, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return []models.TestReport{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tests []models.TestReport
+	if errUnmarshal := json.Unmarshal(readed, &tests); errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+	return tests, nil
+}
+
+func (service *MasterRunnerService) GetReportsForStatus(taskID, fileName string) (map[string]models.MetricReport, error) {
 	file, errOpen := os.Open(fileName)
 	if errOpen != nil {
 		log.Error("can not read report path: ", errOpen)
@@ -410,7 +731,7 @@ func (service *MasterRunnerService) GetReportsForStatus(taskID, fileName string)
 		log.Error("can not open file for reading: ", errReading)
 		return nil, errReading
 	}
-	var model map[string][]string
+	var model map[string]models.MetricReport
 	if errUnmarshal := json.Unmarshal(readed, &model); errUnmarshal != nil {
 		log.Error("can not unmarshal readed file into model: ", errReading)
 		return nil, errUnmarshal
@@ -418,7 +739,17 @@ func (service *MasterRunnerService) GetReportsForStatus(taskID, fileName string)
 	return model, nil
 }
 
-/*GetAgentID - получение текущего идентификатора агента*/
-func (service *MasterRunnerService) GetAgentID() string {
-	return service.masterConfig.AgentID
+/*GetRunnerAuth - проверка подписи запросов слейвов*/
+func (service *MasterRunnerService) GetRunnerAuth() *runner_auth.RunnerAuth {
+	return service.masterCore.SlaveMoniring.Auth
+}
+
+/*GetAPIKeys - ключи доступа к API мастера*/
+func (service *MasterRunnerService) GetAPIKeys() *api_keys.KeyStore {
+	return service.apiKeys
+}
+
+/*GetAuditLog - журнал запросов к API мастера*/
+func (service *MasterRunnerService) GetAuditLog() *api_keys.AuditLog {
+	return service.auditLog
 }
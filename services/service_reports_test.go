@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func uploadReports(service *MasterRunnerService, job, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/task/task/reports/"+job, bytes.NewBufferString(body))
+	request = mux.SetURLVars(request, map[string]string{"taskID": "task", "job": job})
+	recorder := httptest.NewRecorder()
+	service.CreateReportsPerTask(request, recorder)
+	return recorder
+}
+
+func Test_Reports(t *testing.T) {
+	path, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, path)
+	service.masterConfig.PathToReportsWork = path
+
+	reports, err := service.GetReportsTask("task")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(reports.Tests))
+
+	assert.Equal(t, http.StatusOK, uploadReports(service, "lint", `{"metrics":{"warnings":{"matches":[{"count":"3"}],"aggregate":"sum","field":"count","value":"3"}},"tests":null}`).Code)
+	assert.Equal(t, http.StatusOK, uploadReports(service, "test", `{"metrics":{"warnings":{"matches":[{"count":"1"},{"count":"2"}],"aggregate":"sum","field":"count","value":"3"}},"tests":[
+		{"name":"unit","type":"junit","job":"test","total":2,"passed":1,"failed":1,"coverage":75.5}
+	]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, uploadReports(service, "broken", `not json`).Code)
+
+	reports, err = service.GetReportsTask("task")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(reports.Result["warnings"].Matches))
+	assert.Equal(t, "6", reports.Result["warnings"].Value)
+	assert.Equal(t, 1, len(reports.Tests))
+	assert.Equal(t, 1, reports.Tests[0].Failed)
+	assert.Equal(t, 75.5, *reports.Tests[0].Coverage)
+
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/task/task/reports/lint", nil), map[string]string{"taskID": "task", "job": "lint"})
+	jobReports := service.GetReportPerTask(request, httptest.NewRecorder())
+	assert.Equal(t, "3", jobReports.Result["warnings"].Value)
+	assert.Equal(t, 0, len(jobReports.Tests))
+
+	score := service.GetTaskScore(&models.Task{ID: "task", StatusTask: models.SUCCESS, Config: &models.TaskConfig{Scoring: &models.Scoring{Rules: []models.ScoringRule{
+		{Job: "lint", Check: "warnings <= 3"},
+		{Job: "test", Check: "failed_tests == 0"},
+	}}}})
+	assert.Equal(t, 50.0, score.Score)
+	assert.Equal(t, 3.0, *score.Rules[0].Value)
+
+	// метрики, сохранённые в старом формате (результат FindStringSubmatch)
+	ioutil.WriteFile(path+"/task/legacy.json", []byte(`{"version":["go1.14","1.14"]}`), 0666)
+	reports, err = service.GetReportsTask("task")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"go1.14"}, reports.Result["version"].Values())
+
+	recorder := httptest.NewRecorder()
+	request = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/task/task/reports/missing", nil), map[string]string{"taskID": "task", "job": "missing"})
+	assert.Nil(t, service.GetReportPerTask(request, recorder))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func Test_NewTaskValidatesMetricRegex(t *testing.T) {
+	service := newTestService(t, "")
+	for taskID, report := range map[string]models.ReportConfig{
+		"regex":     {Type: models.ReportTypeRegex, Regex: `(?P<warnings>\d+`},
+		"aggregate": {Type: models.ReportTypeRegex, Regex: `(?P<warnings>\d+) warnings`, Aggregate: "avg"},
+		"type":      {Type: "xunit"},
+	} {
+		recorder := httptest.NewRecorder()
+		task := models.TaskConfig{TaskID: taskID, Stages: []string{"lint"}, Jobs: map[string]models.Job{
+			"lint": {Stage: "lint", Reports: map[string]models.ReportConfig{"warnings": report}},
+		}}
+		service.NewTask(&task, httptest.NewRequest(http.MethodPost, "/task", nil), recorder)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, taskID)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+/*maxSecretSize - ограничение размера значения секрета (ключи SSH, токены)*/
+const maxSecretSize = 64 << 10
+
+/*secretPayload - тело запроса на сохранение секрета*/
+type secretPayload struct {
+	Value string `json:"value"`
+}
+
+/*PutSecret - сохранение или замена секрета мастера {value}*/
+func (service *MasterRunnerService) PutSecret(request *http.Request, writer http.ResponseWriter, name string) {
+	var payload secretPayload
+	defer request.Body.Close()
+	if err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxSecretSize)).Decode(&payload); err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "PutSecret",
+			},
+			"detailed": map[string]string{
+				"message": "can't unmarshal secret",
+				"trace":   err.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	if err := service.masterCore.Secrets.Put(name, payload.Value); err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "PutSecret",
+			},
+			"detailed": map[string]string{
+				"message": "can't save secret",
+				"trace":   err.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	log.Info("secret was saved: ", name)
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "completed saved secret",
+		"name":   name,
+	}, http.StatusOK)
+}
+
+/*DeleteSecret - удаление секрета мастера*/
+func (service *MasterRunnerService) DeleteSecret(request *http.Request, writer http.ResponseWriter, name string) {
+	if err := service.masterCore.Secrets.Delete(name); err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "DeleteSecret",
+			},
+			"detailed": map[string]string{
+				"message": "can't delete secret",
+				"trace":   err.Error(),
+			},
+		}, http.StatusNotFound)
+		return
+	}
+	log.Info("secret was deleted: ", name)
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "completed deleted secret",
+		"name":   name,
+	}, http.StatusOK)
+}
+
+/*GetSecrets - имена секретов мастера без значений*/
+func (service *MasterRunnerService) GetSecrets(request *http.Request, writer http.ResponseWriter) {
+	names, err := service.masterCore.Secrets.Names()
+	if err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "GetSecrets",
+			},
+			"detailed": map[string]string{
+				"message": "can't read secrets",
+				"trace":   err.Error(),
+			},
+		}, http.StatusConflict)
+		return
+	}
+	enhancer.Response(request, writer, map[string]interface{}{
+		"secrets": names,
+		"enabled": service.masterCore.Secrets.Enabled(),
+	}, http.StatusOK)
+}
+
+/*checkTaskSecrets - все секреты задачи должны быть сохранены на мастере*/
+func (service *MasterRunnerService) checkTaskSecrets(taskConfig *models.TaskConfig) string {
+	names := taskConfig.SecretNames()
+	if len(names) == 0 {
+		return ""
+	}
+	if !service.masterCore.Secrets.Enabled() {
+		return "task uses secrets, but secret store is disabled"
+	}
+	if missing := service.masterCore.Secrets.Missing(names); len(missing) > 0 {
+		return "unknown secrets: " + strings.Join(missing, ", ")
+	}
+	return ""
+}
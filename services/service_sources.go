@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kubitre/diplom/enhancer"
+	"github.com/kubitre/diplom/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	sourceArchiveSuffix = ".tar.gz"
+	// sourcesCleanupInterval - период удаления архивов завершённых задач
+	sourcesCleanupInterval = 10 * time.Minute
+	// sourceUploadTTL - архив, для которого так и не была создана задача, удаляется через это время
+	sourceUploadTTL = 24 * time.Hour
+	// sourceFormField - поле multipart/form-data с архивом
+	sourceFormField = "source"
+)
+
+var errSourceNotFound = errors.New("source archive is not uploaded")
+
+func (service *MasterRunnerService) sourcePath(taskID string) string {
+	return service.masterConfig.PathToSources + "/" + taskID + sourceArchiveSuffix
+}
+
+/*UploadSource - загрузка кода кандидата архивом (zip, tar.gz или tar в теле запроса или в поле source multipart/form-data) до создания задачи с source: archive. Повторная загрузка заменяет архив*/
+func (service *MasterRunnerService) UploadSource(request *http.Request, writer http.ResponseWriter, taskID string) {
+	if service.masterCore.SlaveMoniring.CheckTaskIDExist(taskID) {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "UploadSource",
+			},
+			"detailed": map[string]string{
+				"message": "source can be uploaded only before task creation",
+				"trace":   "task already exists: " + taskID,
+			},
+		}, http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(service.masterConfig.PathToSources, 0700); err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "UploadSource",
+			},
+			"detailed": map[string]string{
+				"message": "can't save source archive",
+				"trace":   err.Error(),
+			},
+		}, http.StatusInternalServerError)
+		return
+	}
+	limit := service.masterConfig.MaxSourceSize * 1024 * 1024
+	defer request.Body.Close()
+	var body io.Reader = request.Body
+	limited := &io.LimitedReader{R: request.Body, N: limit + 1}
+	if limit > 0 {
+		// сжатый архив не может быть больше распакованного кода
+		body = limited
+	}
+	upload, err := ioutil.TempFile(service.masterConfig.PathToSources, taskID+".upload")
+	if err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "UploadSource",
+			},
+			"detailed": map[string]string{
+				"message": "can't save source archive",
+				"trace":   err.Error(),
+			},
+		}, http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(upload.Name())
+	errCopy := copySourceUpload(request, body, upload)
+	upload.Close()
+	if limit > 0 && limited.N == 0 {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "UploadSource",
+			},
+			"detailed": map[string]string{
+				"message": "invalid source archive",
+				"trace":   enhancer.ErrArchiveTooLarge.Error(),
+			},
+		}, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errCopy != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "UploadSource",
+			},
+			"detailed": map[string]string{
+				"message": "can't read source archive",
+				"trace":   errCopy.Error(),
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	files, err := service.packSource(upload.Name(), taskID, limit)
+	if err != nil {
+		code := http.StatusBadRequest
+		if err == enhancer.ErrArchiveTooLarge {
+			code = http.StatusRequestEntityTooLarge
+		}
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "UploadSource",
+			},
+			"detailed": map[string]string{
+				"message": "invalid source archive",
+				"trace":   err.Error(),
+			},
+		}, code)
+		return
+	}
+	log.Info("source archive was uploaded for task: ", taskID, " files: ", files)
+	enhancer.Response(request, writer, map[string]interface{}{
+		"status": "source archive saved",
+		"files":  files,
+	}, http.StatusOK)
+}
+
+/*copySourceUpload - архив из тела запроса или из поля source multipart/form-data*/
+func copySourceUpload(request *http.Request, body io.Reader, upload io.Writer) error {
+	mediaType, params, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		_, err := io.Copy(upload, body)
+		return err
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return errors.New("multipart form has no field " + sourceFormField)
+		}
+		if err != nil {
+			return err
+		}
+		if part.FormName() == sourceFormField {
+			_, err := io.Copy(upload, part)
+			return err
+		}
+	}
+}
+
+/*packSource - проверка и перепаковка загруженного архива в tar.gz задачи*/
+func (service *MasterRunnerService) packSource(upload, taskID string, limit int64) (int, error) {
+	packed, err := ioutil.TempFile(service.masterConfig.PathToSources, taskID+".pack")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(packed.Name())
+	files, err := enhancer.PackSourceArchive(upload, packed, limit)
+	if errClose := packed.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return 0, err
+	}
+	return files, os.Rename(packed.Name(), service.sourcePath(taskID))
+}
+
+/*GetSource - закрытый метод для слейвов. Архив кода кандидата задачи в tar.gz*/
+func (service *MasterRunnerService) GetSource(request *http.Request, writer http.ResponseWriter, taskID string) {
+	file, err := os.Open(service.sourcePath(taskID))
+	if err != nil {
+		enhancer.Response(request, writer, map[string]interface{}{
+			"context": map[string]string{
+				"module":  "master_executor",
+				"package": "services",
+				"func":    "GetSource",
+			},
+			"detailed": map[string]string{
+				"message": "can't read source archive",
+				"trace":   errSourceNotFound.Error(),
+			},
+		}, http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	writer.Header().Set("Content-Type", "application/gzip")
+	writer.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(writer, file); err != nil {
+		log.Error("can not send source archive of task: ", taskID, " by error: ", err)
+	}
+}
+
+/*checkTaskSource - архив задачи с source: archive должен быть загружен до её создания*/
+func (service *MasterRunnerService) checkTaskSource(taskConfig *models.TaskConfig) string {
+	if taskConfig.Source != models.SourceArchive {
+		return ""
+	}
+	if _, err := os.Stat(service.sourcePath(taskConfig.TaskID)); err != nil {
+		return errSourceNotFound.Error() + ": upload it to /task/" + taskConfig.TaskID + "/source"
+	}
+	return ""
+}
+
+/*runSourcesCleanup - периодическое удаление архивов кода завершённых задач*/
+func (service *MasterRunnerService) runSourcesCleanup() {
+	for {
+		service.removeFinishedSources(time.Now())
+		time.Sleep(sourcesCleanupInterval)
+	}
+}
+
+/*removeFinishedSources - удаление архивов завершённых задач (перезапуск на другом слейве больше невозможен) и архивов, для которых задача не была создана за sourceUploadTTL*/
+func (service *MasterRunnerService) removeFinishedSources(now time.Time) {
+	archives, err := filepath.Glob(service.masterConfig.PathToSources + "/*" + sourceArchiveSuffix)
+	if err != nil {
+		return
+	}
+	for _, archive := range archives {
+		taskID := strings.TrimSuffix(filepath.Base(archive), sourceArchiveSuffix)
+		remove := false
+		if task, errTask := service.masterCore.SlaveMoniring.GetTaskStatus(taskID); errTask == nil {
+			remove = task.StatusTask.IsFinal()
+		} else if info, errStat := os.Stat(archive); errStat == nil {
+			remove = info.ModTime().Before(now.Add(-sourceUploadTTL))
+		}
+		if remove {
+			log.Info("remove source archive of task: ", taskID)
+			if errRemove := os.Remove(archive); errRemove != nil {
+				log.Warn("can not remove source archive: ", errRemove)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kubitre/diplom/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*sourceZip - zip архив с файлами files*/
+func sourceZip(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+/*sourceFiles - содержимое tar.gz архива задачи на мастере*/
+func sourceFiles(t *testing.T, archive []byte) map[string]string {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := tar.NewReader(gzipReader)
+	result := map[string]string{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			content, _ := ioutil.ReadAll(reader)
+			result[header.Name] = string(content)
+		}
+	}
+}
+
+func uploadSource(service *MasterRunnerService, taskID, contentType string, body []byte) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/task/"+taskID+"/source", bytes.NewReader(body))
+	request.Header.Set("Content-Type", contentType)
+	recorder := httptest.NewRecorder()
+	service.UploadSource(request, recorder, taskID)
+	return recorder
+}
+
+func Test_UploadSource(t *testing.T) {
+	path, err := ioutil.TempDir("", "sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, "")
+	service.masterConfig.PathToSources = path
+	service.masterConfig.MaxSourceSize = 1
+
+	// директория верхнего уровня архива GitHub убирается
+	archive := sourceZip(t, map[string]string{
+		"candidate-main/main.go":     "package main",
+		"candidate-main/lib/util.go": "package lib",
+	})
+	assert.Equal(t, http.StatusOK, uploadSource(service, "task", "application/zip", archive).Code)
+
+	recorder := httptest.NewRecorder()
+	service.GetSource(httptest.NewRequest(http.MethodGet, "/task/task/source", nil), recorder, "task")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, map[string]string{"main.go": "package main", "lib/util.go": "package lib"}, sourceFiles(t, recorder.Body.Bytes()))
+
+	// multipart/form-data
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	form.WriteField("comment", "first version")
+	part, _ := form.CreateFormFile("source", "candidate.zip")
+	part.Write(sourceZip(t, map[string]string{"main.go": "package second"}))
+	form.Close()
+	assert.Equal(t, http.StatusOK, uploadSource(service, "form", form.FormDataContentType(), body.Bytes()).Code)
+
+	recorder = httptest.NewRecorder()
+	service.GetSource(httptest.NewRequest(http.MethodGet, "/task/form/source", nil), recorder, "form")
+	assert.Equal(t, map[string]string{"main.go": "package second"}, sourceFiles(t, recorder.Body.Bytes()))
+
+	recorder = httptest.NewRecorder()
+	service.GetSource(httptest.NewRequest(http.MethodGet, "/task/unknown/source", nil), recorder, "unknown")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// временные файлы загрузки не остаются
+	files, _ := ioutil.ReadDir(path)
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"form.tar.gz", "task.tar.gz"}, names)
+}
+
+func Test_UploadSourceRejected(t *testing.T) {
+	path, err := ioutil.TempDir("", "sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, "")
+	service.masterConfig.PathToSources = path
+	service.masterConfig.MaxSourceSize = 1
+
+	assert.Equal(t, http.StatusBadRequest, uploadSource(service, "traversal", "application/zip", sourceZip(t, map[string]string{"../../etc/passwd": "root"})).Code)
+	assert.Equal(t, http.StatusBadRequest, uploadSource(service, "absolute", "application/zip", sourceZip(t, map[string]string{"/etc/passwd": "root"})).Code)
+	assert.Equal(t, http.StatusBadRequest, uploadSource(service, "format", "application/octet-stream", []byte("plain text")).Code)
+	// файл сжимается до нескольких килобайт, но распакованный размер больше ограничения
+	large := sourceZip(t, map[string]string{"large.txt": string(bytes.Repeat([]byte("a"), 2*1024*1024))})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, uploadSource(service, "large", "application/zip", large).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, uploadSource(service, "body", "application/octet-stream", make([]byte, 1024*1024+1)).Code)
+	_, errStat := os.Stat(path + "/traversal.tar.gz")
+	assert.True(t, os.IsNotExist(errStat))
+
+	service.masterCore.SlaveMoniring.Tasks.Save(models.Task{ID: "exist", StatusTask: models.RUNNING})
+	assert.Equal(t, http.StatusConflict, uploadSource(service, "exist", "application/zip", sourceZip(t, map[string]string{"main.go": "package main"})).Code)
+}
+
+func Test_NewTaskRequiresSource(t *testing.T) {
+	path, err := ioutil.TempDir("", "sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, "")
+	service.masterConfig.PathToSources = path
+	create := func(taskID string) int {
+		task := models.TaskConfig{TaskID: taskID, Source: models.SourceArchive, Stages: []string{"build"}, Jobs: map[string]models.Job{"build": {Stage: "build"}}}
+		recorder := httptest.NewRecorder()
+		service.NewTask(&task, httptest.NewRequest(http.MethodPost, "/task", nil), recorder)
+		return recorder.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, create("task"))
+	assert.Equal(t, http.StatusOK, uploadSource(service, "task", "application/zip", sourceZip(t, map[string]string{"main.go": "package main"})).Code)
+	assert.Equal(t, http.StatusOK, create("task"))
+}
+
+func Test_RemoveFinishedSources(t *testing.T) {
+	path, err := ioutil.TempDir("", "sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	service := newTestService(t, "")
+	service.masterConfig.PathToSources = path
+	for _, taskID := range []string{"running", "finished", "orphan"} {
+		assert.Equal(t, http.StatusOK, uploadSource(service, taskID, "application/zip", sourceZip(t, map[string]string{"main.go": "package main"})).Code)
+	}
+	service.masterCore.SlaveMoniring.Tasks.Save(models.Task{ID: "running", StatusTask: models.RUNNING})
+	service.masterCore.SlaveMoniring.Tasks.Save(models.Task{ID: "finished", StatusTask: models.SUCCESS})
+
+	exists := func(taskID string) bool {
+		_, err := os.Stat(path + "/" + taskID + ".tar.gz")
+		return err == nil
+	}
+	service.removeFinishedSources(time.Now())
+	assert.True(t, exists("running"))
+	assert.False(t, exists("finished"))
+	assert.True(t, exists("orphan"))
+
+	service.removeFinishedSources(time.Now().Add(25 * time.Hour))
+	assert.True(t, exists("running"))
+	assert.False(t, exists("orphan"))
+}
@@ -1,19 +1,16 @@
 package validators
 
 import (
-	"errors"
+	"github.com/kubitre/diplom/models"
 )
 
-/*ValidateMetricsForPortal - валидация метрик к типу портала*/
-func ValidateMetricsForPortal(metrics map[string][]string) (map[string]string, error) {
+/*ValidateMetricsForPortal - метрики в виде портала: агрегированное значение метрики, а без агрегации - значение последнего совпадения (как aggregate: last)*/
+func ValidateMetricsForPortal(metrics map[string]models.MetricReport) map[string]string {
 	result := map[string]string{}
-	for nameMetric, values := range metrics {
-		if len(values) > 1 {
-			return nil, errors.New("parsed metrics contain more that one metric")
-		}
-		if len(values) == 1 {
-			result[nameMetric] = values[0]
+	for nameMetric, metric := range metrics {
+		if values := metric.Values(); len(values) > 0 {
+			result[nameMetric] = values[len(values)-1]
 		}
 	}
-	return result, nil
+	return result
 }